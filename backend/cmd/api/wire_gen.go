@@ -9,6 +9,7 @@ package main
 import (
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/handler/mcp"
 	"github.com/chaitin/panda-wiki/handler/share"
 	"github.com/chaitin/panda-wiki/handler/v1"
 	"github.com/chaitin/panda-wiki/log"
@@ -43,12 +44,13 @@ func createApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	ragService, err := rag.NewRAGService(configConfig, logger)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
 	if err != nil {
 		return nil, err
 	}
 	knowledgeBaseRepository := pg2.NewKnowledgeBaseRepository(db, configConfig, logger, ragService)
 	nodeRepository := pg2.NewNodeRepository(db, logger)
+	modelRepository := pg2.NewModelRepository(db, logger)
 	mqProducer, err := mq.NewMQProducer(configConfig, logger)
 	if err != nil {
 		return nil, err
@@ -59,32 +61,47 @@ func createApp() (*App, error) {
 		return nil, err
 	}
 	kbRepo := cache2.NewKBRepo(cacheCache)
-	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, ragService, kbRepo, logger, configConfig)
+	readerSessionRepo := cache2.NewReaderSessionRepo(cacheCache)
+	auditLogRepository := pg2.NewAuditLogRepository(db)
+	userRepository := pg2.NewUserRepository(db, logger)
+	auditUsecase := usecase.NewAuditUsecase(auditLogRepository, userRepository, logger)
+	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, modelRepository, ragRepository, ragService, kbRepo, readerSessionRepo, auditUsecase, logger, configConfig)
 	if err != nil {
 		return nil, err
 	}
-	shareAuthMiddleware := middleware.NewShareAuthMiddleware(logger, knowledgeBaseUsecase)
-	baseHandler := handler.NewBaseHandler(echo, logger, configConfig, shareAuthMiddleware)
-	userRepository := pg2.NewUserRepository(db, logger)
-	userUsecase, err := usecase.NewUserUsecase(userRepository, logger, configConfig)
+	apiTokenRepository := pg2.NewAPITokenRepository(db)
+	kbMemberRepository := pg2.NewKBMemberRepository(db)
+	settingRepository := pg2.NewSettingRepository(db)
+	twoFactorRepo := cache2.NewTwoFactorRepo(cacheCache)
+	sessionRepo := cache2.NewSessionRepo(cacheCache)
+	sessionUsecase := usecase.NewSessionUsecase(sessionRepo, logger)
+	userUsecase, err := usecase.NewUserUsecase(userRepository, apiTokenRepository, kbMemberRepository, settingRepository, twoFactorRepo, sessionUsecase, auditUsecase, logger, configConfig)
 	if err != nil {
 		return nil, err
 	}
+	oidcStateRepo := cache2.NewOIDCStateRepo(cacheCache)
+	oidcUsecase := usecase.NewOIDCUsecase(configConfig, userUsecase, oidcStateRepo, logger)
+	readerAuthUsecase := usecase.NewReaderAuthUsecase(knowledgeBaseUsecase, oidcUsecase, readerSessionRepo, logger)
+	shareAuthMiddleware := middleware.NewShareAuthMiddleware(logger, knowledgeBaseUsecase, readerAuthUsecase)
+	baseHandler := handler.NewBaseHandler(echo, logger, configConfig, shareAuthMiddleware)
 	userAccessRepository := pg2.NewUserAccessRepository(db, logger)
-	authMiddleware, err := middleware.NewAuthMiddleware(configConfig, logger, userAccessRepository)
+	rbacUsecase := usecase.NewRBACUsecase(userRepository, kbMemberRepository, auditUsecase, logger)
+	apiTokenUsecase := usecase.NewAPITokenUsecase(apiTokenRepository, userRepository, rbacUsecase, logger)
+	rbacMiddleware := middleware.NewRBACMiddleware(logger, rbacUsecase)
+	authMiddleware, err := middleware.NewAuthMiddleware(configConfig, logger, userAccessRepository, apiTokenUsecase, sessionUsecase, rbacMiddleware)
 	if err != nil {
 		return nil, err
 	}
-	userHandler := v1.NewUserHandler(echo, baseHandler, logger, userUsecase, authMiddleware, configConfig)
+	ldapUsecase := usecase.NewLDAPUsecase(configConfig, userUsecase, logger)
+	userHandler := v1.NewUserHandler(echo, baseHandler, logger, userUsecase, authMiddleware, rbacUsecase, ldapUsecase, configConfig)
 	conversationRepository := pg2.NewConversationRepository(db)
-	modelRepository := pg2.NewModelRepository(db, logger)
 	llmUsecase := usecase.NewLLMUsecase(configConfig, ragService, conversationRepository, knowledgeBaseRepository, nodeRepository, modelRepository, logger)
-	knowledgeBaseHandler := v1.NewKnowledgeBaseHandler(baseHandler, echo, knowledgeBaseUsecase, llmUsecase, authMiddleware, logger)
+	knowledgeBaseHandler := v1.NewKnowledgeBaseHandler(baseHandler, echo, knowledgeBaseUsecase, llmUsecase, authMiddleware, rbacUsecase, readerAuthUsecase, logger)
 	minioClient, err := s3.NewMinioClient(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, ragRepository, knowledgeBaseRepository, llmUsecase, logger, minioClient, modelRepository)
+	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, ragRepository, knowledgeBaseRepository, llmUsecase, auditUsecase, logger, minioClient, modelRepository)
 	nodeHandler := v1.NewNodeHandler(baseHandler, echo, nodeUsecase, authMiddleware, logger)
 	appRepository := pg2.NewAppRepository(db, logger)
 	ipdbIPDB, err := ipdb.NewIPDB(configConfig, logger)
@@ -93,13 +110,21 @@ func createApp() (*App, error) {
 	}
 	ipAddressRepo := ipdb2.NewIPAddressRepo(ipdbIPDB, logger)
 	conversationUsecase := usecase.NewConversationUsecase(conversationRepository, nodeRepository, logger, ipAddressRepo)
-	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository)
-	chatUsecase := usecase.NewChatUsecase(llmUsecase, conversationUsecase, modelUsecase, appRepository, logger)
-	appUsecase := usecase.NewAppUsecase(appRepository, nodeUsecase, logger, configConfig, chatUsecase)
-	appHandler := v1.NewAppHandler(echo, baseHandler, logger, authMiddleware, appUsecase, modelUsecase, conversationUsecase, configConfig)
+	modelUsecase := usecase.NewModelUsecase(modelRepository, nodeRepository, ragRepository, ragService, logger, configConfig, knowledgeBaseRepository, auditUsecase)
+	quotaRepo := cache2.NewQuotaRepo(cacheCache)
+	quotaUsecase := usecase.NewQuotaUsecase(quotaRepo, appRepository, knowledgeBaseRepository, configConfig, logger)
+	knowledgeGapRepository := pg2.NewKnowledgeGapRepository(db)
+	knowledgeGapUsecase := usecase.NewKnowledgeGapUsecase(knowledgeGapRepository, nodeUsecase, logger)
+	chatStreamRepo := cache2.NewChatStreamRepo(cacheCache)
+	chatUsecase, err := usecase.NewChatUsecase(llmUsecase, conversationUsecase, modelUsecase, quotaUsecase, knowledgeGapUsecase, appRepository, knowledgeBaseRepository, chatStreamRepo, logger)
+	if err != nil {
+		return nil, err
+	}
+	appUsecase := usecase.NewAppUsecase(appRepository, modelRepository, nodeUsecase, logger, configConfig, chatUsecase, auditUsecase)
+	appHandler := v1.NewAppHandler(echo, baseHandler, logger, authMiddleware, appUsecase, modelUsecase, conversationUsecase, rbacUsecase, configConfig)
 	fileUsecase := usecase.NewFileUsecase(logger, minioClient, configConfig)
 	fileHandler := v1.NewFileHandler(echo, baseHandler, logger, authMiddleware, minioClient, configConfig, fileUsecase)
-	modelHandler := v1.NewModelHandler(echo, baseHandler, logger, authMiddleware, modelUsecase, llmUsecase)
+	modelHandler := v1.NewModelHandler(echo, baseHandler, logger, authMiddleware, modelUsecase, llmUsecase, rbacUsecase)
 	conversationHandler := v1.NewConversationHandler(echo, baseHandler, logger, authMiddleware, conversationUsecase)
 	crawlerUsecase, err := usecase.NewCrawlerUsecase(logger)
 	if err != nil {
@@ -109,8 +134,22 @@ func createApp() (*App, error) {
 	epubUsecase := usecase.NewEpubUsecase(logger, minioClient)
 	crawlerHandler := v1.NewCrawlerHandler(echo, baseHandler, authMiddleware, logger, configConfig, crawlerUsecase, notionUseCase, epubUsecase)
 	creationUsecase := usecase.NewCreationUsecase(logger, llmUsecase, modelUsecase)
-	creationHandler := v1.NewCreationHandler(echo, baseHandler, logger, creationUsecase)
-	contentHandler := v1.NewContentHandler(baseHandler, echo, llmUsecase, modelUsecase, logger)
+	creationHandler := v1.NewCreationHandler(echo, baseHandler, logger, creationUsecase, authMiddleware)
+	contentHandler := v1.NewContentHandler(baseHandler, echo, llmUsecase, modelUsecase, logger, authMiddleware)
+	evalRepository := pg2.NewEvalRepository(db)
+	evalUsecase := usecase.NewEvalUsecase(evalRepository, knowledgeBaseRepository, modelRepository, llmUsecase, logger)
+	evalHandler := v1.NewEvalHandler(echo, baseHandler, logger, authMiddleware, evalUsecase)
+	quotaHandler := v1.NewQuotaHandler(echo, baseHandler, logger, authMiddleware, quotaUsecase)
+	knowledgeGapHandler := v1.NewKnowledgeGapHandler(echo, baseHandler, logger, authMiddleware, knowledgeGapUsecase)
+	appAPIKeyRepository := pg2.NewAppAPIKeyRepository(db)
+	appAPIKeyUsecase := usecase.NewAppAPIKeyUsecase(appAPIKeyRepository, appRepository, logger)
+	appAPIKeyHandler := v1.NewAppAPIKeyHandler(echo, baseHandler, logger, authMiddleware, appAPIKeyUsecase)
+	apiTokenHandler := v1.NewAPITokenHandler(echo, baseHandler, logger, authMiddleware, apiTokenUsecase)
+	kbMemberHandler := v1.NewKBMemberHandler(echo, baseHandler, logger, authMiddleware, rbacUsecase)
+	oidcHandler := v1.NewOIDCHandler(echo, baseHandler, logger, oidcUsecase)
+	twoFactorHandler := v1.NewTwoFactorHandler(echo, baseHandler, logger, authMiddleware, userUsecase, rbacUsecase)
+	sessionHandler := v1.NewSessionHandler(echo, baseHandler, logger, authMiddleware, sessionUsecase, rbacUsecase)
+	auditLogHandler := v1.NewAuditLogHandler(echo, baseHandler, logger, authMiddleware, auditUsecase)
 	apiHandlers := &v1.APIHandlers{
 		UserHandler:          userHandler,
 		KnowledgeBaseHandler: knowledgeBaseHandler,
@@ -122,14 +161,34 @@ func createApp() (*App, error) {
 		CrawlerHandler:       crawlerHandler,
 		CreationHandler:      creationHandler,
 		ContentHandler:       contentHandler,
+		EvalHandler:          evalHandler,
+		QuotaHandler:         quotaHandler,
+		KnowledgeGapHandler:  knowledgeGapHandler,
+		AppAPIKeyHandler:     appAPIKeyHandler,
+		APITokenHandler:      apiTokenHandler,
+		KBMemberHandler:      kbMemberHandler,
+		OIDCHandler:          oidcHandler,
+		TwoFactorHandler:     twoFactorHandler,
+		SessionHandler:       sessionHandler,
+		AuditLogHandler:      auditLogHandler,
 	}
+	shareAuthHandler := share.NewShareAuthHandler(echo, baseHandler, logger, readerAuthUsecase)
 	shareNodeHandler := share.NewShareNodeHandler(baseHandler, echo, nodeUsecase, logger)
 	shareAppHandler := share.NewShareAppHandler(echo, baseHandler, logger, appUsecase)
 	shareChatHandler := share.NewShareChatHandler(echo, baseHandler, logger, appUsecase, chatUsecase, conversationUsecase, modelUsecase)
+	appAPIKeyMiddleware := middleware.NewAppAPIKeyMiddleware(logger, appAPIKeyUsecase)
+	openAIUsecase := usecase.NewOpenAIUsecase(chatUsecase, knowledgeBaseRepository, logger)
+	openAIHandler := share.NewOpenAIHandler(echo, baseHandler, logger, appAPIKeyMiddleware, openAIUsecase)
+	mcpUsecase := usecase.NewMCPUsecase(ragService, nodeRepository, knowledgeBaseRepository, logger)
+	mcpServer := mcp.NewMCPServer(mcpUsecase, logger)
+	mcpHandler := share.NewMCPHandler(echo, baseHandler, logger, appAPIKeyMiddleware, mcpServer)
 	shareHandler := &share.ShareHandler{
+		ShareAuthHandler: shareAuthHandler,
 		ShareNodeHandler: shareNodeHandler,
 		ShareAppHandler:  shareAppHandler,
 		ShareChatHandler: shareChatHandler,
+		OpenAIHandler:    openAIHandler,
+		MCPHandler:       mcpHandler,
 	}
 	app := &App{
 		HTTPServer:    httpServer,
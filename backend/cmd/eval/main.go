@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// run a retrieval eval set and print the report, e.g.
+//
+//	panda-wiki-eval -set <eval_set_id> -k 5
+func main() {
+	setID := flag.String("set", "", "eval set id")
+	k := flag.Int("k", 0, "cut off rank, 0 to use top k of the knowledge base")
+	verbose := flag.Bool("v", false, "print result of every question")
+	flag.Parse()
+	if *setID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	app, err := createApp()
+	if err != nil {
+		panic(err)
+	}
+	report, err := app.EvalUsecase.RunEvalSet(context.Background(), &domain.RunEvalSetReq{
+		SetID: *setID,
+		K:     *k,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run eval set failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("report:          %s\n", report.ID)
+	fmt.Printf("embedding model: %s\n", report.EmbeddingModel)
+	fmt.Printf("rerank model:    %s\n", report.RerankModel)
+	fmt.Printf("cases:           %d\n", report.CaseCount)
+	fmt.Printf("recall@%d:        %.4f\n", report.K, report.RecallAtK)
+	fmt.Printf("mrr:             %.4f\n", report.MRR)
+	fmt.Printf("hit rate:        %.4f\n", report.HitRate)
+	for _, result := range report.Results {
+		if !*verbose && result.Hit && result.Recall == 1 {
+			continue
+		}
+		fmt.Printf("\n- %s\n  expected:  %s\n  retrieved: %s\n  recall: %.2f  rr: %.2f\n",
+			result.Question,
+			strings.Join(result.ExpectedNodeIDs, ", "),
+			strings.Join(result.RetrievedNodeIDs, ", "),
+			result.Recall,
+			result.ReciprocalRank)
+		if result.Error != "" {
+			fmt.Printf("  error: %s\n", result.Error)
+		}
+	}
+}
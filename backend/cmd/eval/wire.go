@@ -0,0 +1,30 @@
+//go:build wireinject
+// +build wireinject
+
+package main
+
+import (
+	"github.com/google/wire"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+func createApp() (*App, error) {
+	wire.Build(
+		wire.Struct(new(App), "*"),
+		wire.NewSet(
+			config.ProviderSet,
+			log.ProviderSet,
+			usecase.ProviderSet,
+		),
+	)
+	return &App{}, nil
+}
+
+type App struct {
+	Config      *config.Config
+	Logger      *log.Logger
+	EvalUsecase *usecase.EvalUsecase
+}
@@ -0,0 +1,55 @@
+// Code generated by Wire. DO NOT EDIT.
+
+//go:generate go run -mod=mod github.com/google/wire/cmd/wire
+//go:build !wireinject
+// +build !wireinject
+
+package main
+
+import (
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/log"
+	pg2 "github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/store/rag"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+// Injectors from wire.go:
+
+func createApp() (*App, error) {
+	configConfig, err := config.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	logger := log.NewLogger(configConfig)
+	db, err := pg.NewDB(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	evalRepository := pg2.NewEvalRepository(db)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
+	if err != nil {
+		return nil, err
+	}
+	knowledgeBaseRepository := pg2.NewKnowledgeBaseRepository(db, configConfig, logger, ragService)
+	modelRepository := pg2.NewModelRepository(db, logger)
+	conversationRepository := pg2.NewConversationRepository(db)
+	nodeRepository := pg2.NewNodeRepository(db, logger)
+	llmUsecase := usecase.NewLLMUsecase(configConfig, ragService, conversationRepository, knowledgeBaseRepository, nodeRepository, modelRepository, logger)
+	evalUsecase := usecase.NewEvalUsecase(evalRepository, knowledgeBaseRepository, modelRepository, llmUsecase, logger)
+	app := &App{
+		Config:      configConfig,
+		Logger:      logger,
+		EvalUsecase: evalUsecase,
+	}
+	return app, nil
+}
+
+// wire.go:
+
+type App struct {
+	Config      *config.Config
+	Logger      *log.Logger
+	EvalUsecase *usecase.EvalUsecase
+}
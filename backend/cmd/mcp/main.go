@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// serve the mcp server app over stdio with the api key of the app, e.g. in the mcp config of an agent
+//
+//	PANDA_WIKI_MCP_API_KEY=pw-xxx [PANDA_WIKI_MCP_PASSWORD=xxx] panda-wiki-mcp
+func main() {
+	// stdout is the protocol channel, logs go to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	app, err := createApp()
+	if err != nil {
+		panic(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	mcpApp, err := app.AppAPIKeyUsecase.Authenticate(ctx, os.Getenv("PANDA_WIKI_MCP_API_KEY"), domain.AppTypeMCPServer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid api key: %v\n", err)
+		os.Exit(1)
+	}
+	if err := app.MCPServer.ServeStdio(ctx, mcpApp, os.Getenv("PANDA_WIKI_MCP_PASSWORD"), os.Stdin, stdout); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "serve stdio failed: %v\n", err)
+		os.Exit(1)
+	}
+}
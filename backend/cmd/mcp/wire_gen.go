@@ -0,0 +1,58 @@
+// Code generated by Wire. DO NOT EDIT.
+
+//go:generate go run -mod=mod github.com/google/wire/cmd/wire
+//go:build !wireinject
+// +build !wireinject
+
+package main
+
+import (
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/handler/mcp"
+	"github.com/chaitin/panda-wiki/log"
+	pg2 "github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/store/rag"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+// Injectors from wire.go:
+
+func createApp() (*App, error) {
+	configConfig, err := config.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	logger := log.NewLogger(configConfig)
+	db, err := pg.NewDB(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
+	if err != nil {
+		return nil, err
+	}
+	nodeRepository := pg2.NewNodeRepository(db, logger)
+	knowledgeBaseRepository := pg2.NewKnowledgeBaseRepository(db, configConfig, logger, ragService)
+	mcpUsecase := usecase.NewMCPUsecase(ragService, nodeRepository, knowledgeBaseRepository, logger)
+	mcpServer := mcp.NewMCPServer(mcpUsecase, logger)
+	appAPIKeyRepository := pg2.NewAppAPIKeyRepository(db)
+	appRepository := pg2.NewAppRepository(db, logger)
+	appAPIKeyUsecase := usecase.NewAppAPIKeyUsecase(appAPIKeyRepository, appRepository, logger)
+	app := &App{
+		Config:           configConfig,
+		Logger:           logger,
+		MCPServer:        mcpServer,
+		AppAPIKeyUsecase: appAPIKeyUsecase,
+	}
+	return app, nil
+}
+
+// wire.go:
+
+type App struct {
+	Config           *config.Config
+	Logger           *log.Logger
+	MCPServer        *mcp.MCPServer
+	AppAPIKeyUsecase *usecase.AppAPIKeyUsecase
+}
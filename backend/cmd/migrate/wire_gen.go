@@ -40,7 +40,7 @@ func createApp() (*App, error) {
 		return nil, err
 	}
 	ragRepository := mq2.NewRAGRepository(mqProducer)
-	ragService, err := rag.NewRAGService(configConfig, logger)
+	ragService, err := rag.NewRAGService(configConfig, db, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -48,23 +48,31 @@ func createApp() (*App, error) {
 	conversationRepository := pg2.NewConversationRepository(db)
 	modelRepository := pg2.NewModelRepository(db, logger)
 	llmUsecase := usecase.NewLLMUsecase(configConfig, ragService, conversationRepository, knowledgeBaseRepository, nodeRepository, modelRepository, logger)
+	auditLogRepository := pg2.NewAuditLogRepository(db)
+	userRepository := pg2.NewUserRepository(db, logger)
+	auditUsecase := usecase.NewAuditUsecase(auditLogRepository, userRepository, logger)
 	minioClient, err := s3.NewMinioClient(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, ragRepository, knowledgeBaseRepository, llmUsecase, logger, minioClient, modelRepository)
+	nodeUsecase := usecase.NewNodeUsecase(nodeRepository, ragRepository, knowledgeBaseRepository, llmUsecase, auditUsecase, logger, minioClient, modelRepository)
 	cacheCache, err := cache.NewCache(configConfig)
 	if err != nil {
 		return nil, err
 	}
 	kbRepo := cache2.NewKBRepo(cacheCache)
-	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, ragRepository, ragService, kbRepo, logger, configConfig)
+	readerSessionRepo := cache2.NewReaderSessionRepo(cacheCache)
+	knowledgeBaseUsecase, err := usecase.NewKnowledgeBaseUsecase(knowledgeBaseRepository, nodeRepository, modelRepository, ragRepository, ragService, kbRepo, readerSessionRepo, auditUsecase, logger, configConfig)
 	if err != nil {
 		return nil, err
 	}
 	migrationNodeVersion := fns.NewMigrationNodeVersion(logger, nodeUsecase, knowledgeBaseUsecase, ragRepository)
+	migrationNodeReleaseSearchVector := fns.NewMigrationNodeReleaseSearchVector(logger, nodeRepository)
+	migrationHashSimpleAuthPassword := fns.NewMigrationHashSimpleAuthPassword(logger, kbRepo)
 	migrationFuncs := &migration.MigrationFuncs{
-		NodeMigration: migrationNodeVersion,
+		NodeMigration:         migrationNodeVersion,
+		SearchVectorMigration: migrationNodeReleaseSearchVector,
+		SimpleAuthMigration:   migrationHashSimpleAuthPassword,
 	}
 	manager, err := migration.NewManager(db, logger, migrationFuncs)
 	if err != nil {
@@ -17,6 +17,7 @@ type Config struct {
 	Redis         RedisConfig `mapstructure:"redis"`
 	Auth          AuthConfig  `mapstructure:"auth"`
 	S3            S3Config    `mapstructure:"s3"`
+	Quota         QuotaConfig `mapstructure:"quota"`
 	CaddyAPI      string      `mapstructure:"caddy_api"`
 	SubnetPrefix  string      `mapstructure:"subnet_prefix"`
 }
@@ -45,8 +46,9 @@ type NATSConfig struct {
 }
 
 type RAGConfig struct {
-	Provider string      `mapstructure:"provider"`
-	CTRAG    CTRAGConfig `mapstructure:"ct_rag"`
+	Provider string         `mapstructure:"provider"` // ct, pgvector
+	CTRAG    CTRAGConfig    `mapstructure:"ct_rag"`
+	PGVector PGVectorConfig `mapstructure:"pgvector"`
 }
 
 type CTRAGConfig struct {
@@ -54,20 +56,68 @@ type CTRAGConfig struct {
 	APIKey  string `mapstructure:"api_key"`
 }
 
+type PGVectorConfig struct {
+	ChunkSize    int `mapstructure:"chunk_size"`    // max runes per chunk
+	ChunkOverlap int `mapstructure:"chunk_overlap"` // runes shared by adjacent chunks
+	TopK         int `mapstructure:"top_k"`
+}
+
 type RedisConfig struct {
 	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
 }
 
 type AuthConfig struct {
-	Type string    `mapstructure:"type"`
-	JWT  JWTConfig `mapstructure:"jwt"`
+	Type string     `mapstructure:"type"` // jwt, oidc, ldap
+	JWT  JWTConfig  `mapstructure:"jwt"`
+	OIDC OIDCConfig `mapstructure:"oidc"`
+	LDAP LDAPConfig `mapstructure:"ldap"`
+	// DisableLocalLogin rejects the account password login, users must login by the identity provider
+	DisableLocalLogin bool `mapstructure:"disable_local_login"`
 }
 
 type JWTConfig struct {
 	Secret string `mapstructure:"secret"`
 }
 
+// OIDCConfig single sign-on of the admin console by the authorization code flow with pkce
+type OIDCConfig struct {
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // https://<host>/api/v1/user/oidc/callback
+	Scopes       []string `mapstructure:"scopes"`
+	AccountClaim string   `mapstructure:"account_claim"` // preferred_username by default, email and sub as fallbacks
+	GroupsClaim  string   `mapstructure:"groups_claim"`
+	// GroupRoles roles granted to the members of the idp groups, synced on every login
+	GroupRoles []GroupRoleConfig `mapstructure:"group_roles"`
+}
+
+// LDAPConfig login of the admin console by the accounts of ldap or active directory
+type LDAPConfig struct {
+	URL                string `mapstructure:"url"` // ldap://host:389 or ldaps://host:636
+	StartTLS           bool   `mapstructure:"start_tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	// BindDN service account to search users and groups, anonymous if empty
+	BindDN       string `mapstructure:"bind_dn"`
+	BindPassword string `mapstructure:"bind_password"`
+	UserBaseDN   string `mapstructure:"user_base_dn"`
+	UserFilter   string `mapstructure:"user_filter"`  // {account} is replaced by the login account, (sAMAccountName={account}) for ad
+	AccountAttr  string `mapstructure:"account_attr"` // attribute of the pandawiki account, the login account if missing
+	// GroupBaseDN searches the groups having the user as a member besides the memberOf attribute of the user
+	GroupBaseDN string `mapstructure:"group_base_dn"`
+	GroupFilter string `mapstructure:"group_filter"` // {dn} is replaced by the dn of the user
+	// GroupRoles roles granted to the members of the groups by cn or dn, synced on every login
+	GroupRoles []GroupRoleConfig `mapstructure:"group_roles"`
+}
+
+// GroupRoleConfig role of the members of an external group, the global role if kb id is empty
+type GroupRoleConfig struct {
+	Group string `mapstructure:"group"`
+	KBID  string `mapstructure:"kb_id"`
+	Role  string `mapstructure:"role"`
+}
+
 type S3Config struct {
 	Endpoint    string `mapstructure:"endpoint"`
 	AccessKey   string `mapstructure:"access_key"`
@@ -75,6 +125,13 @@ type S3Config struct {
 	MaxFileSize int64  `mapstructure:"max_file_size"`
 }
 
+// QuotaConfig default chat quotas, overridden by kb and app quota settings, 0 for unlimited
+type QuotaConfig struct {
+	IPRequestsPerMinute int   `mapstructure:"ip_requests_per_minute"`
+	AppDailyTokens      int64 `mapstructure:"app_daily_tokens"`
+	KBDailyTokens       int64 `mapstructure:"kb_daily_tokens"`
+}
+
 func NewConfig() (*Config, error) {
 	// set default config
 	SUBNET_PREFIX := os.Getenv("SUBNET_PREFIX")
@@ -106,6 +163,11 @@ func NewConfig() (*Config, error) {
 				BaseURL: "http://panda-wiki-rag:8080/api/v1",
 				APIKey:  "sk-1234567890",
 			},
+			PGVector: PGVectorConfig{
+				ChunkSize:    1024,
+				ChunkOverlap: 128,
+				TopK:         10,
+			},
 		},
 		Redis: RedisConfig{
 			Addr:     "panda-wiki-redis:6379",
@@ -114,6 +176,16 @@ func NewConfig() (*Config, error) {
 		Auth: AuthConfig{
 			Type: "jwt",
 			JWT:  JWTConfig{Secret: ""},
+			OIDC: OIDCConfig{
+				Scopes:       []string{"openid", "profile", "email", "groups"},
+				AccountClaim: "preferred_username",
+				GroupsClaim:  "groups",
+			},
+			LDAP: LDAPConfig{
+				UserFilter:  "(uid={account})",
+				AccountAttr: "uid",
+				GroupFilter: "(|(member={dn})(uniqueMember={dn}))",
+			},
 		},
 		S3: S3Config{
 			Endpoint:    "panda-wiki-minio:9000",
@@ -121,6 +193,11 @@ func NewConfig() (*Config, error) {
 			SecretKey:   "",
 			MaxFileSize: 20971520, // 20MB
 		},
+		Quota: QuotaConfig{
+			IPRequestsPerMinute: 20,
+			AppDailyTokens:      0,
+			KBDailyTokens:       0,
+		},
 		CaddyAPI:     "/app/run/caddy-admin.sock",
 		SubnetPrefix: "169.254.15",
 	}
@@ -163,6 +240,12 @@ func overrideWithEnv(c *Config) {
 	if env := os.Getenv("JWT_SECRET"); env != "" {
 		c.Auth.JWT.Secret = env
 	}
+	if env := os.Getenv("OIDC_CLIENT_SECRET"); env != "" {
+		c.Auth.OIDC.ClientSecret = env
+	}
+	if env := os.Getenv("LDAP_BIND_PASSWORD"); env != "" {
+		c.Auth.LDAP.BindPassword = env
+	}
 	if env := os.Getenv("S3_SECRET_KEY"); env != "" {
 		c.S3.SecretKey = env
 	}
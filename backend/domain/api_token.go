@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix prefix of the plaintext personal api tokens, only the sha256 hash is stored
+const APITokenPrefix = "pwt-"
+
+type APITokenScope string
+
+const (
+	APITokenScopeRead      APITokenScope = "read"       // read only requests of the admin api
+	APITokenScopeNodeWrite APITokenScope = "node_write" // create, update and import nodes
+	APITokenScopeRelease   APITokenScope = "release"    // publish releases of the knowledge base
+	APITokenScopeAdmin     APITokenScope = "admin"      // everything
+)
+
+// APIToken long-lived token of a user or a service account for the admin api, used by ci jobs and scripts
+type APIToken struct {
+	ID          string          `json:"id" gorm:"primaryKey"`
+	UserID      string          `json:"user_id" gorm:"index"`
+	Name        string          `json:"name"`
+	TokenHash   string          `json:"-" gorm:"uniqueIndex"`
+	TokenPrefix string          `json:"token_prefix"` // first characters of the token for display
+	Scopes      []APITokenScope `json:"scopes" gorm:"type:jsonb;serializer:json"`
+
+	ExpiresAt  *time.Time `json:"expires_at"` // never expires if null
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// writePathScopes scopes granting the write requests of the route paths besides admin
+var writePathScopes = []struct {
+	prefix string
+	scope  APITokenScope
+}{
+	{"/api/v1/node", APITokenScopeNodeWrite},
+	{"/api/v1/file/upload", APITokenScopeNodeWrite},
+	{"/api/v1/crawler/", APITokenScopeNodeWrite},
+	{"/api/v1/knowledge_base/release", APITokenScopeRelease},
+}
+
+// APITokenAllows reports whether the scopes grant the request of the method to the route path,
+// read allows the read only methods and write requests need a matching scope or admin
+func APITokenAllows(scopes []APITokenScope, method, path string) bool {
+	if slices.Contains(scopes, APITokenScopeAdmin) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(scopes, APITokenScopeRead)
+	}
+	for _, s := range writePathScopes {
+		if strings.HasPrefix(path, s.prefix) && slices.Contains(scopes, s.scope) {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateAPITokenReq struct {
+	Name   string          `json:"name" validate:"required,max=64"`
+	Scopes []APITokenScope `json:"scopes" validate:"required,min=1,dive,oneof=read node_write release admin"`
+	// ExpiresAt never expires if empty
+	ExpiresAt *time.Time `json:"expires_at"`
+	// UserID a service account to create the token for, the current user by default
+	UserID string `json:"user_id"`
+}
+
+type CreateAPITokenResp struct {
+	*APIToken
+	Token string `json:"token"` // plaintext token, only returned on creation
+}
+
+type GetAPITokenListReq struct {
+	UserID string `json:"user_id" query:"user_id"` // a service account, the current user by default
+}
+
+type DeleteAPITokenReq struct {
+	ID string `json:"id" query:"id" validate:"required"`
+}
+
+type CreateServiceAccountReq struct {
+	Account string `json:"account" validate:"required,max=64"`
+	// Role global role, empty to grant roles of kbs by memberships
+	Role Role `json:"role" validate:"omitempty,oneof=owner admin editor viewer"`
+}
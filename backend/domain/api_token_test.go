@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPITokenAllows(t *testing.T) {
+	tests := []struct {
+		scopes []APITokenScope
+		method string
+		path   string
+		allow  bool
+	}{
+		{[]APITokenScope{APITokenScopeRead}, http.MethodGet, "/api/v1/node/list", true},
+		{[]APITokenScope{APITokenScopeRead}, http.MethodPost, "/api/v1/node", false},
+		{[]APITokenScope{APITokenScopeNodeWrite}, http.MethodGet, "/api/v1/node/detail", false},
+		{[]APITokenScope{APITokenScopeNodeWrite}, http.MethodPut, "/api/v1/node/detail", true},
+		{[]APITokenScope{APITokenScopeNodeWrite}, http.MethodPost, "/api/v1/file/upload", true},
+		{[]APITokenScope{APITokenScopeNodeWrite}, http.MethodPost, "/api/v1/knowledge_base/release", false},
+		{[]APITokenScope{APITokenScopeRelease}, http.MethodPost, "/api/v1/knowledge_base/release", true},
+		{[]APITokenScope{APITokenScopeRelease, APITokenScopeNodeWrite}, http.MethodPut, "/api/v1/knowledge_base/detail", false},
+		{[]APITokenScope{APITokenScopeNodeWrite}, http.MethodPost, "/api/v1/user/token", false},
+		{[]APITokenScope{APITokenScopeAdmin}, http.MethodDelete, "/api/v1/user/delete", true},
+	}
+	for _, tt := range tests {
+		if allow := APITokenAllows(tt.scopes, tt.method, tt.path); allow != tt.allow {
+			t.Errorf("%v %s %s: expected %v, got %v", tt.scopes, tt.method, tt.path, tt.allow, allow)
+		}
+	}
+}
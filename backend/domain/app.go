@@ -15,6 +15,8 @@ const (
 	AppTypeWidget
 	AppTypeDingTalkBot
 	AppTypeFeishuBot
+	AppTypeOpenAIAPI
+	AppTypeMCPServer
 )
 
 var AppTypes = []AppType{
@@ -22,6 +24,8 @@ var AppTypes = []AppType{
 	AppTypeWidget,
 	AppTypeDingTalkBot,
 	AppTypeFeishuBot,
+	AppTypeOpenAIAPI,
+	AppTypeMCPServer,
 }
 
 type App struct {
@@ -66,6 +70,12 @@ type AppSettings struct {
 	CatalogSettings CatalogSettings `json:"catalog_settings"`
 	// footer settings
 	FooterSettings FooterSettings `json:"footer_settings"`
+	// prompt settings, override the knowledge base prompt settings
+	PromptSettings *PromptSettings `json:"prompt_settings,omitempty"`
+	// ordered chat models, override the knowledge base chat models
+	ChatModelIDs []string `json:"chat_model_ids,omitempty"`
+	// chat quotas of the app
+	QuotaSettings *QuotaSettings `json:"quota_settings,omitempty"`
 }
 
 type CatalogSettings struct {
@@ -148,6 +158,18 @@ type AppSettingsResp struct {
 	CatalogSettings CatalogSettings `json:"catalog_settings"`
 	// footer settings
 	FooterSettings FooterSettings `json:"footer_settings"`
+	// prompt settings, override the knowledge base prompt settings
+	PromptSettings *PromptSettings `json:"prompt_settings,omitempty"`
+	// ordered chat models, override the knowledge base chat models
+	ChatModelIDs []string `json:"chat_model_ids,omitempty"`
+	// chat quotas of the app
+	QuotaSettings *QuotaSettings `json:"quota_settings,omitempty"`
+}
+
+// HideSecrets clears the secrets of the bots for the members who can not manage the app
+func (s *AppSettingsResp) HideSecrets() {
+	s.DingTalkBotClientSecret = ""
+	s.FeishuBotAppSecret = ""
 }
 
 func (s *AppSettingsResp) Scan(value any) error {
@@ -169,7 +191,7 @@ type UpdateAppReq struct {
 
 type CreateAppReq struct {
 	Name string  `json:"name"`
-	Type AppType `json:"type" validate:"required,oneof=1 2 3 4"`
+	Type AppType `json:"type" validate:"required,oneof=1 2 3 4 5 6"`
 	Icon string  `json:"icon"`
 	KBID string  `json:"kb_id" validate:"required"`
 }
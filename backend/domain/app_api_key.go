@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// AppAPIKeyPrefix prefix of the plaintext api keys, only the sha256 hash is stored
+const AppAPIKeyPrefix = "pw-"
+
+// AppAPIKey key of the openai compatible api app or the mcp server app
+type AppAPIKey struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	KBID      string `json:"kb_id" gorm:"index"`
+	AppID     string `json:"app_id" gorm:"index"`
+	Name      string `json:"name"`
+	KeyHash   string `json:"-" gorm:"uniqueIndex"`
+	KeyPrefix string `json:"key_prefix"` // first characters of the key for display
+
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAppAPIKeyReq struct {
+	KBID    string  `json:"kb_id" validate:"required"`
+	Name    string  `json:"name" validate:"required,max=64"`
+	AppType AppType `json:"app_type" validate:"omitempty,oneof=5 6"` // openai api app by default
+}
+
+type CreateAppAPIKeyResp struct {
+	*AppAPIKey
+	Key string `json:"key"` // plaintext key, only returned on creation
+}
+
+type DeleteAppAPIKeyReq struct {
+	KBID string `json:"kb_id" query:"kb_id" validate:"required"`
+	ID   string `json:"id" query:"id" validate:"required"`
+}
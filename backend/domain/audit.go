@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionNodeDelete  AuditAction = "node.delete"
+	AuditActionNodePrivate AuditAction = "node.private"
+	AuditActionNodePublic  AuditAction = "node.public"
+
+	AuditActionKBUpdate AuditAction = "kb.update"
+
+	AuditActionAppUpdate AuditAction = "app.update"
+
+	AuditActionModelActivate AuditAction = "model.activate"
+
+	AuditActionKBMemberUpsert AuditAction = "kb_member.upsert"
+	AuditActionKBMemberDelete AuditAction = "kb_member.delete"
+
+	AuditActionUserCreate            AuditAction = "user.create"
+	AuditActionUserDelete            AuditAction = "user.delete"
+	AuditActionUserResetPassword     AuditAction = "user.reset_password"
+	AuditActionUserUpdateRole        AuditAction = "user.update_role"
+	AuditActionUserSyncRoles         AuditAction = "user.sync_roles" // roles synced from the groups of an identity provider
+	AuditActionUserEnableTwoFactor   AuditAction = "user.enable_2fa"
+	AuditActionUserDisableTwoFactor  AuditAction = "user.disable_2fa"
+	AuditActionUserResetTwoFactor    AuditAction = "user.reset_2fa"
+	AuditActionSecuritySettingUpdate AuditAction = "security_setting.update"
+)
+
+type AuditTargetType string
+
+const (
+	AuditTargetNode            AuditTargetType = "node"
+	AuditTargetKB              AuditTargetType = "knowledge_base"
+	AuditTargetApp             AuditTargetType = "app"
+	AuditTargetModel           AuditTargetType = "model"
+	AuditTargetUser            AuditTargetType = "user"
+	AuditTargetSecuritySetting AuditTargetType = "security_setting"
+)
+
+// AuditLog append only record of an administrative mutation, the table rejects updates and deletes
+type AuditLog struct {
+	ID int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+
+	ActorID      string `json:"actor_id" gorm:"index"` // empty for the system, e.g. group roles synced on login
+	ActorAccount string `json:"actor_account"`
+	APITokenID   string `json:"api_token_id,omitempty"` // the personal api token of the request
+	IP           string `json:"ip"`
+	UserAgent    string `json:"user_agent"`
+
+	Action     AuditAction     `json:"action" gorm:"index"`
+	TargetType AuditTargetType `json:"target_type"`
+	TargetID   string          `json:"target_id" gorm:"index"`
+	KBID       string          `json:"kb_id" gorm:"index"`
+
+	// Diff the changed fields by dotted json paths, secrets are redacted
+	Diff map[string]AuditChange `json:"diff" gorm:"type:jsonb;serializer:json"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditActor the authenticated user of the request, set in the context by the auth middleware
+type AuditActor struct {
+	UserID     string
+	APITokenID string
+	IP         string
+	UserAgent  string
+}
+
+type auditActorKey struct{}
+
+func WithAuditActor(ctx context.Context, actor *AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext nil for unauthenticated requests and background jobs
+func AuditActorFromContext(ctx context.Context) *AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(*AuditActor)
+	return actor
+}
+
+// AuditLogFilter filters of the audit log query and export, all optional
+type AuditLogFilter struct {
+	ActorID    string          `json:"actor_id" query:"actor_id"`
+	Action     AuditAction     `json:"action" query:"action"`
+	TargetType AuditTargetType `json:"target_type" query:"target_type"`
+	TargetID   string          `json:"target_id" query:"target_id"`
+	KBID       string          `json:"kb_id" query:"kb_id"`
+	// Start and End the range of created_at in rfc3339
+	Start *time.Time `json:"start" query:"start"`
+	End   *time.Time `json:"end" query:"end"`
+}
+
+type GetAuditLogListReq struct {
+	AuditLogFilter
+	Pager
+}
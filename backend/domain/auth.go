@@ -0,0 +1,32 @@
+package domain
+
+type UserSource string
+
+const (
+	UserSourceLocal UserSource = "local"
+	UserSourceOIDC  UserSource = "oidc"
+	UserSourceLDAP  UserSource = "ldap"
+)
+
+// ExternalUser an identity authenticated by an identity provider, provisioned as a user just in time
+type ExternalUser struct {
+	Source     UserSource
+	ExternalID string // unique id of the provider, issuer and subject for oidc, dn for ldap
+	Account    string
+	Email      string // verified email, empty if the provider does not verify it
+	Groups     []string
+}
+
+// OIDCLoginState kept between the redirect to the identity provider and the callback
+type OIDCLoginState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Redirect     string `json:"redirect"`
+	KBID         string `json:"kb_id,omitempty"` // the kb of the reader login, empty for the console login
+}
+
+type LoginConfigResp struct {
+	LocalLogin bool `json:"local_login"`
+	OIDC       bool `json:"oidc"`
+	LDAP       bool `json:"ldap"` // the account password login is checked against the directory
+}
@@ -1,15 +1,40 @@
 package domain
 
+type ChatAction string
+
+const (
+	// ChatActionRegenerate answers the user message again as a new assistant sibling
+	ChatActionRegenerate ChatAction = "regenerate"
+	// ChatActionEdit sends a new user sibling of the user message, which forks the branch
+	ChatActionEdit ChatAction = "edit"
+)
+
 type ChatRequest struct {
 	ConversationID string  `json:"conversation_id"`
-	Message        string  `json:"message" validate:"required"`
+	Message        string  `json:"message" validate:"required_unless=Action regenerate"`
 	Nonce          string  `json:"nonce"`
 	AppType        AppType `json:"app_type" validate:"required,oneof=1 2 3"`
 
+	// ParentID the message replied to, the latest message of the conversation by default,
+	// required once the conversation has branches
+	ParentID string `json:"parent_id"`
+	// Action regenerate or edit the user message of MessageID
+	Action    ChatAction `json:"action" validate:"omitempty,oneof=regenerate edit"`
+	MessageID string     `json:"message_id" validate:"required_with=Action"`
+
 	KBID  string `json:"-" validate:"required"`
 	AppID string `json:"-"`
 
+	// History previous messages sent by stateless clients, only used in the prompt and never saved,
+	// since the assistant messages are not answered by the kb
+	History []*ConversationMessage `json:"-"`
+
 	ModelInfo *Model `json:"-"`
 
 	RemoteIP string `json:"-"`
 }
+
+type StopChatReq struct {
+	ConversationID string `json:"conversation_id" validate:"required"`
+	Nonce          string `json:"nonce" validate:"required"`
+}
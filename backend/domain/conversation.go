@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -20,13 +21,28 @@ type Conversation struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type MessageStatus string
+
+const (
+	MessageStatusCompleted MessageStatus = "completed"
+	MessageStatusStopped   MessageStatus = "stopped" // stopped by the reader, the answer is partial
+	MessageStatusFailed    MessageStatus = "failed"
+)
+
 type ConversationMessage struct {
 	ID             string `json:"id" gorm:"primaryKey"`
 	ConversationID string `json:"conversation_id" gorm:"index"`
 	AppID          string `json:"app_id" gorm:"index"`
+	// ParentID the previous message of the branch, siblings are regenerated answers or edited questions
+	ParentID string `json:"parent_id" gorm:"index"`
 
 	Role    schema.RoleType `json:"role"`
 	Content string          `json:"content"`
+	Status  MessageStatus   `json:"status" gorm:"default:completed"`
+
+	// retrieval query rewritten from a follow up question, for auditing
+	RewrittenQuery string   `json:"rewritten_query,omitempty"`
+	SubQueries     []string `json:"sub_queries,omitempty" gorm:"type:jsonb;serializer:json"`
 
 	// model
 	Provider         ModelProvider `json:"provider"`
@@ -35,19 +51,52 @@ type ConversationMessage struct {
 	CompletionTokens int           `json:"completion_tokens" gorm:"default:0"`
 	TotalTokens      int           `json:"total_tokens" gorm:"default:0"`
 
+	// chunks fed into the prompt with their retrieval scores, for debugging
+	RetrievedChunks RetrievedChunks `json:"retrieved_chunks,omitempty" gorm:"type:jsonb"`
+
+	// reader feedback of the assistant answer
+	FeedbackScore   FeedbackScore `json:"feedback_score" gorm:"default:0"`
+	FeedbackReason  string        `json:"feedback_reason,omitempty"`
+	FeedbackComment string        `json:"feedback_comment,omitempty"`
+	FeedbackAt      *time.Time    `json:"feedback_at,omitempty"`
+
 	// stats
 	RemoteIP string `json:"remote_ip"`
 
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type FeedbackScore int8
+
+const (
+	FeedbackScoreNone    FeedbackScore = 0
+	FeedbackScoreLike    FeedbackScore = 1
+	FeedbackScoreDislike FeedbackScore = -1
+)
+
 type ConversationReference struct {
 	ConversationID string `json:"conversation_id" gorm:"index"`
+	MessageID      string `json:"message_id" gorm:"index"`
 	AppID          string `json:"app_id"`
 
 	NodeID string `json:"node_id"`
 	Name   string `json:"name"`
 	URL    string `json:"url"`
+
+	// citation number in the answer and the cited chunk
+	Number  int    `json:"number"`
+	ChunkID string `json:"chunk_id"`
+	Snippet string `json:"snippet"`
+}
+
+// Citation a document cited by the answer, mapped from the [[n](URL)] marker to the retrieved nodes
+type Citation struct {
+	Number   int    `json:"number"`
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	URL      string `json:"url"`
+	ChunkID  string `json:"chunk_id"`
+	Snippet  string `json:"snippet"`
 }
 
 type ConversationListReq struct {
@@ -58,6 +107,9 @@ type ConversationListReq struct {
 
 	RemoteIP *string `json:"remote_ip" query:"remote_ip"`
 
+	// only conversations with an answer of the feedback score, -1 for negatively rated
+	FeedbackScore *FeedbackScore `json:"feedback_score" query:"feedback_score"`
+
 	Pager
 }
 
@@ -70,9 +122,33 @@ type ConversationListItem struct {
 
 	IPAddress *IPAddress `json:"ip_address" gorm:"-"`
 
+	// feedback count of the answers
+	LikeCount    int `json:"like_count"`
+	DislikeCount int `json:"dislike_count"`
+
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ConversationBranch the messages from the root to the leaf message by parent ids
+func ConversationBranch(messages []*ConversationMessage, leafID string) []*ConversationMessage {
+	messageMap := make(map[string]*ConversationMessage, len(messages))
+	for _, message := range messages {
+		messageMap[message.ID] = message
+	}
+	branch := make([]*ConversationMessage, 0)
+	for id := leafID; id != ""; {
+		message, ok := messageMap[id]
+		// stop on missing parent or cycle
+		if !ok || len(branch) == len(messages) {
+			break
+		}
+		branch = append(branch, message)
+		id = message.ParentID
+	}
+	slices.Reverse(branch)
+	return branch
+}
+
 type ConversationDetailResp struct {
 	ID       string `json:"id"`
 	AppID    string `json:"app_id"`
@@ -86,3 +162,12 @@ type ConversationDetailResp struct {
 
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type FeedbackReq struct {
+	ConversationID string        `json:"conversation_id" validate:"required"`
+	Nonce          string        `json:"nonce" validate:"required"`
+	MessageID      string        `json:"message_id" validate:"required"`
+	Score          FeedbackScore `json:"score" validate:"oneof=1 -1"`
+	Reason         string        `json:"reason" validate:"omitempty,oneof=inaccurate incomplete irrelevant outdated other"`
+	Comment        string        `json:"comment" validate:"max=1000"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestConversationBranch(t *testing.T) {
+	// q1 -> a1 -> q2 -> a2
+	//    -> a1'(regenerated)
+	//          -> q2'(edited) -> a3
+	messages := []*ConversationMessage{
+		{ID: "q1"},
+		{ID: "a1", ParentID: "q1"},
+		{ID: "q2", ParentID: "a1"},
+		{ID: "a2", ParentID: "q2"},
+		{ID: "a1'", ParentID: "q1"},
+		{ID: "q2'", ParentID: "a1"},
+		{ID: "a3", ParentID: "q2'"},
+	}
+	ids := func(branch []*ConversationMessage) []string {
+		return lo.Map(branch, func(m *ConversationMessage, _ int) string { return m.ID })
+	}
+	if branch := ids(ConversationBranch(messages, "a3")); !slices.Equal(branch, []string{"q1", "a1", "q2'", "a3"}) {
+		t.Errorf("unexpected branch %v", branch)
+	}
+	if branch := ids(ConversationBranch(messages, "a1'")); !slices.Equal(branch, []string{"q1", "a1'"}) {
+		t.Errorf("unexpected branch %v", branch)
+	}
+	if branch := ConversationBranch(messages, "missing"); len(branch) != 0 {
+		t.Errorf("missing leaf should be empty, got %v", ids(branch))
+	}
+	cycle := []*ConversationMessage{{ID: "a", ParentID: "b"}, {ID: "b", ParentID: "a"}}
+	if branch := ConversationBranch(cycle, "a"); len(branch) != 2 {
+		t.Errorf("cycle should stop, got %v", ids(branch))
+	}
+}
@@ -4,6 +4,8 @@ import "errors"
 
 var ErrModelNotConfigured = errors.New("model not configured")
 
+var ErrBoundChatModelsDeleted = errors.New("bound chat models are all deleted")
+
 var ErrPortHostAlreadyExists = errors.New("port and host already exists")
 
 var ErrSyncCaddyConfigFailed = errors.New("failed to sync caddy config")
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// table: eval_sets
+type EvalSet struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	KBID        string    `json:"kb_id" gorm:"index"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Cases       EvalCases `json:"cases" gorm:"type:jsonb"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EvalCase golden question with the nodes expected to be retrieved
+type EvalCase struct {
+	Question        string   `json:"question" validate:"required"`
+	ExpectedNodeIDs []string `json:"expected_node_ids" validate:"required,min=1"`
+}
+
+type EvalCases []*EvalCase
+
+func (c *EvalCases) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid eval cases value type:", value))
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c EvalCases) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// table: eval_reports
+type EvalReport struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	KBID      string `json:"kb_id" gorm:"index"`
+	SetID     string `json:"set_id" gorm:"index"`
+	K         int    `json:"k"`
+	CaseCount int    `json:"case_count"`
+
+	RecallAtK float64 `json:"recall_at_k"`
+	MRR       float64 `json:"mrr"`
+	HitRate   float64 `json:"hit_rate"`
+
+	// retrieval settings and models used by the run
+	RetrievalSettings RetrievalSettings `json:"retrieval_settings" gorm:"type:jsonb"`
+	EmbeddingModel    string            `json:"embedding_model"`
+	RerankModel       string            `json:"rerank_model"`
+
+	Results EvalCaseResults `json:"results,omitempty" gorm:"type:jsonb"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EvalCaseResult struct {
+	Question         string   `json:"question"`
+	ExpectedNodeIDs  []string `json:"expected_node_ids"`
+	RetrievedNodeIDs []string `json:"retrieved_node_ids"`
+	Recall           float64  `json:"recall"`
+	ReciprocalRank   float64  `json:"reciprocal_rank"`
+	Hit              bool     `json:"hit"`
+	Error            string   `json:"error,omitempty"`
+}
+
+type EvalCaseResults []*EvalCaseResult
+
+func (r *EvalCaseResults) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid eval case results value type:", value))
+	}
+	return json.Unmarshal(bytes, r)
+}
+
+func (r EvalCaseResults) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+type CreateEvalSetReq struct {
+	KBID        string      `json:"kb_id" validate:"required"`
+	Name        string      `json:"name" validate:"required"`
+	Description string      `json:"description"`
+	Cases       []*EvalCase `json:"cases" validate:"dive"`
+}
+
+type UpdateEvalSetReq struct {
+	ID          string      `json:"id" validate:"required"`
+	Name        *string     `json:"name"`
+	Description *string     `json:"description"`
+	Cases       []*EvalCase `json:"cases" validate:"omitempty,dive"`
+}
+
+type EvalSetListItem struct {
+	ID          string    `json:"id"`
+	KBID        string    `json:"kb_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CaseCount   int       `json:"case_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RunEvalSetReq struct {
+	SetID string `json:"set_id" validate:"required"`
+	K     int    `json:"k" validate:"gte=0"` // 0 to use top k of the kb
+}
+
+type GetEvalReportListReq struct {
+	SetID string `json:"set_id" query:"set_id" validate:"required"`
+	Pager
+}
+
+type EvalReportListItem struct {
+	ID        string    `json:"id"`
+	KBID      string    `json:"kb_id"`
+	SetID     string    `json:"set_id"`
+	K         int       `json:"k"`
+	CaseCount int       `json:"case_count"`
+	RecallAtK float64   `json:"recall_at_k"`
+	MRR       float64   `json:"mrr"`
+	HitRate   float64   `json:"hit_rate"`
+	CreatedAt time.Time `json:"created_at"`
+
+	EmbeddingModel string `json:"embedding_model"`
+	RerankModel    string `json:"rerank_model"`
+}
+
+type GetEvalReportListResp = PaginatedResult[[]*EvalReportListItem]
@@ -18,6 +18,18 @@ type KnowledgeBase struct {
 	// public info for public access
 	AccessSettings AccessSettings `json:"access_settings" gorm:"type:jsonb"`
 
+	// hybrid retrieval settings
+	RetrievalSettings RetrievalSettings `json:"retrieval_settings" gorm:"type:jsonb"`
+
+	// answer prompt settings
+	PromptSettings PromptSettings `json:"prompt_settings" gorm:"type:jsonb"`
+
+	// ordered chat models, the next one is used when the previous one fails, empty to use the active chat model
+	ChatModelIDs []string `json:"chat_model_ids" gorm:"type:jsonb;serializer:json"`
+
+	// chat quotas
+	QuotaSettings QuotaSettings `json:"quota_settings" gorm:"type:jsonb"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -31,11 +43,15 @@ type AccessSettings struct {
 	BaseURL    string   `json:"base_url"`
 
 	SimpleAuth SimpleAuth `json:"simple_auth"`
+	ReaderAuth ReaderAuth `json:"reader_auth"`
 }
 
+// SimpleAuth the password of the readers, only the bcrypt hash is stored
 type SimpleAuth struct {
-	Enabled  bool   `json:"enabled"`
-	Password string `json:"password"`
+	Enabled bool `json:"enabled"`
+	// Password the new password of the update request, empty to keep the current one
+	Password     string `json:"password,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
 }
 
 func (s *AccessSettings) Scan(value any) error {
@@ -64,6 +80,11 @@ type UpdateKnowledgeBaseReq struct {
 	ID             string          `json:"id" validate:"required"`
 	Name           *string         `json:"name"`
 	AccessSettings *AccessSettings `json:"access_settings"`
+
+	RetrievalSettings *RetrievalSettings `json:"retrieval_settings"`
+	PromptSettings    *PromptSettings    `json:"prompt_settings"`
+	ChatModelIDs      []string           `json:"chat_model_ids"`
+	QuotaSettings     *QuotaSettings     `json:"quota_settings"`
 }
 
 type KnowledgeBaseListItem struct {
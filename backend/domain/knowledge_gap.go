@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+type UnansweredReason string
+
+const (
+	UnansweredReasonNoChunks UnansweredReason = "no_chunks" // retrieval returned nothing
+	UnansweredReasonRefusal  UnansweredReason = "refusal"   // answer matches the refusal text
+)
+
+// table: unanswered_questions
+type UnansweredQuestion struct {
+	ID             string           `json:"id" gorm:"primaryKey"`
+	KBID           string           `json:"kb_id" gorm:"index"`
+	AppID          string           `json:"app_id"`
+	ConversationID string           `json:"conversation_id"`
+	MessageID      string           `json:"message_id"` // assistant message
+	Question       string           `json:"question"`
+	Reason         UnansweredReason `json:"reason"`
+	NodeID         string           `json:"node_id"` // draft node created for the question
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type KnowledgeGapReportReq struct {
+	KBID            string `json:"kb_id" query:"kb_id" validate:"required"`
+	Days            int    `json:"days" query:"days" validate:"gte=0,lte=365"` // 0 for the last 30 days
+	IncludeResolved bool   `json:"include_resolved" query:"include_resolved"`  // include questions with draft nodes
+}
+
+// KnowledgeGapCluster similar unanswered questions
+type KnowledgeGapCluster struct {
+	Question    string                   `json:"question"` // the latest question of the cluster
+	Count       int                      `json:"count"`
+	QuestionIDs []string                 `json:"question_ids"`
+	Samples     []string                 `json:"samples"` // distinct questions of the cluster
+	Reasons     map[UnansweredReason]int `json:"reasons"`
+	NodeIDs     []string                 `json:"node_ids"` // draft nodes already created
+	LastAskedAt time.Time                `json:"last_asked_at"`
+}
+
+type KnowledgeGapReport struct {
+	KBID     string                 `json:"kb_id"`
+	Since    time.Time              `json:"since"`
+	Total    int                    `json:"total"`
+	Clusters []*KnowledgeGapCluster `json:"clusters"`
+}
+
+type CreateGapDraftNodeReq struct {
+	KBID        string   `json:"kb_id" validate:"required"`
+	QuestionIDs []string `json:"question_ids" validate:"required,min=1"`
+	Name        string   `json:"name"` // default to the latest question
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+const (
+	MCPServerName = "panda-wiki"
+
+	MCPToolSearchKB  = "search_kb"
+	MCPToolListNodes = "list_nodes"
+	MCPToolGetNode   = "get_node"
+
+	MCPDefaultSearchTopK = 10
+	MCPMaxSearchTopK     = 50
+)
+
+// MCPSearchResult a chunk of a public document matched by search_kb
+type MCPSearchResult struct {
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	URL      string `json:"url"`
+	Content  string `json:"content"`
+}
+
+// MCPNode a public document or folder listed by list_nodes
+type MCPNode struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Type     NodeType `json:"type"`
+	ParentID string   `json:"parent_id"`
+	Summary  string   `json:"summary,omitempty"`
+	URL      string   `json:"url"`
+}
+
+// MCPNodeDetail a public document read by get_node, the content is markdown
+type MCPNodeDetail struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -36,7 +36,7 @@ type Model struct {
 	APIHeader  string        `json:"api_header"`
 	BaseURL    string        `json:"base_url"`
 	APIVersion string        `json:"api_version"` // for azure openai
-	Type       ModelType     `json:"type" gorm:"default:chat;uniqueIndex"`
+	Type       ModelType     `json:"type" gorm:"default:chat;index"`
 
 	IsActive bool `json:"is_active" gorm:"default:false"`
 
@@ -44,6 +44,10 @@ type Model struct {
 	CompletionTokens uint64 `json:"completion_tokens" gorm:"default:0"`
 	TotalTokens      uint64 `json:"total_tokens" gorm:"default:0"`
 
+	// citations of the answers, unsupported ones are not among the retrieved documents
+	CitationCount            uint64 `json:"citation_count" gorm:"default:0"`
+	UnsupportedCitationCount uint64 `json:"unsupported_citation_count" gorm:"default:0"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -61,6 +65,15 @@ type ModelListItem struct {
 	PromptTokens     uint64 `json:"prompt_tokens"`
 	CompletionTokens uint64 `json:"completion_tokens"`
 	TotalTokens      uint64 `json:"total_tokens"`
+
+	CitationCount            uint64 `json:"citation_count"`
+	UnsupportedCitationCount uint64 `json:"unsupported_citation_count"`
+}
+
+// HideSecrets clears the credentials of the provider for the users who can not manage the models
+func (m *ModelListItem) HideSecrets() {
+	m.APIKey = ""
+	m.APIHeader = ""
 }
 
 type ModelDetailResp struct {
@@ -132,13 +132,19 @@ type NodeContentChunk struct {
 	Seq     uint   `json:"seq"`
 	Name    string `json:"name"`
 	Content string `json:"content"`
+
+	// retrieval scores
+	VectorRank  int      `json:"vector_rank,omitempty"`
+	RerankScore *float64 `json:"rerank_score,omitempty"`
 }
 
 type RankedNodeChunks struct {
-	NodeID      string
-	NodeName    string
-	NodeSummary string
-	Chunks      []*NodeContentChunk
+	NodeID      string              `json:"node_id"`
+	NodeName    string              `json:"node_name"`
+	NodeSummary string              `json:"node_summary"`
+	Chunks      []*NodeContentChunk `json:"chunks"`
+	Score       float64             `json:"score"`        // fused retrieval score, replaced by the best rerank score if reranked
+	KeywordRank int                 `json:"keyword_rank"` // rank in full text search, 0 if not hit
 }
 
 func (n *RankedNodeChunks) GetURL(baseURL string) string {
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	OpenAIObjectList                = "list"
+	OpenAIObjectModel               = "model"
+	OpenAIObjectChatCompletion      = "chat.completion"
+	OpenAIObjectChatCompletionChunk = "chat.completion.chunk"
+
+	OpenAIModelOwner = "panda-wiki"
+)
+
+// OpenAIChatCompletionReq openai compatible chat completion request, the model is the knowledge base id or name
+type OpenAIChatCompletionReq struct {
+	Model    string           `json:"model" validate:"required"`
+	Messages []*OpenAIMessage `json:"messages" validate:"required,min=1,dive"`
+	Stream   bool             `json:"stream"`
+	User     string           `json:"user"`
+}
+
+type OpenAIMessage struct {
+	Role    string               `json:"role" validate:"required,oneof=system developer user assistant tool function"`
+	Content OpenAIMessageContent `json:"content"`
+}
+
+// OpenAIMessageContent content of a message, either a string or an array of content parts
+type OpenAIMessageContent string
+
+func (c *OpenAIMessageContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = OpenAIMessageContent(text)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		// only text parts are supported
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = OpenAIMessageContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+type OpenAIChatCompletionResp struct {
+	ID      string          `json:"id"`
+	Object  string          `json:"object"`
+	Created int64           `json:"created"`
+	Model   string          `json:"model"`
+	Choices []*OpenAIChoice `json:"choices"`
+	// vendor extension
+	PandaWiki *OpenAIPandaWikiExtension `json:"x_pandawiki,omitempty"`
+}
+
+type OpenAIChoice struct {
+	Index        int                `json:"index"`
+	Message      *OpenAIRespMessage `json:"message,omitempty"`
+	Delta        *OpenAIRespMessage `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+type OpenAIRespMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+// OpenAIPandaWikiExtension the conversation and the documents cited by the answer
+type OpenAIPandaWikiExtension struct {
+	ConversationID string      `json:"conversation_id,omitempty"`
+	MessageID      string      `json:"message_id,omitempty"`
+	Citations      []*Citation `json:"citations"`
+}
+
+type OpenAIModelListResp struct {
+	Object string         `json:"object"`
+	Data   []*OpenAIModel `json:"data"`
+}
+
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type OpenAIErrorResp struct {
+	Error OpenAIError `json:"error"`
+}
+
+type OpenAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOpenAIMessageContent(t *testing.T) {
+	var req OpenAIChatCompletionReq
+	data := `{"model":"kb","messages":[
+		{"role":"system","content":"be short"},
+		{"role":"user","content":[{"type":"text","text":"hello"},{"type":"image_url","image_url":{"url":"x"}},{"type":"text","text":"world"}]}
+	]}`
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if req.Messages[0].Content != "be short" {
+		t.Errorf("unexpected string content %q", req.Messages[0].Content)
+	}
+	if req.Messages[1].Content != "hello\nworld" {
+		t.Errorf("unexpected content parts %q", req.Messages[1].Content)
+	}
+	if err := json.Unmarshal([]byte(`{"role":"user","content":1}`), &OpenAIMessage{}); err == nil {
+		t.Errorf("invalid content should fail")
+	}
+}
@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/schema"
+)
+
+type CitationStyle string
+
+const (
+	CitationStyleInline CitationStyle = "inline" // inline markers and reference list
+	CitationStyleList   CitationStyle = "list"   // reference list only
+	CitationStyleNone   CitationStyle = "none"   // no citation
+)
+
+const (
+	DefaultRefusalText = "抱歉，我当前的知识不足以回答这个问题"
+
+	MaxPromptTemplateLen = 8000
+	MaxRefusalTextLen    = 200
+	MaxAnswerLanguageLen = 32
+)
+
+var citationRules = map[CitationStyle]string{
+	CitationStyleInline: `如果回答的内容引用了文档，请使用内联引用格式标注回答内容的来源：
+	- 你需要给回答中引用的相关文档添加唯一序号，序号从1开始依次递增，跟回答无关的文档不添加序号
+	- 句号前放置引用标记
+	- 引用使用格式 [[文档序号](URL)]
+	- 如果多个不同文档支持同一观点，使用组合引用：[[文档序号](URL1)],[[文档序号](URL2)],[[文档序号](URLN)]
+  回答结束后，如果有引用列表则按照序号输出，格式如下，没有则不输出
+	---
+	### 引用列表
+	> [1]. [文档标题1](URL1)
+	> [2]. [文档标题2](URL2)
+	> ...
+	> [N]. [文档标题N](URLN)
+	---`,
+	CitationStyleList: `回答中不要添加内联引用标记，回答结束后，如果引用了文档则按照序号输出引用列表，格式如下，没有则不输出
+	---
+	### 引用列表
+	> [1]. [文档标题1](URL1)
+	> [2]. [文档标题2](URL2)
+	> ...
+	> [N]. [文档标题N](URLN)
+	---`,
+	CitationStyleNone: `回答中不要添加引用标记和引用列表`,
+}
+
+// PromptSettings answer prompt settings of a knowledge base, apps may override part of them
+type PromptSettings struct {
+	// go template of the system prompt, empty to use the default one,
+	// variables: see TemplateVars
+	SystemPrompt  string        `json:"system_prompt,omitempty"`
+	Language      string        `json:"language,omitempty"`       // answer language, empty to follow the question
+	RefusalText   string        `json:"refusal_text,omitempty"`   // answer when documents are not enough
+	CitationStyle CitationStyle `json:"citation_style,omitempty"` // inline, list or none, default inline
+	MaxDocs       int           `json:"max_docs,omitempty"`       // max documents fed into the prompt, 0 for no limit
+}
+
+func (s *PromptSettings) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid prompt settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s PromptSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s PromptSettings) Validate() error {
+	if s.MaxDocs < 0 {
+		return errors.New("max docs must not be negative")
+	}
+	switch s.CitationStyle {
+	case "", CitationStyleInline, CitationStyleList, CitationStyleNone:
+	default:
+		return fmt.Errorf("invalid citation style: %s", s.CitationStyle)
+	}
+	if utf8.RuneCountInString(s.RefusalText) > MaxRefusalTextLen {
+		return fmt.Errorf("refusal text must not exceed %d characters", MaxRefusalTextLen)
+	}
+	if utf8.RuneCountInString(s.Language) > MaxAnswerLanguageLen {
+		return fmt.Errorf("language must not exceed %d characters", MaxAnswerLanguageLen)
+	}
+	if utf8.RuneCountInString(s.SystemPrompt) > MaxPromptTemplateLen {
+		return fmt.Errorf("system prompt must not exceed %d characters", MaxPromptTemplateLen)
+	}
+	// render with sample variables to catch syntax errors and unknown variables
+	vars := s.TemplateVars("知识库", "示例问题", "<document>\nID: 1\n标题: 示例文档\nURL: \n内容:\n示例内容\n</document>")
+	if _, err := s.FormatMessages(context.Background(), vars); err != nil {
+		return fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	return nil
+}
+
+// Merge returns the settings overridden by the non empty fields of override
+func (s PromptSettings) Merge(override *PromptSettings) PromptSettings {
+	if override == nil {
+		return s
+	}
+	if override.SystemPrompt != "" {
+		s.SystemPrompt = override.SystemPrompt
+	}
+	if override.Language != "" {
+		s.Language = override.Language
+	}
+	if override.RefusalText != "" {
+		s.RefusalText = override.RefusalText
+	}
+	if override.CitationStyle != "" {
+		s.CitationStyle = override.CitationStyle
+	}
+	if override.MaxDocs > 0 {
+		s.MaxDocs = override.MaxDocs
+	}
+	return s
+}
+
+func (s PromptSettings) GetSystemPrompt() string {
+	if s.SystemPrompt != "" {
+		return s.SystemPrompt
+	}
+	return SystemPrompt
+}
+
+func (s PromptSettings) GetRefusalText() string {
+	if s.RefusalText != "" {
+		return s.RefusalText
+	}
+	return DefaultRefusalText
+}
+
+func (s PromptSettings) GetCitationStyle() CitationStyle {
+	if s.CitationStyle != "" {
+		return s.CitationStyle
+	}
+	return CitationStyleInline
+}
+
+// LimitDocs keeps the first max docs nodes
+func (s PromptSettings) LimitDocs(nodes []*RankedNodeChunks) []*RankedNodeChunks {
+	if s.MaxDocs > 0 && len(nodes) > s.MaxDocs {
+		return nodes[:s.MaxDocs]
+	}
+	return nodes
+}
+
+// TemplateVars variables available in the system prompt and the user question templates
+func (s PromptSettings) TemplateVars(kbName, question, documents string) map[string]any {
+	citationStyle := s.GetCitationStyle()
+	return map[string]any{
+		"CurrentDate":   time.Now().Format("2006-01-02"),
+		"KBName":        kbName,
+		"Question":      question,
+		"Documents":     documents,
+		"Language":      s.Language,
+		"RefusalText":   s.GetRefusalText(),
+		"CitationStyle": string(citationStyle),
+		"CitationRule":  citationRules[citationStyle],
+	}
+}
+
+// FormatMessages renders the system prompt and the user question through the eino go template
+func (s PromptSettings) FormatMessages(ctx context.Context, vars map[string]any) ([]*schema.Message, error) {
+	template := prompt.FromMessages(schema.GoTemplate,
+		schema.SystemMessage(s.GetSystemPrompt()),
+		schema.UserMessage(UserQuestionFormatter),
+	)
+	return template.Format(ctx, vars)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPromptSettingsValidate(t *testing.T) {
+	valid := []PromptSettings{
+		{},
+		{SystemPrompt: "你是{{.KBName}}的助手，使用{{.Language}}回答。{{.CitationRule}}", Language: "English", CitationStyle: CitationStyleNone},
+	}
+	for _, settings := range valid {
+		if err := settings.Validate(); err != nil {
+			t.Errorf("settings %+v should be valid, got %v", settings, err)
+		}
+	}
+	invalid := []PromptSettings{
+		{SystemPrompt: "{{.KBName"},
+		{SystemPrompt: "{{.Unknown}}"},
+		{CitationStyle: "footnote"},
+		{MaxDocs: -1},
+	}
+	for _, settings := range invalid {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("settings %+v should be invalid", settings)
+		}
+	}
+}
+
+func TestPromptSettingsFormatMessages(t *testing.T) {
+	settings := PromptSettings{RefusalText: "Sorry, I don't know."}.Merge(&PromptSettings{Language: "English", MaxDocs: 1})
+	messages, err := settings.FormatMessages(context.Background(), settings.TemplateVars("kb", "q", "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	system := messages[0].Content
+	if !strings.Contains(system, "Sorry, I don't know.") || !strings.Contains(system, "请使用English回答") || !strings.Contains(system, "[[文档序号](URL)]") {
+		t.Errorf("unexpected system prompt %s", system)
+	}
+	if len(settings.LimitDocs([]*RankedNodeChunks{{}, {}})) != 1 {
+		t.Errorf("docs should be limited to %d", settings.MaxDocs)
+	}
+}
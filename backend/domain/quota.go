@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type QuotaScope string
+
+const (
+	QuotaScopeIP  QuotaScope = "ip"
+	QuotaScopeApp QuotaScope = "app"
+	QuotaScopeKB  QuotaScope = "kb"
+)
+
+// QuotaSettings chat quotas of a knowledge base or an app, nil to use the default, 0 for unlimited
+type QuotaSettings struct {
+	// requests per minute of a remote ip, the kb one is the default of its apps
+	IPRequestsPerMinute *int `json:"ip_requests_per_minute"`
+	// daily token budget of the kb or the app
+	DailyTokens *int64 `json:"daily_tokens"`
+}
+
+func (s *QuotaSettings) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid quota settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s QuotaSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s QuotaSettings) Validate() error {
+	if s.IPRequestsPerMinute != nil && *s.IPRequestsPerMinute < 0 {
+		return errors.New("ip requests per minute must not be negative")
+	}
+	if s.DailyTokens != nil && *s.DailyTokens < 0 {
+		return errors.New("daily tokens must not be negative")
+	}
+	return nil
+}
+
+// QuotaExceeded detail of the exceeded quota, sent with the sse error event
+type QuotaExceeded struct {
+	Scope      QuotaScope `json:"scope"`
+	Limit      int64      `json:"limit"`
+	Used       int64      `json:"used"`
+	RetryAfter int        `json:"retry_after"` // seconds until the quota resets
+}
+
+func (e *QuotaExceeded) Message() string {
+	switch e.Scope {
+	case QuotaScopeIP:
+		return fmt.Sprintf("请求过于频繁，请 %d 秒后再试", e.RetryAfter)
+	case QuotaScopeApp:
+		return "当前应用今日的问答额度已用完，请明天再试"
+	default:
+		return "当前知识库今日的问答额度已用完，请明天再试"
+	}
+}
+
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"` // 0 for unlimited
+}
+
+type AppQuotaUsage struct {
+	AppID string  `json:"app_id"`
+	Name  string  `json:"name"`
+	Type  AppType `json:"type"`
+
+	// daily tokens
+	Tokens QuotaUsage `json:"tokens"`
+	// requests of the current minute from all ips, limit is per ip
+	Requests QuotaUsage `json:"requests"`
+}
+
+type QuotaUsageResp struct {
+	KBID   string           `json:"kb_id"`
+	Date   string           `json:"date"`
+	Tokens QuotaUsage       `json:"tokens"`
+	Apps   []*AppQuotaUsage `json:"apps"`
+}
@@ -0,0 +1,91 @@
+package domain
+
+import "time"
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"  // everything, including deleting the kb and granting admins
+	RoleAdmin  Role = "admin"  // settings, apps, keys, evals and members of the kb
+	RoleEditor Role = "editor" // nodes, files and releases of the kb
+	RoleViewer Role = "viewer" // read only
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// Allows reports whether the role includes the permissions of the required role
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] > 0 && roleRanks[r] >= roleRanks[required]
+}
+
+// Max the higher of the two roles, the empty role is the lowest
+func (r Role) Max(other Role) Role {
+	if roleRanks[other] > roleRanks[r] {
+		return other
+	}
+	return r
+}
+
+// KBMember role of a user in a knowledge base, the global role of the user applies to all knowledge bases
+// table: kb_members
+type KBMember struct {
+	KBID      string    `json:"kb_id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;index"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type KBMemberListItem struct {
+	UserID    string    `json:"user_id"`
+	Account   string    `json:"account"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GetKBMemberListReq struct {
+	KBID string `json:"kb_id" query:"kb_id" validate:"required"`
+}
+
+type UpsertKBMemberReq struct {
+	KBID   string `json:"kb_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+	Role   Role   `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+type DeleteKBMemberReq struct {
+	KBID   string `json:"kb_id" query:"kb_id" validate:"required"`
+	UserID string `json:"user_id" query:"user_id" validate:"required"`
+}
+
+type UpdateUserRoleReq struct {
+	UserID string `json:"user_id" validate:"required"`
+	// Role global role, empty to only access the kbs of the memberships
+	Role Role `json:"role" validate:"omitempty,oneof=owner admin editor viewer"`
+}
+
+// PermissionTarget where the knowledge base of a request comes from
+type PermissionTarget string
+
+const (
+	PermissionTargetGlobal       PermissionTarget = "global"       // the global role of the user
+	PermissionTargetAny          PermissionTarget = "any"          // the global role or the role in any kb
+	PermissionTargetSelf         PermissionTarget = "self"         // any authenticated user, checked by the handler
+	PermissionTargetKB           PermissionTarget = "kb"           // kb_id of the query, form or json body
+	PermissionTargetKBByID       PermissionTarget = "kb_by_id"     // id of the query or json body is the kb id
+	PermissionTargetNode         PermissionTarget = "node"         // kb of the node of the id
+	PermissionTargetApp          PermissionTarget = "app"          // kb of the app of the id
+	PermissionTargetConversation PermissionTarget = "conversation" // kb of the conversation of the id
+	PermissionTargetEvalSet      PermissionTarget = "eval_set"     // kb of the eval set of the id or set_id
+	PermissionTargetEvalReport   PermissionTarget = "eval_report"  // kb of the eval report of the id
+)
+
+// Permission the role required by a route
+type Permission struct {
+	Role   Role
+	Target PermissionTarget
+}
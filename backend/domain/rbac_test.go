@@ -0,0 +1,15 @@
+package domain
+
+import "testing"
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleOwner.Allows(RoleAdmin) || !RoleEditor.Allows(RoleViewer) || !RoleViewer.Allows(RoleViewer) {
+		t.Error("higher roles should include the lower ones")
+	}
+	if RoleEditor.Allows(RoleAdmin) || Role("").Allows(RoleViewer) || Role("root").Allows(RoleViewer) {
+		t.Error("lower, empty and unknown roles should be denied")
+	}
+	if Role("").Max(RoleEditor) != RoleEditor || RoleAdmin.Max(RoleViewer) != RoleAdmin {
+		t.Error("max should pick the higher role")
+	}
+}
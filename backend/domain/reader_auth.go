@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ReaderSessionCookie the cookie of the reader session of the published wiki
+const ReaderSessionCookie = "pw_reader_session"
+
+type ReaderAuthMethod string
+
+const (
+	ReaderAuthMethodPassword  ReaderAuthMethod = "password"
+	ReaderAuthMethodOIDC      ReaderAuthMethod = "oidc"
+	ReaderAuthMethodShareLink ReaderAuthMethod = "share_link"
+)
+
+// ReaderAuth more methods of the reader authentication besides the password of SimpleAuth, any enabled one grants access
+type ReaderAuth struct {
+	OIDC      OIDCReaderAuth `json:"oidc"`
+	ShareLink ShareLinkAuth  `json:"share_link"`
+}
+
+// OIDCReaderAuth readers login by the oidc provider of the console, only verified emails of the domains are allowed
+type OIDCReaderAuth struct {
+	Enabled      bool     `json:"enabled"`
+	EmailDomains []string `json:"email_domains"` // e.g. example.com
+}
+
+// ShareLinkAuth readers with a signed share link are allowed until the link expires
+type ShareLinkAuth struct {
+	Enabled bool `json:"enabled"`
+	// Secret signs the links of the kb, regenerated to revoke all links
+	Secret string `json:"secret,omitempty"`
+}
+
+// ReaderAuthMethods the enabled methods, readers are not authenticated if empty
+func (s *AccessSettings) ReaderAuthMethods() []ReaderAuthMethod {
+	methods := make([]ReaderAuthMethod, 0)
+	if s.SimpleAuth.Enabled && s.SimpleAuth.PasswordHash != "" {
+		methods = append(methods, ReaderAuthMethodPassword)
+	}
+	if s.ReaderAuth.OIDC.Enabled && len(s.ReaderAuth.OIDC.EmailDomains) > 0 {
+		methods = append(methods, ReaderAuthMethodOIDC)
+	}
+	if s.ReaderAuth.ShareLink.Enabled && s.ReaderAuth.ShareLink.Secret != "" {
+		methods = append(methods, ReaderAuthMethodShareLink)
+	}
+	return methods
+}
+
+// HideReaderSecrets removes the secrets of the reader auth from the responses of the console
+func (s *AccessSettings) HideReaderSecrets() {
+	s.SimpleAuth.Password = ""
+	s.SimpleAuth.PasswordHash = ""
+	s.ReaderAuth.ShareLink.Secret = ""
+}
+
+func (a *SimpleAuth) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	a.Password = ""
+	a.PasswordHash = string(hash)
+	return nil
+}
+
+func (a *SimpleAuth) CheckPassword(password string) bool {
+	if a.PasswordHash == "" || password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)) == nil
+}
+
+// AllowsEmail the domain of the email is in the allow-list, subdomains are not included
+func (a *OIDCReaderAuth) AllowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return false
+	}
+	emailDomain := strings.ToLower(email[at+1:])
+	return slices.ContainsFunc(a.EmailDomains, func(domain string) bool {
+		return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")) == emailDomain
+	})
+}
+
+// ReaderSession the authenticated reader of a published wiki, kept in the cookie by the id
+type ReaderSession struct {
+	ID        string           `json:"id"`
+	KBID      string           `json:"kb_id"`
+	Method    ReaderAuthMethod `json:"method"`
+	Email     string           `json:"email,omitempty"` // the reader of the oidc login
+	IP        string           `json:"ip"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// ShareLinkClaims the signed payload of a share link
+type ShareLinkClaims struct {
+	KBID      string `json:"kb_id"`
+	ExpiresAt int64  `json:"exp"` // unix seconds
+}
+
+type ReaderAuthInfoResp struct {
+	Methods       []ReaderAuthMethod `json:"methods"` // empty if the kb is public
+	Authenticated bool               `json:"authenticated"`
+}
+
+type ReaderPasswordLoginReq struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type CreateShareLinkReq struct {
+	KBID      string `json:"kb_id" validate:"required"`
+	ExpiresIn int64  `json:"expires_in" validate:"required,min=60,max=31536000"` // seconds
+	Redirect  string `json:"redirect"`                                           // path of the wiki to open, / by default
+}
+
+type ShareLinkResp struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type RevokeShareLinksReq struct {
+	KBID string `json:"kb_id" query:"kb_id" validate:"required"`
+}
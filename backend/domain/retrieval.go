@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	DefaultRetrievalTopK        = 10
+	DefaultRetrievalVectorTopK  = 20
+	DefaultRetrievalKeywordTopK = 20
+	DefaultRetrievalWeight      = 1.0
+	MaxRetrievalSubQueries      = 5
+
+	// RRFConstant is the k of reciprocal rank fusion, score = weight / (k + rank)
+	RRFConstant = 60
+)
+
+// RetrievalSettings hybrid retrieval settings of a knowledge base
+type RetrievalSettings struct {
+	TopK          int      `json:"top_k"`          // max nodes fed into the prompt
+	VectorTopK    int      `json:"vector_top_k"`   // max chunks from vector search
+	KeywordTopK   int      `json:"keyword_top_k"`  // max nodes from full text search
+	VectorWeight  *float64 `json:"vector_weight"`  // rrf weight of vector search, 0 to disable
+	KeywordWeight *float64 `json:"keyword_weight"` // rrf weight of full text search, 0 to disable
+
+	// chunks scored below the threshold by the rerank model are dropped, nil to keep all
+	RerankThreshold *float64 `json:"rerank_threshold"`
+
+	// rewrite follow up questions into standalone queries with the chat model
+	QueryRewrite  bool `json:"query_rewrite"`
+	MaxSubQueries int  `json:"max_sub_queries"` // split the question into sub queries, 0 to disable
+}
+
+func (s *RetrievalSettings) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid retrieval settings value type:", value))
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s RetrievalSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s RetrievalSettings) Validate() error {
+	if s.TopK < 0 || s.VectorTopK < 0 || s.KeywordTopK < 0 {
+		return errors.New("top k must not be negative")
+	}
+	if s.MaxSubQueries < 0 || s.MaxSubQueries > MaxRetrievalSubQueries {
+		return fmt.Errorf("max sub queries must be between 0 and %d", MaxRetrievalSubQueries)
+	}
+	if s.VectorWeight != nil && *s.VectorWeight < 0 {
+		return errors.New("vector weight must not be negative")
+	}
+	if s.KeywordWeight != nil && *s.KeywordWeight < 0 {
+		return errors.New("keyword weight must not be negative")
+	}
+	if s.GetVectorWeight() == 0 && s.GetKeywordWeight() == 0 {
+		return errors.New("vector weight and keyword weight must not both be zero")
+	}
+	return nil
+}
+
+func (s RetrievalSettings) GetTopK() int {
+	if s.TopK > 0 {
+		return s.TopK
+	}
+	return DefaultRetrievalTopK
+}
+
+func (s RetrievalSettings) GetVectorTopK() int {
+	if s.VectorTopK > 0 {
+		return s.VectorTopK
+	}
+	return DefaultRetrievalVectorTopK
+}
+
+func (s RetrievalSettings) GetKeywordTopK() int {
+	if s.KeywordTopK > 0 {
+		return s.KeywordTopK
+	}
+	return DefaultRetrievalKeywordTopK
+}
+
+func (s RetrievalSettings) GetVectorWeight() float64 {
+	if s.VectorWeight != nil {
+		return *s.VectorWeight
+	}
+	return DefaultRetrievalWeight
+}
+
+func (s RetrievalSettings) GetKeywordWeight() float64 {
+	if s.KeywordWeight != nil {
+		return *s.KeywordWeight
+	}
+	return DefaultRetrievalWeight
+}
+
+// RetrievedChunk retrieval record of a chunk fed into the prompt
+type RetrievedChunk struct {
+	NodeID      string   `json:"node_id"`
+	ChunkID     string   `json:"chunk_id"`
+	DocID       string   `json:"doc_id"`
+	VectorRank  int      `json:"vector_rank,omitempty"`  // rank of the chunk in vector search
+	KeywordRank int      `json:"keyword_rank,omitempty"` // rank of the node in full text search
+	Score       float64  `json:"score"`                  // fused score of the node
+	RerankScore *float64 `json:"rerank_score,omitempty"`
+}
+
+type RetrievedChunks []*RetrievedChunk
+
+func NewRetrievedChunks(rankedNodes []*RankedNodeChunks) RetrievedChunks {
+	chunks := make(RetrievedChunks, 0)
+	for _, node := range rankedNodes {
+		for _, chunk := range node.Chunks {
+			chunks = append(chunks, &RetrievedChunk{
+				NodeID:      node.NodeID,
+				ChunkID:     chunk.ID,
+				DocID:       chunk.DocID,
+				VectorRank:  chunk.VectorRank,
+				KeywordRank: node.KeywordRank,
+				Score:       node.Score,
+				RerankScore: chunk.RerankScore,
+			})
+		}
+	}
+	return chunks
+}
+
+func (c *RetrievedChunks) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("invalid retrieved chunks value type:", value))
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c RetrievedChunks) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// KeywordSearchResult node release hit by full text search
+type KeywordSearchResult struct {
+	NodeRelease *NodeRelease
+	Rank        float64
+}
+
+// QueryRewrite standalone query rewritten from a follow up question
+type QueryRewrite struct {
+	Query      string   `json:"query"`
+	SubQueries []string `json:"sub_queries"`
+}
+
+// RetrievalResult intermediate and final results of hybrid retrieval
+type RetrievalResult struct {
+	Queries        []string                // the query and sub queries
+	VectorChunks   []*NodeContentChunk     // raw chunks from rag service
+	DocIDNode      map[string]*NodeRelease // node releases of the raw chunks by doc id
+	KeywordResults []*KeywordSearchResult
+	RankedNodes    []*RankedNodeChunks // fused, reranked and trimmed nodes fed into the prompt
+}
+
+type RetrievalTestReq struct {
+	KBID       string   `json:"kb_id" validate:"required"`
+	Question   string   `json:"question" validate:"required"`
+	SubQueries []string `json:"sub_queries"` // optional, to test multi query retrieval
+}
+
+type RetrievalTestResp struct {
+	Queries       []string                   `json:"queries"`
+	VectorChunks  []*RetrievalTestChunk      `json:"vector_chunks"`
+	KeywordHits   []*RetrievalTestKeywordHit `json:"keyword_hits"`
+	RankedNodes   []*RankedNodeChunks        `json:"ranked_nodes"`
+	Messages      []*schema.Message          `json:"messages"`
+	Prompt        string                     `json:"prompt"`
+	TokenEstimate int                        `json:"token_estimate"`
+}
+
+type RetrievalTestChunk struct {
+	*NodeContentChunk
+	// nil if the doc is not mapped to a public node release
+	NodeRelease *RetrievalTestNodeRelease `json:"node_release"`
+}
+
+type RetrievalTestNodeRelease struct {
+	ID     string `json:"id"`
+	NodeID string `json:"node_id"`
+	DocID  string `json:"doc_id"`
+	Name   string `json:"name"`
+}
+
+type RetrievalTestKeywordHit struct {
+	RetrievalTestNodeRelease
+	Rank float64 `json:"rank"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Session server side state of a jwt issued by the login, the jwt is rejected once its session is revoked
+type Session struct {
+	ID         string    `json:"id"` // jti of the jwt
+	UserID     string    `json:"user_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"` // device of the login
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionClient the client logging in
+type SessionClient struct {
+	IP        string
+	UserAgent string
+}
+
+type SessionListItem struct {
+	*Session
+	Current bool `json:"current"` // the session of the request
+}
+
+type GetSessionListReq struct {
+	UserID string `json:"user_id" query:"user_id"` // the current user by default, other users by admins
+}
+
+type RevokeSessionReq struct {
+	ID string `json:"id" query:"id" validate:"required"`
+}
+
+type RevokeAllSessionsReq struct {
+	// UserID the current user by default, whose current session is kept
+	UserID string `json:"user_id" query:"user_id"`
+}
@@ -1,8 +1,13 @@
 package domain
 
+const SSEErrorCodeQuotaExceeded = "quota_exceeded"
+
 type SSEEvent struct {
 	Type        string              `json:"type"`
 	Content     string              `json:"content"`
 	ChunkResult *NodeCotentChunkSSE `json:"chunk_result,omitempty"`
 	Error       string              `json:"error,omitempty"`
+	Code        string              `json:"code,omitempty"`
+	Quota       *QuotaExceeded      `json:"quota,omitempty"`
+	Citation    *Citation           `json:"citation,omitempty"`
 }
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// SettingKeySecurity key of the SecuritySetting in the settings table
+const SettingKeySecurity = "security"
+
+// Setting instance wide settings changed by admins at runtime, the value is json
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey"`
+	Value     []byte    `json:"value" gorm:"type:jsonb"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SecuritySetting struct {
+	// RequireTwoFactor every user logging in to the console must enroll totp,
+	// including the users of ldap and oidc
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+// TwoFactorChallenge the pending second step of a login after the password is verified
+type TwoFactorChallenge struct {
+	UserID string `json:"user_id"`
+}
+
+type TwoFactorLoginReq struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+	// Code totp code, or a recovery code if the device is lost
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorLoginEnrollReq struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+}
+
+type TwoFactorEnrollResp struct {
+	Secret string `json:"secret"` // base32 secret for manual entry
+	URI    string `json:"uri"`    // otpauth provisioning uri
+	QRCode string `json:"qr_code"`
+}
+
+type TwoFactorCodeReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorRecoveryCodesResp struct {
+	RecoveryCodes []string `json:"recovery_codes"` // plaintext codes, only returned once
+}
+
+type TwoFactorLoginResp struct {
+	Token string `json:"token"`
+	// RecoveryCodes returned when the totp is enrolled during the login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type ResetTwoFactorReq struct {
+	UserID string `json:"user_id" query:"user_id" validate:"required"`
+}
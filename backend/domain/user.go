@@ -4,17 +4,35 @@ import (
 	"time"
 )
 
+type UserType string
+
+const (
+	UserTypeUser UserType = "user"
+	// UserTypeServiceAccount can not login, only authenticates by api tokens
+	UserTypeServiceAccount UserType = "service_account"
+)
+
 type User struct {
-	ID         string    `json:"id" gorm:"primaryKey"`
-	Account    string    `json:"account" gorm:"uniqueIndex"`
-	Password   string    `json:"password"`
-	CreatedAt  time.Time `json:"created_at"`
-	LastAccess time.Time `json:"last_access" gorm:"default:null"`
+	ID         string     `json:"id" gorm:"primaryKey"`
+	Account    string     `json:"account" gorm:"uniqueIndex"`
+	Password   string     `json:"password"`
+	Type       UserType   `json:"type" gorm:"default:user"`
+	Role       Role       `json:"role"` // global role of all kbs, empty for members of kbs only
+	Source     UserSource `json:"source" gorm:"default:local"`
+	ExternalID string     `json:"-" gorm:"index"` // id of the identity provider of external users
+	CreatedAt  time.Time  `json:"created_at"`
+	LastAccess time.Time  `json:"last_access" gorm:"default:null"`
+
+	// TwoFactorSecret totp secret, pending until TwoFactorEnabled is set by the first valid code
+	TwoFactorEnabled       bool     `json:"two_factor_enabled"`
+	TwoFactorSecret        string   `json:"-"`
+	TwoFactorRecoveryCodes []string `json:"-" gorm:"type:jsonb;serializer:json"` // sha256 hashes of the unused codes
 }
 
 type CreateUserReq struct {
 	Account  string `json:"account" validate:"required"`
 	Password string `json:"password" validate:"required,min=8"`
+	Role     Role   `json:"role" validate:"omitempty,oneof=owner admin editor viewer"`
 }
 
 type LoginReq struct {
@@ -22,21 +40,36 @@ type LoginReq struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResp the token, or the challenge of the second step for users with 2fa
 type LoginResp struct {
-	Token string `json:"token"`
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+	// TwoFactorEnrollRequired 2fa is required by the admins but not enrolled by the user yet
+	TwoFactorEnrollRequired bool `json:"two_factor_enroll_required,omitempty"`
 }
 
 type UserInfoResp struct {
 	ID         string     `json:"id"`
 	Account    string     `json:"account"`
+	Type       UserType   `json:"type"`
+	Role       Role       `json:"role"`
+	Source     UserSource `json:"source"`
 	LastAccess *time.Time `json:"last_access,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
 type UserListItemResp struct {
 	ID         string     `json:"id"`
 	Account    string     `json:"account"`
+	Type       UserType   `json:"type"`
+	Role       Role       `json:"role"`
+	Source     UserSource `json:"source"`
 	LastAccess *time.Time `json:"last_access,omitempty"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
 type ResetPasswordReq struct {
@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/telemetry"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type accessContextKey struct{}
+
+// access the mcp server app of the caller and the access password of the kb
+type access struct {
+	app      *domain.App
+	password string
+}
+
+// WithAccess binds the app and the access password of the caller to the tool calls
+func WithAccess(ctx context.Context, app *domain.App, password string) context.Context {
+	return context.WithValue(ctx, accessContextKey{}, &access{app: app, password: password})
+}
+
+// MCPServer exposes the knowledge base tools over the model context protocol
+type MCPServer struct {
+	server  *server.MCPServer
+	usecase *usecase.MCPUsecase
+	logger  *log.Logger
+}
+
+func NewMCPServer(mcpUsecase *usecase.MCPUsecase, logger *log.Logger) *MCPServer {
+	s := &MCPServer{
+		server: server.NewMCPServer(domain.MCPServerName, telemetry.Version,
+			server.WithToolCapabilities(false),
+			server.WithRecovery(),
+		),
+		usecase: mcpUsecase,
+		logger:  logger.WithModule("handler.mcp"),
+	}
+	s.server.AddTool(mcp.NewTool(domain.MCPToolSearchKB,
+		mcp.WithDescription("Search the public documents of the knowledge base, returns the most relevant chunks with their document id and url"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithString("query", mcp.Required(), mcp.Description("search query")),
+		mcp.WithNumber("top_k", mcp.Description("max number of chunks"), mcp.DefaultNumber(domain.MCPDefaultSearchTopK), mcp.Min(1), mcp.Max(domain.MCPMaxSearchTopK)),
+	), s.searchKB)
+	s.server.AddTool(mcp.NewTool(domain.MCPToolListNodes,
+		mcp.WithDescription("List the public documents and folders of the knowledge base, parent_id links a node to its folder"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+	), s.listNodes)
+	s.server.AddTool(mcp.NewTool(domain.MCPToolGetNode,
+		mcp.WithDescription("Read a public document of the knowledge base as markdown"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithString("id", mcp.Required(), mcp.Description("document id from search_kb or list_nodes")),
+	), s.getNode)
+	return s
+}
+
+// ServeStdio serves the single client of stdin and stdout as the app
+func (s *MCPServer) ServeStdio(ctx context.Context, app *domain.App, password string, stdin io.Reader, stdout io.Writer) error {
+	stdio := server.NewStdioServer(s.server)
+	stdio.SetContextFunc(func(ctx context.Context) context.Context {
+		return WithAccess(ctx, app, password)
+	})
+	return stdio.Listen(ctx, stdin, stdout)
+}
+
+// HTTPHandler the stateless streamable http transport, the request context must carry the access
+func (s *MCPServer) HTTPHandler() http.Handler {
+	return server.NewStreamableHTTPServer(s.server, server.WithStateLess(true))
+}
+
+func (s *MCPServer) searchKB(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := request.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return s.call(ctx, domain.MCPToolSearchKB, func(access *access) (any, error) {
+		return s.usecase.SearchKB(ctx, access.app, access.password, query, request.GetInt("top_k", domain.MCPDefaultSearchTopK))
+	})
+}
+
+func (s *MCPServer) listNodes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.call(ctx, domain.MCPToolListNodes, func(access *access) (any, error) {
+		return s.usecase.ListNodes(ctx, access.app, access.password)
+	})
+}
+
+func (s *MCPServer) getNode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return s.call(ctx, domain.MCPToolGetNode, func(access *access) (any, error) {
+		return s.usecase.GetNode(ctx, access.app, access.password, id)
+	})
+}
+
+// call runs the tool as the caller of the context and returns the result as json text,
+// failures are returned as tool errors for the model to see
+func (s *MCPServer) call(ctx context.Context, tool string, fn func(access *access) (any, error)) (*mcp.CallToolResult, error) {
+	access, ok := ctx.Value(accessContextKey{}).(*access)
+	if !ok {
+		return mcp.NewToolResultError("unauthorized"), nil
+	}
+	result, err := fn(access)
+	if err != nil {
+		s.logger.Error("mcp tool failed", log.String("tool", tool), log.String("app_id", access.app.ID), log.Error(err))
+		switch {
+		case errors.Is(err, usecase.ErrMCPAccessDenied):
+			return mcp.NewToolResultError(err.Error()), nil
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return mcp.NewToolResultError("not found"), nil
+		}
+		return mcp.NewToolResultError(tool + " failed"), nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
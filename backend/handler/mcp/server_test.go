@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+func TestMCPServerTools(t *testing.T) {
+	s := NewMCPServer(nil, log.NewLogger(&config.Config{}))
+	ctx := context.Background()
+
+	response := s.server.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	result, ok := response.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	tools := result.Result.(mcp.ListToolsResult).Tools
+	names := map[string]bool{}
+	for _, tool := range tools {
+		names[tool.Name] = true
+		if tool.Annotations.ReadOnlyHint == nil || !*tool.Annotations.ReadOnlyHint {
+			t.Errorf("tool %s should be read only", tool.Name)
+		}
+	}
+	for _, name := range []string{domain.MCPToolSearchKB, domain.MCPToolListNodes, domain.MCPToolGetNode} {
+		if !names[name] {
+			t.Errorf("tool %s not registered", name)
+		}
+	}
+
+	// tool calls without the app of the caller are rejected
+	response = s.server.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"list_nodes","arguments":{}}}`))
+	result, ok = response.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if callResult := result.Result.(mcp.CallToolResult); !callResult.IsError {
+		t.Errorf("call without access should fail")
+	}
+}
@@ -39,7 +39,8 @@ func NewShareAppHandler(
 				}
 				return next(c)
 			}
-		})
+		},
+		h.BaseHandler.ShareAuthMiddleware.Authorize)
 	share.GET("/web/info", h.GetWebAppInfo)
 	return h
 }
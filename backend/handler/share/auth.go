@@ -0,0 +1,202 @@
+package share
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type ShareAuthHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.ReaderAuthUsecase
+}
+
+func NewShareAuthHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	usecase *usecase.ReaderAuthUsecase,
+) *ShareAuthHandler {
+	h := &ShareAuthHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.share.auth"),
+		usecase:     usecase,
+	}
+
+	group := e.Group("share/v1/auth")
+	group.GET("/info", h.GetAuthInfo)
+	group.POST("/login", h.Login)
+	group.POST("/logout", h.Logout)
+	group.GET("/oidc/login", h.OIDCLogin)
+	group.GET("/oidc/callback", h.OIDCCallback)
+	group.GET("/share_link", h.ShareLinkLogin)
+
+	return h
+}
+
+func readerSessionID(c echo.Context) string {
+	cookie, err := c.Cookie(domain.ReaderSessionCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func setReaderSessionCookie(c echo.Context, session *domain.ReaderSession) {
+	c.SetCookie(&http.Cookie{
+		Name:     domain.ReaderSessionCookie,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		// lax to be sent after the redirect of the oidc callback
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// GetAuthInfo
+//
+//	@Summary		GetAuthInfo
+//	@Description	the reader auth methods of the kb and whether the reader is authenticated
+//	@Tags			share_auth
+//	@Produce		json
+//	@Param			X-KB-ID	header		string	true	"kb id"
+//	@Success		200		{object}	domain.Response{data=domain.ReaderAuthInfoResp}
+//	@Router			/share/v1/auth/info [get]
+func (h *ShareAuthHandler) GetAuthInfo(c echo.Context) error {
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+	info, err := h.usecase.GetAuthInfo(c.Request().Context(), kbID, readerSessionID(c), c.RealIP())
+	if err != nil {
+		return h.NewResponseWithError(c, "get auth info failed", err)
+	}
+	return h.NewResponseWithData(c, info)
+}
+
+// Login
+//
+//	@Summary		ReaderLogin
+//	@Description	login the wiki by the access password, the session is kept in the cookie
+//	@Tags			share_auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			X-KB-ID	header		string							true	"kb id"
+//	@Param			body	body		domain.ReaderPasswordLoginReq	true	"password"
+//	@Success		200		{object}	domain.Response
+//	@Router			/share/v1/auth/login [post]
+func (h *ShareAuthHandler) Login(c echo.Context) error {
+	var req domain.ReaderPasswordLoginReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+	session, err := h.usecase.LoginPassword(c.Request().Context(), kbID, req.Password, c.RealIP())
+	if err != nil {
+		return h.NewResponseWithError(c, "login failed", err)
+	}
+	setReaderSessionCookie(c, session)
+	return h.NewResponseWithData(c, nil)
+}
+
+// Logout
+//
+//	@Summary		ReaderLogout
+//	@Description	revoke the reader session of the cookie
+//	@Tags			share_auth
+//	@Produce		json
+//	@Success		200	{object}	domain.Response
+//	@Router			/share/v1/auth/logout [post]
+func (h *ShareAuthHandler) Logout(c echo.Context) error {
+	if sessionID := readerSessionID(c); sessionID != "" {
+		if err := h.usecase.Logout(c.Request().Context(), sessionID); err != nil {
+			return h.NewResponseWithError(c, "logout failed", err)
+		}
+	}
+	c.SetCookie(&http.Cookie{Name: domain.ReaderSessionCookie, Path: "/", MaxAge: -1})
+	return h.NewResponseWithData(c, nil)
+}
+
+// OIDCLogin
+//
+//	@Summary		ReaderOIDCLogin
+//	@Description	redirect to the identity provider to login the wiki, only the emails of the allowed domains are accepted
+//	@Tags			share_auth
+//	@Param			X-KB-ID		header	string	true	"kb id"
+//	@Param			redirect	query	string	false	"wiki path to return to after login"
+//	@Success		302
+//	@Router			/share/v1/auth/oidc/login [get]
+func (h *ShareAuthHandler) OIDCLogin(c echo.Context) error {
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+	authURL, err := h.usecase.OIDCAuthURL(c.Request().Context(), kbID, c.QueryParam("redirect"))
+	if err != nil {
+		return h.NewResponseWithError(c, "oidc login failed", err)
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback
+//
+//	@Summary		ReaderOIDCCallback
+//	@Description	callback of the identity provider, sets the session cookie and redirects to the wiki
+//	@Tags			share_auth
+//	@Param			X-KB-ID	header	string	true	"kb id"
+//	@Param			code	query	string	true	"authorization code"
+//	@Param			state	query	string	true	"state"
+//	@Success		302
+//	@Router			/share/v1/auth/oidc/callback [get]
+func (h *ShareAuthHandler) OIDCCallback(c echo.Context) error {
+	if errMsg := c.QueryParam("error"); errMsg != "" {
+		return h.NewResponseWithError(c, "oidc login failed: "+errMsg, nil)
+	}
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+	session, redirect, err := h.usecase.LoginOIDC(c.Request().Context(), kbID, c.QueryParam("code"), c.QueryParam("state"), c.RealIP())
+	if err != nil {
+		return h.NewResponseWithError(c, "oidc login failed", err)
+	}
+	setReaderSessionCookie(c, session)
+	return c.Redirect(http.StatusFound, redirect)
+}
+
+// ShareLinkLogin
+//
+//	@Summary		ShareLinkLogin
+//	@Description	open a signed share link, sets the session cookie until the link expires and redirects to the wiki
+//	@Tags			share_auth
+//	@Param			X-KB-ID		header	string	true	"kb id"
+//	@Param			token		query	string	true	"share link token"
+//	@Param			redirect	query	string	false	"wiki path to open"
+//	@Success		302
+//	@Router			/share/v1/auth/share_link [get]
+func (h *ShareAuthHandler) ShareLinkLogin(c echo.Context) error {
+	kbID := c.Request().Header.Get("X-KB-ID")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb_id is required", nil)
+	}
+	session, redirect, err := h.usecase.LoginShareLink(c.Request().Context(), kbID, c.QueryParam("token"), c.QueryParam("redirect"), c.RealIP())
+	if err != nil {
+		return h.NewResponseWithError(c, "share link login failed", err)
+	}
+	setReaderSessionCookie(c, session)
+	return c.Redirect(http.StatusFound, redirect)
+}
@@ -2,6 +2,7 @@ package share
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -54,6 +55,8 @@ func NewShareChatHandler(
 			}
 		})
 	share.POST("/message", h.ChatMessage)
+	share.POST("/feedback", h.FeedbackMessage)
+	share.POST("/stop", h.StopChat)
 
 	return h
 }
@@ -104,6 +107,57 @@ func (h *ShareChatHandler) ChatMessage(c echo.Context) error {
 	return nil
 }
 
+// FeedbackMessage rate an answer
+//
+//	@Summary		FeedbackMessage
+//	@Description	rate an assistant message with thumbs up or down, an optional reason and comment
+//	@Tags			share_chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		domain.FeedbackReq	true	"request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/share/v1/chat/feedback [post]
+func (h *ShareChatHandler) FeedbackMessage(c echo.Context) error {
+	var req domain.FeedbackReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.conversationUsecase.FeedbackMessage(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "feedback message failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// StopChat stop answering
+//
+//	@Summary		StopChat
+//	@Description	stop the running answer of the conversation, the partial answer is saved as stopped
+//	@Tags			share_chat
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		domain.StopChatReq	true	"request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/share/v1/chat/stop [post]
+func (h *ShareChatHandler) StopChat(c echo.Context) error {
+	var req domain.StopChatReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.chatUsecase.StopChat(c.Request().Context(), &req); err != nil {
+		if errors.Is(err, usecase.ErrChatStreamNotFound) {
+			return h.NewResponseWithError(c, "chat stream not found", err)
+		}
+		return h.NewResponseWithError(c, "stop chat failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
 func (h *ShareChatHandler) sendErrMsg(c echo.Context, errMsg string) error {
 	return h.writeSSEEvent(c, domain.SSEEvent{Type: "error", Content: errMsg})
 }
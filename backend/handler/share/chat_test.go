@@ -0,0 +1,105 @@
+package share
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	pgstore "github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type testValidator struct {
+	validator *validator.Validate
+}
+
+func (v *testValidator) Validate(i any) error {
+	return v.validator.Struct(i)
+}
+
+func TestFeedbackMessage(t *testing.T) {
+	logger := log.NewLogger(&config.Config{})
+	e := echo.New()
+	e.Validator = &testValidator{validator: validator.New()}
+	for _, tc := range []struct {
+		name string
+		body string
+		// the nonce is checked if the request is valid
+		valid bool
+		// the score saved if the nonce is right
+		score   domain.FeedbackScore
+		success bool
+	}{
+		{name: "like", body: `{"conversation_id":"conversation","nonce":"nonce","message_id":"answer","score":1}`, valid: true, score: domain.FeedbackScoreLike, success: true},
+		{name: "vote again", body: `{"conversation_id":"conversation","nonce":"nonce","message_id":"answer","score":-1,"reason":"outdated"}`, valid: true, score: domain.FeedbackScoreDislike, success: true},
+		{name: "wrong nonce", body: `{"conversation_id":"conversation","nonce":"forged","message_id":"answer","score":1}`, valid: true},
+		{name: "invalid score", body: `{"conversation_id":"conversation","nonce":"nonce","message_id":"answer","score":0}`},
+		{name: "invalid reason", body: `{"conversation_id":"conversation","nonce":"nonce","message_id":"answer","score":-1,"reason":"spam"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var req domain.FeedbackReq
+			if err := json.Unmarshal([]byte(tc.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			if tc.valid {
+				rows := sqlmock.NewRows([]string{"id", "nonce"})
+				if req.Nonce == "nonce" {
+					rows.AddRow("conversation", "nonce")
+				}
+				mock.ExpectQuery(`SELECT \* FROM "conversations"`).
+					WithArgs("conversation", req.Nonce, 1).
+					WillReturnRows(rows)
+			}
+			if tc.score != 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "conversation_messages" SET .*"feedback_score"=\$4 WHERE id = \$5 AND conversation_id = \$6 AND role = \$7`).
+					WithArgs(sqlmock.AnyArg(), req.Comment, req.Reason, tc.score, "answer", "conversation", "assistant").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+			h := &ShareChatHandler{
+				BaseHandler:         handler.NewBaseHandler(e, logger, &config.Config{}, nil),
+				logger:              logger,
+				conversationUsecase: usecase.NewConversationUsecase(pg.NewConversationRepository(&pgstore.DB{DB: db}), nil, logger, nil),
+			}
+			request := httptest.NewRequest(http.MethodPost, "/share/v1/chat/feedback", strings.NewReader(tc.body))
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			recorder := httptest.NewRecorder()
+			if err := h.FeedbackMessage(e.NewContext(request, recorder)); err != nil {
+				t.Fatal(err)
+			}
+			var resp domain.Response
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Success != tc.success {
+				t.Errorf("success = %v, expected %v: %s", resp.Success, tc.success, resp.Message)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package share
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/handler/mcp"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+)
+
+// MCPHandler streamable http transport of the mcp server app, authenticated by the api key of the app
+type MCPHandler struct {
+	*handler.BaseHandler
+	logger      *log.Logger
+	auth        *middleware.AppAPIKeyMiddleware
+	httpHandler http.Handler
+}
+
+func NewMCPHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth *middleware.AppAPIKeyMiddleware, mcpServer *mcp.MCPServer) *MCPHandler {
+	h := &MCPHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.share.mcp"),
+		auth:        auth,
+		httpHandler: mcpServer.HTTPHandler(),
+	}
+	e.Match([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, "/mcp", h.Serve, h.auth.Authorize(domain.AppTypeMCPServer))
+
+	return h
+}
+
+// Serve
+//
+//	@Summary		MCP
+//	@Description	model context protocol over streamable http with the tools search_kb, list_nodes and get_node,
+//	@Description	the X-Simple-Auth-Password header is required if the knowledge base enables simple auth
+//	@Tags			mcp
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Router			/mcp [post]
+func (h *MCPHandler) Serve(c echo.Context) error {
+	app, _ := h.auth.MustGetApp(c)
+	ctx := mcp.WithAccess(c.Request().Context(), app, c.Request().Header.Get("X-Simple-Auth-Password"))
+	h.httpHandler.ServeHTTP(c.Response(), c.Request().WithContext(ctx))
+	return nil
+}
@@ -0,0 +1,218 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+const openAIFinishReasonStop = "stop"
+
+// OpenAIHandler openai compatible api, the knowledge base of the api key is the model
+type OpenAIHandler struct {
+	*handler.BaseHandler
+	logger        *log.Logger
+	auth          *middleware.AppAPIKeyMiddleware
+	openAIUsecase *usecase.OpenAIUsecase
+}
+
+func NewOpenAIHandler(
+	e *echo.Echo,
+	baseHandler *handler.BaseHandler,
+	logger *log.Logger,
+	auth *middleware.AppAPIKeyMiddleware,
+	openAIUsecase *usecase.OpenAIUsecase,
+) *OpenAIHandler {
+	h := &OpenAIHandler{
+		BaseHandler:   baseHandler,
+		logger:        logger.WithModule("handler.share.openai"),
+		auth:          auth,
+		openAIUsecase: openAIUsecase,
+	}
+	group := e.Group("/v1", h.auth.Authorize(domain.AppTypeOpenAIAPI))
+	group.GET("/models", h.ListModels)
+	group.POST("/chat/completions", h.ChatCompletions)
+
+	return h
+}
+
+// ListModels
+//
+//	@Summary		ListModels
+//	@Description	openai compatible model list, the knowledge base of the api key is the only model
+//	@Tags			openai
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	domain.OpenAIModelListResp
+//	@Router			/v1/models [get]
+func (h *OpenAIHandler) ListModels(c echo.Context) error {
+	app, _ := h.auth.MustGetApp(c)
+	models, err := h.openAIUsecase.ListModels(c.Request().Context(), app)
+	if err != nil {
+		h.logger.Error("list models failed", log.Error(err))
+		return h.writeError(c, http.StatusInternalServerError, "server_error", "", "list models failed")
+	}
+	return c.JSON(http.StatusOK, models)
+}
+
+// ChatCompletions
+//
+//	@Summary		ChatCompletions
+//	@Description	openai compatible chat completions answered from the knowledge base, the cited documents are in x_pandawiki
+//	@Tags			openai
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		domain.OpenAIChatCompletionReq	true	"request"
+//	@Success		200		{object}	domain.OpenAIChatCompletionResp
+//	@Router			/v1/chat/completions [post]
+func (h *OpenAIHandler) ChatCompletions(c echo.Context) error {
+	var req domain.OpenAIChatCompletionReq
+	if err := c.Bind(&req); err != nil {
+		return h.writeError(c, http.StatusBadRequest, "invalid_request_error", "", "parse request failed")
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.writeError(c, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+	}
+	app, _ := h.auth.MustGetApp(c)
+	eventCh, err := h.openAIUsecase.ChatCompletions(c.Request().Context(), app, &req, c.RealIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrOpenAIModelNotFound):
+			return h.writeError(c, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Sprintf("model %s not found", req.Model))
+		case errors.Is(err, usecase.ErrOpenAINoQuestion):
+			return h.writeError(c, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		}
+		h.logger.Error("chat completions failed", log.Error(err))
+		return h.writeError(c, http.StatusInternalServerError, "server_error", "", "chat completions failed")
+	}
+
+	completion := &domain.OpenAIChatCompletionResp{
+		ID:        "chatcmpl-" + uuid.New().String(),
+		Created:   time.Now().Unix(),
+		Model:     req.Model,
+		PandaWiki: &domain.OpenAIPandaWikiExtension{Citations: make([]*domain.Citation, 0)},
+	}
+	answer := ""
+	streaming := false
+	for event := range eventCh {
+		switch event.Type {
+		case "conversation_id":
+			completion.PandaWiki.ConversationID = event.Content
+		case "message_id":
+			completion.PandaWiki.MessageID = event.Content
+		case "citation":
+			completion.PandaWiki.Citations = append(completion.PandaWiki.Citations, event.Citation)
+		case "verified_answer":
+			// the streamed content can not be taken back
+			answer = event.Content
+		case "data":
+			answer += event.Content
+			if !req.Stream {
+				continue
+			}
+			if !streaming {
+				streaming = true
+				c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+				c.Response().Header().Set("Cache-Control", "no-cache")
+				c.Response().Header().Set("Connection", "keep-alive")
+				c.Response().WriteHeader(http.StatusOK)
+				if err := h.writeChunk(c, completion, &domain.OpenAIRespMessage{Role: "assistant"}, nil); err != nil {
+					return err
+				}
+			}
+			if err := h.writeChunk(c, completion, &domain.OpenAIRespMessage{Content: event.Content}, nil); err != nil {
+				return err
+			}
+		case "error":
+			status, code := http.StatusInternalServerError, ""
+			if event.Code == domain.SSEErrorCodeQuotaExceeded {
+				status, code = http.StatusTooManyRequests, "rate_limit_exceeded"
+			}
+			if !streaming {
+				return h.writeError(c, status, "server_error", code, event.Content)
+			}
+			if err := h.writeEvent(c, domain.OpenAIErrorResp{Error: domain.OpenAIError{Message: event.Content, Type: "server_error", Code: code}}); err != nil {
+				return err
+			}
+			return h.writeDone(c)
+		case "done":
+			finishReason := openAIFinishReasonStop
+			if !req.Stream {
+				// the reasoning is not part of the message
+				if endIndex := strings.Index(answer, "</think>"); endIndex != -1 {
+					answer = strings.TrimSpace(answer[endIndex+len("</think>"):])
+				}
+				completion.Object = domain.OpenAIObjectChatCompletion
+				completion.Choices = []*domain.OpenAIChoice{{
+					Message:      &domain.OpenAIRespMessage{Role: "assistant", Content: answer},
+					FinishReason: &finishReason,
+				}}
+				return c.JSON(http.StatusOK, completion)
+			}
+			if !streaming {
+				streaming = true
+				c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+				c.Response().WriteHeader(http.StatusOK)
+			}
+			// the citations are sent with the last chunk
+			if err := h.writeChunk(c, completion, &domain.OpenAIRespMessage{}, &finishReason); err != nil {
+				return err
+			}
+			return h.writeDone(c)
+		}
+	}
+	return nil
+}
+
+func (h *OpenAIHandler) writeChunk(c echo.Context, completion *domain.OpenAIChatCompletionResp, delta *domain.OpenAIRespMessage, finishReason *string) error {
+	chunk := *completion
+	chunk.Object = domain.OpenAIObjectChatCompletionChunk
+	chunk.Choices = []*domain.OpenAIChoice{{Delta: delta, FinishReason: finishReason}}
+	if finishReason == nil {
+		chunk.PandaWiki = nil
+	}
+	return h.writeEvent(c, chunk)
+}
+
+func (h *OpenAIHandler) writeEvent(c echo.Context, data any) error {
+	jsonContent, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Response(), "data: %s\n\n", jsonContent); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+func (h *OpenAIHandler) writeDone(c echo.Context) error {
+	if _, err := fmt.Fprint(c.Response(), "data: [DONE]\n\n"); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+func (h *OpenAIHandler) writeError(c echo.Context, status int, errType, code, message string) error {
+	return c.JSON(status, domain.OpenAIErrorResp{
+		Error: domain.OpenAIError{
+			Message: message,
+			Type:    errType,
+			Code:    code,
+		},
+	})
+}
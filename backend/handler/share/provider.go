@@ -1,17 +1,29 @@
 package share
 
-import "github.com/google/wire"
+import (
+	"github.com/google/wire"
+
+	"github.com/chaitin/panda-wiki/handler/mcp"
+)
 
 type ShareHandler struct {
+	ShareAuthHandler *ShareAuthHandler
 	ShareNodeHandler *ShareNodeHandler
 	ShareAppHandler  *ShareAppHandler
 	ShareChatHandler *ShareChatHandler
+	OpenAIHandler    *OpenAIHandler
+	MCPHandler       *MCPHandler
 }
 
 var ProviderSet = wire.NewSet(
+	NewShareAuthHandler,
 	NewShareNodeHandler,
 	NewShareAppHandler,
 	NewShareChatHandler,
+	NewOpenAIHandler,
+	NewMCPHandler,
+
+	mcp.NewMCPServer,
 
 	wire.Struct(new(ShareHandler), "*"),
 )
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type APITokenHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.APITokenUsecase
+}
+
+func NewAPITokenHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.APITokenUsecase) *APITokenHandler {
+	h := &APITokenHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.api_token"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/user", h.auth.Authorize)
+	group.GET("/token/list", h.GetAPITokenList)
+	group.POST("/token", h.CreateAPIToken)
+	group.DELETE("/token", h.DeleteAPIToken)
+	group.POST("/service_account", h.CreateServiceAccount)
+
+	return h
+}
+
+// GetAPITokenList
+//
+//	@Summary		GetAPITokenList
+//	@Description	list the api tokens of the current user or a service account
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			user_id	query		string	false	"service account id"
+//	@Success		200		{object}	domain.Response{data=[]domain.APIToken}
+//	@Router			/api/v1/user/token/list [get]
+func (h *APITokenHandler) GetAPITokenList(c echo.Context) error {
+	var req domain.GetAPITokenListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	tokens, err := h.usecase.GetAPITokenList(c.Request().Context(), userID, &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get api token list failed", err)
+	}
+	return h.NewResponseWithData(c, tokens)
+}
+
+// CreateAPIToken
+//
+//	@Summary		CreateAPIToken
+//	@Description	create a scoped api token of the current user or a service account, the token is only returned once
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateAPITokenReq	true	"api token"
+//	@Success		200		{object}	domain.Response{data=domain.CreateAPITokenResp}
+//	@Router			/api/v1/user/token [post]
+func (h *APITokenHandler) CreateAPIToken(c echo.Context) error {
+	var req domain.CreateAPITokenReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	token, err := h.usecase.CreateAPIToken(c.Request().Context(), userID, &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create api token failed", err)
+	}
+	return h.NewResponseWithData(c, token)
+}
+
+// DeleteAPIToken
+//
+//	@Summary		DeleteAPIToken
+//	@Description	revoke an api token of the current user or a service account
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	query		string	true	"api token id"
+//	@Success		200	{object}	domain.Response
+//	@Router			/api/v1/user/token [delete]
+func (h *APITokenHandler) DeleteAPIToken(c echo.Context) error {
+	var req domain.DeleteAPITokenReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if err := h.usecase.DeleteAPIToken(c.Request().Context(), userID, &req); err != nil {
+		return h.NewResponseWithError(c, "delete api token failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// CreateServiceAccount
+//
+//	@Summary		CreateServiceAccount
+//	@Description	create a service account which can not login and only authenticates by api tokens
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateServiceAccountReq	true	"service account"
+//	@Success		200		{object}	domain.Response{data=domain.User}
+//	@Router			/api/v1/user/service_account [post]
+func (h *APITokenHandler) CreateServiceAccount(c echo.Context) error {
+	var req domain.CreateServiceAccountReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	user, err := h.usecase.CreateServiceAccount(c.Request().Context(), userID, &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create service account failed", err)
+	}
+	return h.NewResponseWithData(c, user)
+}
@@ -20,10 +20,11 @@ type AppHandler struct {
 	usecase             *usecase.AppUsecase
 	modelUsecase        *usecase.ModelUsecase
 	conversationUsecase *usecase.ConversationUsecase
+	rbac                *usecase.RBACUsecase
 	config              *config.Config
 }
 
-func NewAppHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.AppUsecase, modelUsecase *usecase.ModelUsecase, conversationUsecase *usecase.ConversationUsecase, config *config.Config) *AppHandler {
+func NewAppHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.AppUsecase, modelUsecase *usecase.ModelUsecase, conversationUsecase *usecase.ConversationUsecase, rbac *usecase.RBACUsecase, config *config.Config) *AppHandler {
 	h := &AppHandler{
 		BaseHandler:         baseHandler,
 		logger:              logger.WithModule("handler.v1.app"),
@@ -31,6 +32,7 @@ func NewAppHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.L
 		usecase:             usecase,
 		modelUsecase:        modelUsecase,
 		conversationUsecase: conversationUsecase,
+		rbac:                rbac,
 		config:              config,
 	}
 	group := e.Group("/api/v1/app", h.auth.Authorize)
@@ -70,6 +72,18 @@ func (h *AppHandler) GetAppDetail(c echo.Context) error {
 	if err != nil {
 		return h.NewResponseWithError(c, "get app detail failed", err)
 	}
+	// the secrets of the bots are only for the admins of the kb
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	role, err := h.rbac.GetKBRole(ctx, userID, kbID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get app detail failed", err)
+	}
+	if !role.Allows(domain.RoleAdmin) {
+		app.Settings.HideSecrets()
+	}
 	return h.NewResponseWithData(c, app)
 }
 
@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type AppAPIKeyHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.AppAPIKeyUsecase
+}
+
+func NewAppAPIKeyHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.AppAPIKeyUsecase) *AppAPIKeyHandler {
+	h := &AppAPIKeyHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.app_api_key"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/app/api_key", h.auth.Authorize)
+	group.GET("/list", h.GetAPIKeyList)
+	group.POST("", h.CreateAPIKey)
+	group.DELETE("", h.DeleteAPIKey)
+
+	return h
+}
+
+// GetAPIKeyList
+//
+//	@Summary		GetAPIKeyList
+//	@Description	list the openai compatible api keys of a knowledge base
+//	@Tags			app
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Success		200		{object}	domain.Response{data=[]domain.AppAPIKey}
+//	@Router			/api/v1/app/api_key/list [get]
+func (h *AppAPIKeyHandler) GetAPIKeyList(c echo.Context) error {
+	kbID := c.QueryParam("kb_id")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb id is required", nil)
+	}
+	keys, err := h.usecase.GetAPIKeyList(c.Request().Context(), kbID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get api key list failed", err)
+	}
+	return h.NewResponseWithData(c, keys)
+}
+
+// CreateAPIKey
+//
+//	@Summary		CreateAPIKey
+//	@Description	create an openai compatible api key of a knowledge base, the key is only returned once
+//	@Tags			app
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateAppAPIKeyReq	true	"api key"
+//	@Success		200		{object}	domain.Response{data=domain.CreateAppAPIKeyResp}
+//	@Router			/api/v1/app/api_key [post]
+func (h *AppAPIKeyHandler) CreateAPIKey(c echo.Context) error {
+	var req domain.CreateAppAPIKeyReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	key, err := h.usecase.CreateAPIKey(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create api key failed", err)
+	}
+	return h.NewResponseWithData(c, key)
+}
+
+// DeleteAPIKey
+//
+//	@Summary		DeleteAPIKey
+//	@Description	revoke an openai compatible api key
+//	@Tags			app
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Param			id		query		string	true	"api key id"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/app/api_key [delete]
+func (h *AppAPIKeyHandler) DeleteAPIKey(c echo.Context) error {
+	var req domain.DeleteAppAPIKeyReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.DeleteAPIKey(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "delete api key failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type AuditLogHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.AuditUsecase
+}
+
+func NewAuditLogHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.AuditUsecase) *AuditLogHandler {
+	h := &AuditLogHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.audit_log"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/audit_log", h.auth.Authorize)
+	group.GET("/list", h.GetAuditLogList)
+	group.GET("/export", h.ExportAuditLogs)
+
+	return h
+}
+
+type AuditLogListItems = domain.PaginatedResult[[]domain.AuditLog]
+
+// GetAuditLogList
+//
+//	@Summary		GetAuditLogList
+//	@Description	list the audit logs from the newest
+//	@Tags			audit_log
+//	@Produce		json
+//	@Param			params	query		domain.GetAuditLogListReq	true	"filters and pagination"
+//	@Success		200		{object}	domain.Response{data=AuditLogListItems}
+//	@Router			/api/v1/audit_log/list [get]
+func (h *AuditLogHandler) GetAuditLogList(c echo.Context) error {
+	var req domain.GetAuditLogListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	result, err := h.usecase.GetAuditLogList(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get audit log list failed", err)
+	}
+	return h.NewResponseWithData(c, result)
+}
+
+// ExportAuditLogs
+//
+//	@Summary		ExportAuditLogs
+//	@Description	export all matched audit logs as csv
+//	@Tags			audit_log
+//	@Produce		text/csv
+//	@Param			params	query	domain.AuditLogFilter	false	"filters"
+//	@Success		200		{file}	file
+//	@Router			/api/v1/audit_log/export [get]
+func (h *AuditLogHandler) ExportAuditLogs(c echo.Context) error {
+	var req domain.AuditLogFilter
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=audit_logs_%s.csv", time.Now().Format("20060102150405")))
+	c.Response().WriteHeader(http.StatusOK)
+	// the response is already started, a failure only truncates the file
+	if err := h.usecase.ExportAuditLogs(c.Request().Context(), &req, c.Response()); err != nil {
+		h.logger.Error("export audit logs failed", log.Error(err))
+	}
+	return nil
+}
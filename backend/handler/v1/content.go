@@ -12,6 +12,7 @@ import (
 
 	"github.com/chaitin/panda-wiki/handler"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
 	"github.com/chaitin/panda-wiki/usecase"
 	"github.com/cloudwego/eino/schema"
 	"github.com/labstack/echo/v4"
@@ -22,29 +23,32 @@ type ContentHandler struct {
 	llmUsecase   *usecase.LLMUsecase
 	modelUsecase *usecase.ModelUsecase
 	logger       *log.Logger
+	auth         middleware.AuthMiddleware
 }
 
-func NewContentHandler(baseHandler *handler.BaseHandler, e *echo.Echo, llmUsecase *usecase.LLMUsecase, modelUsecase *usecase.ModelUsecase, logger *log.Logger) *ContentHandler {
+func NewContentHandler(baseHandler *handler.BaseHandler, e *echo.Echo, llmUsecase *usecase.LLMUsecase, modelUsecase *usecase.ModelUsecase, logger *log.Logger, auth middleware.AuthMiddleware) *ContentHandler {
 	h := &ContentHandler{
 		BaseHandler:  baseHandler,
 		llmUsecase:   llmUsecase,
 		modelUsecase: modelUsecase,
 		logger:       logger.WithModule("handler.v1.content"),
+		auth:         auth,
 	}
 	
-	// 注册路由 - 暂时不使用认证中间件，用于测试
+	// 注册路由，跨域预检请求不需要认证
 	group := e.Group("/api/v1/content",
 		func(next echo.HandlerFunc) echo.HandlerFunc {
 			return func(c echo.Context) error {
 				c.Response().Header().Set("Access-Control-Allow-Origin", "*")
 				c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-				c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Origin, Accept, x-kb-id")
+				c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Origin, Accept, Authorization, x-kb-id")
 				if c.Request().Method == "OPTIONS" {
 					return c.NoContent(http.StatusOK)
 				}
 				return next(c)
 			}
-		})
+		},
+		h.auth.Authorize)
 	group.POST("/analyze", h.AnalyzeContent)
 	group.POST("/enhance", h.EnhanceContent)
 	
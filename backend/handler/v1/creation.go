@@ -8,6 +8,7 @@ import (
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/handler"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
 	"github.com/chaitin/panda-wiki/usecase"
 )
 
@@ -15,16 +16,18 @@ type CreationHandler struct {
 	*handler.BaseHandler
 	logger  *log.Logger
 	usecase *usecase.CreationUsecase
+	auth    middleware.AuthMiddleware
 }
 
-func NewCreationHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, usecase *usecase.CreationUsecase) *CreationHandler {
+func NewCreationHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, usecase *usecase.CreationUsecase, auth middleware.AuthMiddleware) *CreationHandler {
 	h := &CreationHandler{
 		BaseHandler: baseHandler,
 		logger:      logger.WithModule("handler.v1.creation"),
 		usecase:     usecase,
+		auth:        auth,
 	}
 
-	api := echo.Group("/api/v1/creation")
+	api := echo.Group("/api/v1/creation", h.auth.Authorize)
 	api.POST("/text", h.Text)
 
 	return h
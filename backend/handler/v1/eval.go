@@ -0,0 +1,228 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type EvalHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.EvalUsecase
+}
+
+func NewEvalHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.EvalUsecase) *EvalHandler {
+	h := &EvalHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.eval"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/eval", h.auth.Authorize)
+	// eval set
+	group.POST("/set", h.CreateEvalSet)
+	group.GET("/set/list", h.GetEvalSetList)
+	group.GET("/set/detail", h.GetEvalSetDetail)
+	group.PUT("/set/detail", h.UpdateEvalSet)
+	group.DELETE("/set/detail", h.DeleteEvalSet)
+	// run and report
+	group.POST("/run", h.RunEvalSet)
+	group.GET("/report/list", h.GetEvalReportList)
+	group.GET("/report/detail", h.GetEvalReportDetail)
+
+	return h
+}
+
+// CreateEvalSet
+//
+//	@Summary		CreateEvalSet
+//	@Description	create retrieval eval set of a knowledge base
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateEvalSetReq	true	"CreateEvalSet Request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/set [post]
+func (h *EvalHandler) CreateEvalSet(c echo.Context) error {
+	var req domain.CreateEvalSetReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	id, err := h.usecase.CreateEvalSet(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create eval set failed", err)
+	}
+	return h.NewResponseWithData(c, map[string]string{
+		"id": id,
+	})
+}
+
+// GetEvalSetList
+//
+//	@Summary		GetEvalSetList
+//	@Description	get eval set list of a knowledge base
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Success		200		{object}	domain.Response{data=[]domain.EvalSetListItem}
+//	@Router			/api/v1/eval/set/list [get]
+func (h *EvalHandler) GetEvalSetList(c echo.Context) error {
+	kbID := c.QueryParam("kb_id")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb id is required", nil)
+	}
+	sets, err := h.usecase.GetEvalSetList(c.Request().Context(), kbID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get eval set list failed", err)
+	}
+	return h.NewResponseWithData(c, sets)
+}
+
+// GetEvalSetDetail
+//
+//	@Summary		GetEvalSetDetail
+//	@Description	get eval set detail with cases
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	query		string	true	"eval set id"
+//	@Success		200	{object}	domain.Response{data=domain.EvalSet}
+//	@Router			/api/v1/eval/set/detail [get]
+func (h *EvalHandler) GetEvalSetDetail(c echo.Context) error {
+	id := c.QueryParam("id")
+	if id == "" {
+		return h.NewResponseWithError(c, "eval set id is required", nil)
+	}
+	set, err := h.usecase.GetEvalSet(c.Request().Context(), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "get eval set failed", err)
+	}
+	return h.NewResponseWithData(c, set)
+}
+
+// UpdateEvalSet
+//
+//	@Summary		UpdateEvalSet
+//	@Description	update eval set, cases are replaced as a whole
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.UpdateEvalSetReq	true	"UpdateEvalSet Request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/eval/set/detail [put]
+func (h *EvalHandler) UpdateEvalSet(c echo.Context) error {
+	var req domain.UpdateEvalSetReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.UpdateEvalSet(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "update eval set failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteEvalSet
+//
+//	@Summary		DeleteEvalSet
+//	@Description	delete eval set
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	query		string	true	"eval set id"
+//	@Success		200	{object}	domain.Response
+//	@Router			/api/v1/eval/set/detail [delete]
+func (h *EvalHandler) DeleteEvalSet(c echo.Context) error {
+	id := c.QueryParam("id")
+	if id == "" {
+		return h.NewResponseWithError(c, "eval set id is required", nil)
+	}
+	if err := h.usecase.DeleteEvalSet(c.Request().Context(), id); err != nil {
+		return h.NewResponseWithError(c, "delete eval set failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// RunEvalSet
+//
+//	@Summary		RunEvalSet
+//	@Description	run eval set against the current retrieval pipeline and save the report
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.RunEvalSetReq	true	"RunEvalSet Request"
+//	@Success		200		{object}	domain.Response{data=domain.EvalReport}
+//	@Router			/api/v1/eval/run [post]
+func (h *EvalHandler) RunEvalSet(c echo.Context) error {
+	var req domain.RunEvalSetReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	report, err := h.usecase.RunEvalSet(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "run eval set failed", err)
+	}
+	return h.NewResponseWithData(c, report)
+}
+
+// GetEvalReportList
+//
+//	@Summary		GetEvalReportList
+//	@Description	get eval report list of an eval set
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	query		domain.GetEvalReportListReq	true	"GetEvalReportList Request"
+//	@Success		200	{object}	domain.Response{data=domain.GetEvalReportListResp}
+//	@Router			/api/v1/eval/report/list [get]
+func (h *EvalHandler) GetEvalReportList(c echo.Context) error {
+	var req domain.GetEvalReportListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	resp, err := h.usecase.GetEvalReportList(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get eval report list failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// GetEvalReportDetail
+//
+//	@Summary		GetEvalReportDetail
+//	@Description	get eval report detail with per question results
+//	@Tags			eval
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	query		string	true	"eval report id"
+//	@Success		200	{object}	domain.Response{data=domain.EvalReport}
+//	@Router			/api/v1/eval/report/detail [get]
+func (h *EvalHandler) GetEvalReportDetail(c echo.Context) error {
+	id := c.QueryParam("id")
+	if id == "" {
+		return h.NewResponseWithError(c, "eval report id is required", nil)
+	}
+	report, err := h.usecase.GetEvalReport(c.Request().Context(), id)
+	if err != nil {
+		return h.NewResponseWithError(c, "get eval report failed", err)
+	}
+	return h.NewResponseWithData(c, report)
+}
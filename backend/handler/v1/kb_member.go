@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type KBMemberHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.RBACUsecase
+}
+
+func NewKBMemberHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.RBACUsecase) *KBMemberHandler {
+	h := &KBMemberHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.kb_member"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/knowledge_base/member", h.auth.Authorize)
+	group.GET("/list", h.GetMemberList)
+	group.POST("", h.UpsertMember)
+	group.DELETE("", h.DeleteMember)
+
+	return h
+}
+
+// GetMemberList
+//
+//	@Summary		GetMemberList
+//	@Description	list the members and their roles of a knowledge base
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Success		200		{object}	domain.Response{data=[]domain.KBMemberListItem}
+//	@Router			/api/v1/knowledge_base/member/list [get]
+func (h *KBMemberHandler) GetMemberList(c echo.Context) error {
+	var req domain.GetKBMemberListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	members, err := h.usecase.GetMemberList(c.Request().Context(), req.KBID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get member list failed", err)
+	}
+	return h.NewResponseWithData(c, members)
+}
+
+// UpsertMember
+//
+//	@Summary		UpsertMember
+//	@Description	add a member to a knowledge base or change the role of the member
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.UpsertKBMemberReq	true	"member"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/member [post]
+func (h *KBMemberHandler) UpsertMember(c echo.Context) error {
+	var req domain.UpsertKBMemberReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if err := h.usecase.UpsertMember(c.Request().Context(), userID, &req); err != nil {
+		return h.NewResponseWithError(c, "upsert member failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// DeleteMember
+//
+//	@Summary		DeleteMember
+//	@Description	remove a member from a knowledge base
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Param			user_id	query		string	true	"user id"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/member [delete]
+func (h *KBMemberHandler) DeleteMember(c echo.Context) error {
+	var req domain.DeleteKBMemberReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if err := h.usecase.DeleteMember(c.Request().Context(), userID, &req); err != nil {
+		return h.NewResponseWithError(c, "delete member failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
@@ -19,6 +19,8 @@ type KnowledgeBaseHandler struct {
 	llmUsecase *usecase.LLMUsecase
 	logger     *log.Logger
 	auth       middleware.AuthMiddleware
+	rbac       *usecase.RBACUsecase
+	readerAuth *usecase.ReaderAuthUsecase
 }
 
 func NewKnowledgeBaseHandler(
@@ -27,6 +29,8 @@ func NewKnowledgeBaseHandler(
 	usecase *usecase.KnowledgeBaseUsecase,
 	llmUsecase *usecase.LLMUsecase,
 	auth middleware.AuthMiddleware,
+	rbac *usecase.RBACUsecase,
+	readerAuth *usecase.ReaderAuthUsecase,
 	logger *log.Logger,
 ) *KnowledgeBaseHandler {
 	h := &KnowledgeBaseHandler{
@@ -35,6 +39,8 @@ func NewKnowledgeBaseHandler(
 		usecase:     usecase,
 		llmUsecase:  llmUsecase,
 		auth:        auth,
+		rbac:        rbac,
+		readerAuth:  readerAuth,
 	}
 
 	group := echo.Group("/api/v1/knowledge_base", h.auth.Authorize)
@@ -46,6 +52,13 @@ func NewKnowledgeBaseHandler(
 	// release
 	group.POST("/release", h.CreateKBRelease)
 	group.GET("/release/list", h.GetKBReleaseList)
+	// share links of the readers
+	group.POST("/share_link", h.CreateShareLink)
+	group.DELETE("/share_link", h.RevokeShareLinks)
+
+	kbGroup := echo.Group("/api/v1/kb", h.auth.Authorize)
+	// retrieval playground
+	kbGroup.POST("/retrieval/test", h.RetrievalTest)
 
 	return h
 }
@@ -99,17 +112,33 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c echo.Context) error {
 // GetKnowledgeBaseList
 //
 //	@Summary		GetKnowledgeBaseList
-//	@Description	GetKnowledgeBaseList
+//	@Description	GetKnowledgeBaseList, only the knowledge bases the user is a member of without a global role
 //	@Tags			knowledge_base
 //	@Accept			json
 //	@Produce		json
 //	@Success		200	{object}	domain.Response{data=[]domain.KnowledgeBaseListItem}
 //	@Router			/api/v1/knowledge_base/list [get]
 func (h *KnowledgeBaseHandler) GetKnowledgeBaseList(c echo.Context) error {
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
 	knowledgeBases, err := h.usecase.GetKnowledgeBaseList(c.Request().Context())
 	if err != nil {
 		return h.NewResponseWithError(c, "failed to get knowledge base list", err)
 	}
+	kbIDs, all, err := h.rbac.GetAccessibleKBIDs(c.Request().Context(), userID)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get knowledge base list", err)
+	}
+	if !all {
+		knowledgeBases = lo.Filter(knowledgeBases, func(kb *domain.KnowledgeBaseListItem, _ int) bool {
+			return kbIDs[kb.ID]
+		})
+	}
+	for _, kb := range knowledgeBases {
+		kb.AccessSettings.HideReaderSecrets()
+	}
 
 	return h.NewResponseWithData(c, knowledgeBases)
 }
@@ -168,10 +197,59 @@ func (h *KnowledgeBaseHandler) GetKnowledgeBaseDetail(c echo.Context) error {
 	if err != nil {
 		return h.NewResponseWithError(c, "failed to get knowledge base detail", err)
 	}
+	kb.AccessSettings.HideReaderSecrets()
 
 	return h.NewResponseWithData(c, kb)
 }
 
+// CreateShareLink
+//
+//	@Summary		CreateShareLink
+//	@Description	sign an expiring link to read the wiki, requires the share link reader auth
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateShareLinkReq	true	"share link"
+//	@Success		200		{object}	domain.Response{data=domain.ShareLinkResp}
+//	@Router			/api/v1/knowledge_base/share_link [post]
+func (h *KnowledgeBaseHandler) CreateShareLink(c echo.Context) error {
+	var req domain.CreateShareLinkReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	resp, err := h.readerAuth.CreateShareLink(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create share link failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// RevokeShareLinks
+//
+//	@Summary		RevokeShareLinks
+//	@Description	revoke all share links of the kb and the sessions of the readers
+//	@Tags			knowledge_base
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"kb id"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_base/share_link [delete]
+func (h *KnowledgeBaseHandler) RevokeShareLinks(c echo.Context) error {
+	var req domain.RevokeShareLinksReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.RevokeShareLinks(c.Request().Context(), req.KBID); err != nil {
+		return h.NewResponseWithError(c, "revoke share links failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
 // DeleteKnowledgeBase
 //
 //	@Summary		DeleteKnowledgeBase
@@ -251,3 +329,28 @@ func (h *KnowledgeBaseHandler) GetKBReleaseList(c echo.Context) error {
 
 	return h.NewResponseWithData(c, resp)
 }
+
+// RetrievalTest
+//
+//	@Summary		RetrievalTest
+//	@Description	retrieve documents and render prompt for a question without calling the chat model
+//	@Tags			knowledge_base
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.RetrievalTestReq	true	"RetrievalTest Request"
+//	@Success		200		{object}	domain.Response{data=domain.RetrievalTestResp}
+//	@Router			/api/v1/kb/retrieval/test [post]
+func (h *KnowledgeBaseHandler) RetrievalTest(c echo.Context) error {
+	var req domain.RetrievalTestReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	resp, err := h.llmUsecase.RetrievalTest(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "retrieval test failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
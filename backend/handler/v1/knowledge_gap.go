@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type KnowledgeGapHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.KnowledgeGapUsecase
+}
+
+func NewKnowledgeGapHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.KnowledgeGapUsecase) *KnowledgeGapHandler {
+	h := &KnowledgeGapHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.knowledge_gap"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/knowledge_gap", h.auth.Authorize)
+	group.GET("/report", h.GetKnowledgeGapReport)
+	group.POST("/draft", h.CreateGapDraftNode)
+
+	return h
+}
+
+// GetKnowledgeGapReport
+//
+//	@Summary		GetKnowledgeGapReport
+//	@Description	get clusters of unanswered questions ranked by frequency
+//	@Tags			knowledge_gap
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	query		domain.KnowledgeGapReportReq	true	"GetKnowledgeGapReport Request"
+//	@Success		200	{object}	domain.Response{data=domain.KnowledgeGapReport}
+//	@Router			/api/v1/knowledge_gap/report [get]
+func (h *KnowledgeGapHandler) GetKnowledgeGapReport(c echo.Context) error {
+	var req domain.KnowledgeGapReportReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	report, err := h.usecase.GetReport(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "get knowledge gap report failed", err)
+	}
+	return h.NewResponseWithData(c, report)
+}
+
+// CreateGapDraftNode
+//
+//	@Summary		CreateGapDraftNode
+//	@Description	create a private draft document pre-filled with the unanswered questions of a cluster
+//	@Tags			knowledge_gap
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.CreateGapDraftNodeReq	true	"CreateGapDraftNode Request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/knowledge_gap/draft [post]
+func (h *KnowledgeGapHandler) CreateGapDraftNode(c echo.Context) error {
+	var req domain.CreateGapDraftNodeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	nodeID, err := h.usecase.CreateDraftNode(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "create draft node failed", err)
+	}
+	return h.NewResponseWithData(c, map[string]string{
+		"id": nodeID,
+	})
+}
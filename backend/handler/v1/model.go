@@ -17,15 +17,17 @@ type ModelHandler struct {
 	auth       middleware.AuthMiddleware
 	usecase    *usecase.ModelUsecase
 	llmUsecase *usecase.LLMUsecase
+	rbac       *usecase.RBACUsecase
 }
 
-func NewModelHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.ModelUsecase, llmUsecase *usecase.LLMUsecase) *ModelHandler {
+func NewModelHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.ModelUsecase, llmUsecase *usecase.LLMUsecase, rbac *usecase.RBACUsecase) *ModelHandler {
 	handler := &ModelHandler{
 		BaseHandler: baseHandler,
 		logger:      logger.WithModule("handler.v1.model"),
 		auth:        auth,
 		usecase:     usecase,
 		llmUsecase:  llmUsecase,
+		rbac:        rbac,
 	}
 	group := echo.Group("/api/v1/model", handler.auth.Authorize)
 	group.GET("/list", handler.GetModelList)
@@ -55,10 +57,32 @@ func (h *ModelHandler) GetModelList(c echo.Context) error {
 	if err != nil {
 		return h.NewResponseWithError(c, "get model list failed", err)
 	}
+	canManage, err := h.canManageModels(c)
+	if err != nil {
+		return h.NewResponseWithError(c, "get model list failed", err)
+	}
+	if !canManage {
+		for _, model := range models {
+			model.HideSecrets()
+		}
+	}
 
 	return h.NewResponseWithData(c, models)
 }
 
+// canManageModels only the global admins see the api keys of the providers, the others only pick the models
+func (h *ModelHandler) canManageModels(c echo.Context) (bool, error) {
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return false, nil
+	}
+	role, err := h.rbac.GetGlobalRole(c.Request().Context(), userID)
+	if err != nil {
+		return false, err
+	}
+	return role.Allows(domain.RoleAdmin), nil
+}
+
 // get model detail
 //
 //	@Summary		get model detail
@@ -79,6 +103,13 @@ func (h *ModelHandler) GetModelDetail(c echo.Context) error {
 	if err != nil {
 		return h.NewResponseWithError(c, "get model detail failed", err)
 	}
+	canManage, err := h.canManageModels(c)
+	if err != nil {
+		return h.NewResponseWithError(c, "get model detail failed", err)
+	}
+	if !canManage {
+		model.HideSecrets()
+	}
 
 	return h.NewResponseWithData(c, model)
 }
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type OIDCHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	usecase *usecase.OIDCUsecase
+}
+
+func NewOIDCHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, usecase *usecase.OIDCUsecase) *OIDCHandler {
+	h := &OIDCHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.oidc"),
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/user/oidc")
+	group.GET("/login", h.Login)
+	group.GET("/callback", h.Callback)
+
+	return h
+}
+
+// Login
+//
+//	@Summary		OIDCLogin
+//	@Description	redirect to the identity provider to login the admin console
+//	@Tags			user
+//	@Param			redirect	query	string	false	"console path to return to after login"
+//	@Success		302
+//	@Router			/api/v1/user/oidc/login [get]
+func (h *OIDCHandler) Login(c echo.Context) error {
+	authURL, err := h.usecase.AuthURL(c.Request().Context(), c.QueryParam("redirect"))
+	if err != nil {
+		return h.NewResponseWithError(c, "oidc login failed", err)
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback
+//
+//	@Summary		OIDCCallback
+//	@Description	callback of the identity provider, redirects to the console with the token or the two_factor_token in the url fragment
+//	@Tags			user
+//	@Param			code	query	string	true	"authorization code"
+//	@Param			state	query	string	true	"state"
+//	@Success		302
+//	@Router			/api/v1/user/oidc/callback [get]
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	if errMsg := c.QueryParam("error"); errMsg != "" {
+		return h.NewResponseWithError(c, "oidc login failed: "+errMsg, nil)
+	}
+	resp, redirect, err := h.usecase.Login(c.Request().Context(), c.QueryParam("code"), c.QueryParam("state"), sessionClient(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "oidc login failed", err)
+	}
+	fragment := url.Values{}
+	if resp.TwoFactorRequired {
+		fragment.Set("two_factor_token", resp.TwoFactorToken)
+		fragment.Set("two_factor_enroll_required", strconv.FormatBool(resp.TwoFactorEnrollRequired))
+	} else {
+		fragment.Set("token", resp.Token)
+	}
+	return c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+}
@@ -19,6 +19,16 @@ type APIHandlers struct {
 	CrawlerHandler       *CrawlerHandler
 	CreationHandler      *CreationHandler
 	ContentHandler       *ContentHandler
+	EvalHandler          *EvalHandler
+	QuotaHandler         *QuotaHandler
+	KnowledgeGapHandler  *KnowledgeGapHandler
+	AppAPIKeyHandler     *AppAPIKeyHandler
+	APITokenHandler      *APITokenHandler
+	KBMemberHandler      *KBMemberHandler
+	OIDCHandler          *OIDCHandler
+	TwoFactorHandler     *TwoFactorHandler
+	SessionHandler       *SessionHandler
+	AuditLogHandler      *AuditLogHandler
 }
 
 var ProviderSet = wire.NewSet(
@@ -36,6 +46,16 @@ var ProviderSet = wire.NewSet(
 	NewCrawlerHandler,
 	NewCreationHandler,
 	NewContentHandler,
+	NewEvalHandler,
+	NewQuotaHandler,
+	NewKnowledgeGapHandler,
+	NewAppAPIKeyHandler,
+	NewAPITokenHandler,
+	NewKBMemberHandler,
+	NewOIDCHandler,
+	NewTwoFactorHandler,
+	NewSessionHandler,
+	NewAuditLogHandler,
 
 	wire.Struct(new(APIHandlers), "*"),
 )
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type QuotaHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.QuotaUsecase
+}
+
+func NewQuotaHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.QuotaUsecase) *QuotaHandler {
+	h := &QuotaHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.quota"),
+		auth:        auth,
+		usecase:     usecase,
+	}
+	group := echo.Group("/api/v1/quota", h.auth.Authorize)
+	group.GET("/usage", h.GetQuotaUsage)
+
+	return h
+}
+
+// GetQuotaUsage
+//
+//	@Summary		GetQuotaUsage
+//	@Description	get today's token and request consumption of a knowledge base and its apps
+//	@Tags			quota
+//	@Accept			json
+//	@Produce		json
+//	@Param			kb_id	query		string	true	"knowledge base id"
+//	@Success		200		{object}	domain.Response{data=domain.QuotaUsageResp}
+//	@Router			/api/v1/quota/usage [get]
+func (h *QuotaHandler) GetQuotaUsage(c echo.Context) error {
+	kbID := c.QueryParam("kb_id")
+	if kbID == "" {
+		return h.NewResponseWithError(c, "kb id is required", nil)
+	}
+	usage, err := h.usecase.GetQuotaUsage(c.Request().Context(), kbID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get quota usage failed", err)
+	}
+	return h.NewResponseWithData(c, usage)
+}
@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type SessionHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.SessionUsecase
+	rbac    *usecase.RBACUsecase
+}
+
+func NewSessionHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.SessionUsecase, rbac *usecase.RBACUsecase) *SessionHandler {
+	h := &SessionHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.session"),
+		auth:        auth,
+		usecase:     usecase,
+		rbac:        rbac,
+	}
+	group := echo.Group("/api/v1/user", h.auth.Authorize)
+	group.GET("/session/list", h.GetSessionList)
+	group.DELETE("/session", h.RevokeSession)
+	group.DELETE("/session/all", h.RevokeAllSessions)
+	group.POST("/logout", h.Logout)
+
+	return h
+}
+
+// sessionClient the client of the login request
+func sessionClient(c echo.Context) *domain.SessionClient {
+	return &domain.SessionClient{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
+// GetSessionList
+//
+//	@Summary		GetSessionList
+//	@Description	list the login sessions of the current user, or of another user by admins
+//	@Tags			user
+//	@Produce		json
+//	@Param			user_id	query		string	false	"user id"
+//	@Success		200		{object}	domain.Response{data=[]domain.SessionListItem}
+//	@Router			/api/v1/user/session/list [get]
+func (h *SessionHandler) GetSessionList(c echo.Context) error {
+	var req domain.GetSessionListReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if req.UserID != "" && req.UserID != userID {
+		if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, req.UserID); err != nil {
+			return h.NewResponseWithError(c, "只有管理员可以查看其他用户的会话", err)
+		}
+		userID = req.UserID
+	}
+	sessions, err := h.usecase.GetSessionList(c.Request().Context(), userID, h.auth.GetSessionID(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "get session list failed", err)
+	}
+	return h.NewResponseWithData(c, sessions)
+}
+
+// RevokeSession
+//
+//	@Summary		RevokeSession
+//	@Description	revoke a session of the current user, or of another user by admins
+//	@Tags			user
+//	@Produce		json
+//	@Param			id	query		string	true	"session id"
+//	@Success		200	{object}	domain.Response
+//	@Router			/api/v1/user/session [delete]
+func (h *SessionHandler) RevokeSession(c echo.Context) error {
+	var req domain.RevokeSessionReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	session, err := h.usecase.GetSession(c.Request().Context(), req.ID)
+	if err != nil {
+		return h.NewResponseWithError(c, "get session failed", err)
+	}
+	if session == nil {
+		return h.NewResponseWithError(c, "session not found", usecase.ErrSessionNotFound)
+	}
+	if session.UserID != userID {
+		if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, session.UserID); err != nil {
+			return h.NewResponseWithError(c, "只有管理员可以注销其他用户的会话", err)
+		}
+	}
+	if err := h.usecase.RevokeSession(c.Request().Context(), session); err != nil {
+		return h.NewResponseWithError(c, "revoke session failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// RevokeAllSessions
+//
+//	@Summary		RevokeAllSessions
+//	@Description	revoke the other sessions of the current user, or all sessions of another user by admins
+//	@Tags			user
+//	@Produce		json
+//	@Param			user_id	query		string	false	"user id"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/user/session/all [delete]
+func (h *SessionHandler) RevokeAllSessions(c echo.Context) error {
+	var req domain.RevokeAllSessionsReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	var err error
+	if req.UserID == "" || req.UserID == userID {
+		err = h.usecase.RevokeAllSessions(c.Request().Context(), userID, h.auth.GetSessionID(c))
+	} else {
+		if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, req.UserID); err != nil {
+			return h.NewResponseWithError(c, "只有管理员可以注销其他用户的会话", err)
+		}
+		err = h.usecase.RevokeAllSessions(c.Request().Context(), req.UserID)
+	}
+	if err != nil {
+		return h.NewResponseWithError(c, "revoke sessions failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// Logout
+//
+//	@Summary		Logout
+//	@Description	revoke the current session
+//	@Tags			user
+//	@Produce		json
+//	@Success		200	{object}	domain.Response
+//	@Router			/api/v1/user/logout [post]
+func (h *SessionHandler) Logout(c echo.Context) error {
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	sessionID := h.auth.GetSessionID(c)
+	if sessionID == "" {
+		return h.NewResponseWithError(c, "api tokens can not logout", nil)
+	}
+	if err := h.usecase.RevokeSession(c.Request().Context(), &domain.Session{ID: sessionID, UserID: userID}); err != nil {
+		return h.NewResponseWithError(c, "logout failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
@@ -0,0 +1,267 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/handler"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/middleware"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type TwoFactorHandler struct {
+	*handler.BaseHandler
+	logger  *log.Logger
+	auth    middleware.AuthMiddleware
+	usecase *usecase.UserUsecase
+	rbac    *usecase.RBACUsecase
+}
+
+func NewTwoFactorHandler(echo *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, auth middleware.AuthMiddleware, usecase *usecase.UserUsecase, rbac *usecase.RBACUsecase) *TwoFactorHandler {
+	h := &TwoFactorHandler{
+		BaseHandler: baseHandler,
+		logger:      logger.WithModule("handler.v1.two_factor"),
+		auth:        auth,
+		usecase:     usecase,
+		rbac:        rbac,
+	}
+	// the second step of the login, authorized by the two factor token
+	login := echo.Group("/api/v1/user/login/2fa")
+	login.POST("", h.LoginTwoFactor)
+	login.POST("/enroll", h.LoginEnrollTwoFactor)
+
+	group := echo.Group("/api/v1/user", h.auth.Authorize)
+	group.POST("/2fa/enroll", h.EnrollTwoFactor)
+	group.POST("/2fa/enable", h.EnableTwoFactor)
+	group.POST("/2fa/disable", h.DisableTwoFactor)
+	group.POST("/2fa/recovery_codes", h.RegenerateRecoveryCodes)
+	group.DELETE("/2fa", h.ResetTwoFactor)
+	group.GET("/security_setting", h.GetSecuritySetting)
+	group.PUT("/security_setting", h.UpdateSecuritySetting)
+
+	return h
+}
+
+// LoginTwoFactor
+//
+//	@Summary		LoginTwoFactor
+//	@Description	verify the totp or recovery code of the login, the first code of a user enrolling during the login enables 2fa
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.TwoFactorLoginReq	true	"two factor code"
+//	@Success		200		{object}	domain.Response{data=domain.TwoFactorLoginResp}
+//	@Router			/api/v1/user/login/2fa [post]
+func (h *TwoFactorHandler) LoginTwoFactor(c echo.Context) error {
+	var req domain.TwoFactorLoginReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	resp, err := h.usecase.LoginTwoFactor(c.Request().Context(), &req, sessionClient(c))
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to login", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// LoginEnrollTwoFactor
+//
+//	@Summary		LoginEnrollTwoFactor
+//	@Description	enroll totp during the login when 2fa is required but not enrolled yet
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.TwoFactorLoginEnrollReq	true	"two factor token"
+//	@Success		200		{object}	domain.Response{data=domain.TwoFactorEnrollResp}
+//	@Router			/api/v1/user/login/2fa/enroll [post]
+func (h *TwoFactorHandler) LoginEnrollTwoFactor(c echo.Context) error {
+	var req domain.TwoFactorLoginEnrollReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	resp, err := h.usecase.LoginEnrollTwoFactor(c.Request().Context(), &req)
+	if err != nil {
+		return h.NewResponseWithError(c, "enroll two factor failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// EnrollTwoFactor
+//
+//	@Summary		EnrollTwoFactor
+//	@Description	generate the totp secret and qr code of the current user, enabled by /api/v1/user/2fa/enable
+//	@Tags			user
+//	@Produce		json
+//	@Success		200	{object}	domain.Response{data=domain.TwoFactorEnrollResp}
+//	@Router			/api/v1/user/2fa/enroll [post]
+func (h *TwoFactorHandler) EnrollTwoFactor(c echo.Context) error {
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	resp, err := h.usecase.EnrollTwoFactor(c.Request().Context(), userID)
+	if err != nil {
+		return h.NewResponseWithError(c, "enroll two factor failed", err)
+	}
+	return h.NewResponseWithData(c, resp)
+}
+
+// EnableTwoFactor
+//
+//	@Summary		EnableTwoFactor
+//	@Description	confirm the enrollment by a totp code, the recovery codes are only returned once
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.TwoFactorCodeReq	true	"totp code"
+//	@Success		200		{object}	domain.Response{data=domain.TwoFactorRecoveryCodesResp}
+//	@Router			/api/v1/user/2fa/enable [post]
+func (h *TwoFactorHandler) EnableTwoFactor(c echo.Context) error {
+	var req domain.TwoFactorCodeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	codes, err := h.usecase.EnableTwoFactor(c.Request().Context(), userID, req.Code)
+	if err != nil {
+		return h.NewResponseWithError(c, "enable two factor failed", err)
+	}
+	return h.NewResponseWithData(c, domain.TwoFactorRecoveryCodesResp{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor
+//
+//	@Summary		DisableTwoFactor
+//	@Description	disable 2fa of the current user by a totp or recovery code
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.TwoFactorCodeReq	true	"totp or recovery code"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/user/2fa/disable [post]
+func (h *TwoFactorHandler) DisableTwoFactor(c echo.Context) error {
+	var req domain.TwoFactorCodeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if err := h.usecase.DisableTwoFactor(c.Request().Context(), userID, req.Code); err != nil {
+		return h.NewResponseWithError(c, "disable two factor failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// RegenerateRecoveryCodes
+//
+//	@Summary		RegenerateRecoveryCodes
+//	@Description	replace the recovery codes of the current user, the old codes stop working
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.TwoFactorCodeReq	true	"totp code"
+//	@Success		200		{object}	domain.Response{data=domain.TwoFactorRecoveryCodesResp}
+//	@Router			/api/v1/user/2fa/recovery_codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	var req domain.TwoFactorCodeReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	codes, err := h.usecase.RegenerateRecoveryCodes(c.Request().Context(), userID, req.Code)
+	if err != nil {
+		return h.NewResponseWithError(c, "regenerate recovery codes failed", err)
+	}
+	return h.NewResponseWithData(c, domain.TwoFactorRecoveryCodesResp{RecoveryCodes: codes})
+}
+
+// ResetTwoFactor
+//
+//	@Summary		ResetTwoFactor
+//	@Description	clear 2fa of a user who lost the device, the user enrolls again on the next login if 2fa is required
+//	@Tags			user
+//	@Produce		json
+//	@Param			user_id	query		string	true	"user id"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/user/2fa [delete]
+func (h *TwoFactorHandler) ResetTwoFactor(c echo.Context) error {
+	var req domain.ResetTwoFactorReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, req.UserID); err != nil {
+		return h.NewResponseWithError(c, "只有管理员可以重置其他用户的两步验证", err)
+	}
+	if err := h.usecase.ResetTwoFactor(c.Request().Context(), req.UserID); err != nil {
+		return h.NewResponseWithError(c, "reset two factor failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
+
+// GetSecuritySetting
+//
+//	@Summary		GetSecuritySetting
+//	@Description	GetSecuritySetting
+//	@Tags			user
+//	@Produce		json
+//	@Success		200	{object}	domain.Response{data=domain.SecuritySetting}
+//	@Router			/api/v1/user/security_setting [get]
+func (h *TwoFactorHandler) GetSecuritySetting(c echo.Context) error {
+	setting, err := h.usecase.GetSecuritySetting(c.Request().Context())
+	if err != nil {
+		return h.NewResponseWithError(c, "get security setting failed", err)
+	}
+	return h.NewResponseWithData(c, setting)
+}
+
+// UpdateSecuritySetting
+//
+//	@Summary		UpdateSecuritySetting
+//	@Description	require 2fa of all users logging in with a password
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.SecuritySetting	true	"security setting"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/user/security_setting [put]
+func (h *TwoFactorHandler) UpdateSecuritySetting(c echo.Context) error {
+	var req domain.SecuritySetting
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+	if err := h.usecase.UpdateSecuritySetting(c.Request().Context(), &req); err != nil {
+		return h.NewResponseWithError(c, "update security setting failed", err)
+	}
+	return h.NewResponseWithData(c, nil)
+}
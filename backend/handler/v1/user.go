@@ -18,24 +18,30 @@ type UserHandler struct {
 	logger  *log.Logger
 	config  *config.Config
 	auth    middleware.AuthMiddleware
+	rbac    *usecase.RBACUsecase
+	ldap    *usecase.LDAPUsecase
 }
 
-func NewUserHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, usecase *usecase.UserUsecase, auth middleware.AuthMiddleware, config *config.Config) *UserHandler {
+func NewUserHandler(e *echo.Echo, baseHandler *handler.BaseHandler, logger *log.Logger, usecase *usecase.UserUsecase, auth middleware.AuthMiddleware, rbac *usecase.RBACUsecase, ldap *usecase.LDAPUsecase, config *config.Config) *UserHandler {
 	h := &UserHandler{
 		BaseHandler: baseHandler,
 		logger:      logger.WithModule("handler.v1.user"),
 		usecase:     usecase,
 		auth:        auth,
+		rbac:        rbac,
+		ldap:        ldap,
 		config:      config,
 	}
 	group := e.Group("/api/v1/user")
 	group.POST("/login", h.Login)
+	group.GET("/login/config", h.GetLoginConfig)
 
 	group.POST("/create", h.CreateUser, h.auth.Authorize)
 	group.GET("", h.GetUserInfo, h.auth.Authorize)
 	group.GET("/list", h.ListUsers, h.auth.Authorize)
 	group.PUT("/reset_password", h.ResetPassword, h.auth.Authorize)
 	group.DELETE("/delete", h.DeleteUser, h.auth.Authorize)
+	group.PUT("/role", h.UpdateUserRole, h.auth.Authorize)
 
 	return h
 }
@@ -60,10 +66,23 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		return h.NewResponseWithError(c, "invalid request", err)
 	}
 
-	err := h.usecase.CreateUser(c.Request().Context(), &domain.User{
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+	role, err := h.rbac.GetGlobalRole(c.Request().Context(), userID)
+	if err != nil {
+		return h.NewResponseWithError(c, "failed to get user", err)
+	}
+	if req.Role != "" && !role.Allows(req.Role) {
+		return h.NewResponseWithError(c, "不能创建角色高于自己的用户", nil)
+	}
+
+	err = h.usecase.CreateUser(c.Request().Context(), &domain.User{
 		ID:       uuid.New().String(),
 		Account:  req.Account,
 		Password: req.Password,
+		Role:     req.Role,
 	})
 	if err != nil {
 		return h.NewResponseWithError(c, "failed to create user", err)
@@ -75,7 +94,7 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 // Login
 //
 //	@Summary		Login
-//	@Description	Login
+//	@Description	Login, users with 2fa get two_factor_token for /api/v1/user/login/2fa instead of the token
 //	@Tags			user
 //	@Accept			json
 //	@Produce		json
@@ -92,12 +111,34 @@ func (h *UserHandler) Login(c echo.Context) error {
 		return h.NewResponseWithError(c, "invalid request", err)
 	}
 
-	token, err := h.usecase.VerifyUserAndGenerateToken(c.Request().Context(), req)
+	var resp *domain.LoginResp
+	var err error
+	if h.ldap.Enabled() {
+		resp, err = h.ldap.Login(c.Request().Context(), req, sessionClient(c))
+	} else {
+		resp, err = h.usecase.Login(c.Request().Context(), req, sessionClient(c))
+	}
 	if err != nil {
 		return h.NewResponseWithError(c, "failed to login", err)
 	}
 
-	return h.NewResponseWithData(c, domain.LoginResp{Token: token})
+	return h.NewResponseWithData(c, resp)
+}
+
+// GetLoginConfig
+//
+//	@Summary		GetLoginConfig
+//	@Description	login methods of the admin console
+//	@Tags			user
+//	@Produce		json
+//	@Success		200	{object}	domain.Response{data=domain.LoginConfigResp}
+//	@Router			/api/v1/user/login/config [get]
+func (h *UserHandler) GetLoginConfig(c echo.Context) error {
+	return h.NewResponseWithData(c, domain.LoginConfigResp{
+		LocalLogin: !h.config.Auth.DisableLocalLogin,
+		OIDC:       h.config.Auth.Type == "oidc",
+		LDAP:       h.ldap.Enabled(),
+	})
 }
 
 // GetUser
@@ -171,8 +212,10 @@ func (h *UserHandler) ResetPassword(c echo.Context) error {
 	if user.Account == "admin" && userID == req.ID {
 		return h.NewResponseWithError(c, "请修改安装目录下 .env 文件中的 ADMIN_PASSWORD，并重启 panda-wiki-api 容器使更改生效。", nil)
 	}
-	if user.Account != "admin" && userID != req.ID {
-		return h.NewResponseWithError(c, "只有管理员可以重置其他用户密码", nil)
+	if userID != req.ID {
+		if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, req.ID); err != nil {
+			return h.NewResponseWithError(c, "只有管理员可以重置其他用户密码", err)
+		}
 	}
 	err = h.usecase.ResetPassword(c.Request().Context(), &req)
 	if err != nil {
@@ -206,18 +249,46 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 		return h.NewResponseWithError(c, "cannot delete yourself", nil)
 	}
 
-	user, err := h.usecase.GetUser(c.Request().Context(), userID)
-	if err != nil {
-		return h.NewResponseWithError(c, "failed to get user", err)
-	}
-	if user.Account != "admin" {
-		return h.NewResponseWithError(c, "只有管理员可以删除用户", nil)
+	if err := h.rbac.CheckUserManageable(c.Request().Context(), userID, req.UserID); err != nil {
+		return h.NewResponseWithError(c, "只有管理员可以删除用户", err)
 	}
 
-	err = h.usecase.DeleteUser(c.Request().Context(), req.UserID)
+	err := h.usecase.DeleteUser(c.Request().Context(), req.UserID)
 	if err != nil {
 		return h.NewResponseWithError(c, "failed to delete user", err)
 	}
 
 	return h.NewResponseWithData(c, nil)
 }
+
+// UpdateUserRole
+//
+//	@Summary		UpdateUserRole
+//	@Description	set the global role of a user, which applies to all knowledge bases
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		domain.UpdateUserRoleReq	true	"UpdateUserRole Request"
+//	@Success		200		{object}	domain.Response
+//	@Router			/api/v1/user/role [put]
+func (h *UserHandler) UpdateUserRole(c echo.Context) error {
+	var req domain.UpdateUserRoleReq
+	if err := c.Bind(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return h.NewResponseWithError(c, "invalid request", err)
+	}
+
+	userID, ok := h.auth.MustGetUserID(c)
+	if !ok {
+		return h.NewResponseWithError(c, "failed to get user", nil)
+	}
+
+	if err := h.rbac.UpdateUserRole(c.Request().Context(), userID, &req); err != nil {
+		return h.NewResponseWithError(c, "failed to update user role", err)
+	}
+
+	return h.NewResponseWithData(c, nil)
+}
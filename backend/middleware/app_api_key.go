@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+const appContextKey = "app"
+
+// AppAPIKeyMiddleware authenticates the openai compatible api and the mcp server by the bearer api key of the app
+type AppAPIKeyMiddleware struct {
+	logger           *log.Logger
+	appAPIKeyUsecase *usecase.AppAPIKeyUsecase
+}
+
+func NewAppAPIKeyMiddleware(logger *log.Logger, appAPIKeyUsecase *usecase.AppAPIKeyUsecase) *AppAPIKeyMiddleware {
+	return &AppAPIKeyMiddleware{
+		logger:           logger.WithModule("middleware.app_api_key"),
+		appAPIKeyUsecase: appAPIKeyUsecase,
+	}
+}
+
+// Authorize accepts the keys of the apps of the type only
+func (m *AppAPIKeyMiddleware) Authorize(appType domain.AppType) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || key == "" {
+				return m.unauthorized(c, "missing api key")
+			}
+			app, err := m.appAPIKeyUsecase.Authenticate(c.Request().Context(), strings.TrimSpace(key), appType)
+			if err != nil {
+				m.logger.Error("api key auth failed", log.Error(err))
+				return m.unauthorized(c, "invalid api key")
+			}
+			c.Set(appContextKey, app)
+			return next(c)
+		}
+	}
+}
+
+func (m *AppAPIKeyMiddleware) MustGetApp(c echo.Context) (*domain.App, bool) {
+	app, ok := c.Get(appContextKey).(*domain.App)
+	return app, ok
+}
+
+func (m *AppAPIKeyMiddleware) unauthorized(c echo.Context, message string) error {
+	return c.JSON(http.StatusUnauthorized, domain.OpenAIErrorResp{
+		Error: domain.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    "invalid_api_key",
+		},
+	})
+}
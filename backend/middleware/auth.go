@@ -8,17 +8,21 @@ import (
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/usecase"
 )
 
 type AuthMiddleware interface {
 	Authorize(next echo.HandlerFunc) echo.HandlerFunc
 	MustGetUserID(c echo.Context) (string, bool)
+	// GetSessionID the session of the jwt, empty for api tokens
+	GetSessionID(c echo.Context) string
 }
 
-func NewAuthMiddleware(config *config.Config, logger *log.Logger, userAccessRepo *pg.UserAccessRepository) (AuthMiddleware, error) {
+func NewAuthMiddleware(config *config.Config, logger *log.Logger, userAccessRepo *pg.UserAccessRepository, apiTokenUsecase *usecase.APITokenUsecase, sessionUsecase *usecase.SessionUsecase, rbac *RBACMiddleware) (AuthMiddleware, error) {
 	switch config.Auth.Type {
-	case "jwt":
-		return NewJWTMiddleware(config, logger, userAccessRepo), nil
+	// users of identity providers login by the providers and get the same jwt
+	case "jwt", "oidc", "ldap":
+		return NewJWTMiddleware(config, logger, userAccessRepo, apiTokenUsecase, sessionUsecase, rbac), nil
 	default:
 		return nil, fmt.Errorf("invalid auth type: %s", config.Auth.Type)
 	}
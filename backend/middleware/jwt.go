@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	echoMiddleware "github.com/labstack/echo-jwt/v4"
@@ -11,16 +12,25 @@ import (
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+const (
+	apiTokenContextKey = "api_token"
+	sessionContextKey  = "session"
 )
 
 type JWTMiddleware struct {
-	config         *config.Config
-	jwtMiddleware  echo.MiddlewareFunc
-	logger         *log.Logger
-	userAccessRepo *pg.UserAccessRepository
+	config          *config.Config
+	jwtMiddleware   echo.MiddlewareFunc
+	logger          *log.Logger
+	userAccessRepo  *pg.UserAccessRepository
+	apiTokenUsecase *usecase.APITokenUsecase
+	sessionUsecase  *usecase.SessionUsecase
+	rbac            *RBACMiddleware
 }
 
-func NewJWTMiddleware(config *config.Config, logger *log.Logger, userAccessRepo *pg.UserAccessRepository) *JWTMiddleware {
+func NewJWTMiddleware(config *config.Config, logger *log.Logger, userAccessRepo *pg.UserAccessRepository, apiTokenUsecase *usecase.APITokenUsecase, sessionUsecase *usecase.SessionUsecase, rbac *RBACMiddleware) *JWTMiddleware {
 	jwtMiddleware := echoMiddleware.WithConfig(echoMiddleware.Config{
 		SigningKey: []byte(config.Auth.JWT.Secret),
 		ErrorHandler: func(c echo.Context, err error) error {
@@ -32,39 +42,105 @@ func NewJWTMiddleware(config *config.Config, logger *log.Logger, userAccessRepo
 		},
 	})
 	return &JWTMiddleware{
-		config:         config,
-		jwtMiddleware:  jwtMiddleware,
-		logger:         logger.WithModule("middleware.jwt"),
-		userAccessRepo: userAccessRepo,
+		config:          config,
+		jwtMiddleware:   jwtMiddleware,
+		logger:          logger.WithModule("middleware.jwt"),
+		userAccessRepo:  userAccessRepo,
+		apiTokenUsecase: apiTokenUsecase,
+		sessionUsecase:  sessionUsecase,
+		rbac:            rbac,
 	}
 }
 
 func (m *JWTMiddleware) Authorize(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		// First apply JWT middleware
-		if err := m.jwtMiddleware(next)(c); err != nil {
-			return err
+		// personal api tokens are accepted alongside jwt
+		if token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok && strings.HasPrefix(token, domain.APITokenPrefix) {
+			return m.authorizeAPIToken(next, c, token)
 		}
 
-		// If we get here, JWT authentication was successful
-		// Get user ID and update access time
-		if userID, ok := m.MustGetUserID(c); ok {
+		return m.jwtMiddleware(func(c echo.Context) error {
+			// If we get here, JWT authentication was successful
+			// Get user ID and update access time
+			userID, ok := m.MustGetUserID(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, domain.Response{
+					Success: false,
+					Message: "Unauthorized",
+				})
+			}
+			// the jwt is valid until expiry, the session is checked to honor logouts and revocations
+			session, err := m.sessionUsecase.Authenticate(c.Request().Context(), userID, m.jwtClaim(c, "jti"), &domain.SessionClient{
+				IP:        c.RealIP(),
+				UserAgent: c.Request().UserAgent(),
+			})
+			if err != nil {
+				m.logger.Error("session auth failed", log.String("user_id", userID), log.Error(err))
+				return c.JSON(http.StatusUnauthorized, domain.Response{
+					Success: false,
+					Message: "Unauthorized",
+				})
+			}
+			c.Set(sessionContextKey, session)
+			setAuditActor(c, &domain.AuditActor{UserID: userID})
 			m.userAccessRepo.UpdateAccessTime(userID)
-		}
+			return m.rbac.Authorize(userID, next)(c)
+		})(c)
+	}
+}
 
-		return nil
+func (m *JWTMiddleware) authorizeAPIToken(next echo.HandlerFunc, c echo.Context, plaintext string) error {
+	token, err := m.apiTokenUsecase.Authenticate(c.Request().Context(), plaintext, c.RealIP())
+	if err != nil {
+		m.logger.Error("api token auth failed", log.Error(err))
+		return c.JSON(http.StatusUnauthorized, domain.Response{
+			Success: false,
+			Message: "Unauthorized",
+		})
 	}
+	if !domain.APITokenAllows(token.Scopes, c.Request().Method, c.Path()) {
+		return c.JSON(http.StatusForbidden, domain.Response{
+			Success: false,
+			Message: "Forbidden",
+		})
+	}
+	c.Set(apiTokenContextKey, token)
+	setAuditActor(c, &domain.AuditActor{UserID: token.UserID, APITokenID: token.ID})
+	m.userAccessRepo.UpdateAccessTime(token.UserID)
+	return m.rbac.Authorize(token.UserID, next)(c)
+}
+
+// setAuditActor passes the actor of the request to the audit log of the usecases
+func setAuditActor(c echo.Context, actor *domain.AuditActor) {
+	actor.IP = c.RealIP()
+	actor.UserAgent = c.Request().UserAgent()
+	c.SetRequest(c.Request().WithContext(domain.WithAuditActor(c.Request().Context(), actor)))
 }
 
 func (m *JWTMiddleware) MustGetUserID(c echo.Context) (string, bool) {
+	if token, ok := c.Get(apiTokenContextKey).(*domain.APIToken); ok {
+		return token.UserID, true
+	}
+	id := m.jwtClaim(c, "id")
+	return id, id != ""
+}
+
+func (m *JWTMiddleware) GetSessionID(c echo.Context) string {
+	if session, ok := c.Get(sessionContextKey).(*domain.Session); ok {
+		return session.ID
+	}
+	return ""
+}
+
+func (m *JWTMiddleware) jwtClaim(c echo.Context, name string) string {
 	user, ok := c.Get("user").(*jwt.Token)
 	if !ok || user == nil {
-		return "", false
+		return ""
 	}
 	claims, ok := user.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", false
+		return ""
 	}
-	id, ok := claims["id"].(string)
-	return id, ok
+	value, _ := claims[name].(string)
+	return value
 }
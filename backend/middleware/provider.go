@@ -4,5 +4,7 @@ import "github.com/google/wire"
 
 var ProviderSet = wire.NewSet(
 	NewAuthMiddleware,
+	NewRBACMiddleware,
 	NewShareAuthMiddleware,
+	NewAppAPIKeyMiddleware,
 )
@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/usecase"
+)
+
+type routePermission struct {
+	domain.Permission
+	// param the request parameter of the kb or resource id, kb_id or id by default
+	param string
+}
+
+func permission(role domain.Role, target domain.PermissionTarget) routePermission {
+	return routePermission{Permission: domain.Permission{Role: role, Target: target}}
+}
+
+func (p routePermission) withParam(param string) routePermission {
+	p.param = param
+	return p
+}
+
+// defaultPermission routes missing from the table are restricted to global admins
+var defaultPermission = permission(domain.RoleAdmin, domain.PermissionTargetGlobal)
+
+// routePermissions the roles required by the admin api, keyed by method and route path
+var routePermissions = map[string]routePermission{
+	"GET /api/v1/app/detail": permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"PUT /api/v1/app":        permission(domain.RoleAdmin, domain.PermissionTargetApp),
+	"DELETE /api/v1/app":     permission(domain.RoleAdmin, domain.PermissionTargetApp),
+
+	"GET /api/v1/app/api_key/list": permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"POST /api/v1/app/api_key":     permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"DELETE /api/v1/app/api_key":   permission(domain.RoleAdmin, domain.PermissionTargetKB),
+
+	"POST /api/v1/content/analyze": permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/content/enhance": permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/creation/text":   permission(domain.RoleEditor, domain.PermissionTargetAny),
+
+	"GET /api/v1/conversation":        permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"GET /api/v1/conversation/detail": permission(domain.RoleViewer, domain.PermissionTargetConversation),
+
+	"POST /api/v1/crawler/parse_rss":       permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/crawler/parse_sitemap":   permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/crawler/scrape":          permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/crawler/notion/get_list": permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/crawler/notion/get_doc":  permission(domain.RoleEditor, domain.PermissionTargetAny),
+	"POST /api/v1/crawler/epub/convert":    permission(domain.RoleEditor, domain.PermissionTargetAny),
+
+	"POST /api/v1/eval/set":          permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"GET /api/v1/eval/set/list":      permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"GET /api/v1/eval/set/detail":    permission(domain.RoleViewer, domain.PermissionTargetEvalSet),
+	"PUT /api/v1/eval/set/detail":    permission(domain.RoleAdmin, domain.PermissionTargetEvalSet),
+	"DELETE /api/v1/eval/set/detail": permission(domain.RoleAdmin, domain.PermissionTargetEvalSet),
+	"POST /api/v1/eval/run":          permission(domain.RoleAdmin, domain.PermissionTargetEvalSet).withParam("set_id"),
+	"GET /api/v1/eval/report/list":   permission(domain.RoleViewer, domain.PermissionTargetEvalSet).withParam("set_id"),
+	"GET /api/v1/eval/report/detail": permission(domain.RoleViewer, domain.PermissionTargetEvalReport),
+
+	"POST /api/v1/file/upload": permission(domain.RoleEditor, domain.PermissionTargetAny),
+
+	"POST /api/v1/knowledge_base":              permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"GET /api/v1/knowledge_base/list":          permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"GET /api/v1/knowledge_base/detail":        permission(domain.RoleViewer, domain.PermissionTargetKBByID),
+	"PUT /api/v1/knowledge_base/detail":        permission(domain.RoleAdmin, domain.PermissionTargetKBByID),
+	"DELETE /api/v1/knowledge_base/detail":     permission(domain.RoleOwner, domain.PermissionTargetKBByID),
+	"POST /api/v1/knowledge_base/release":      permission(domain.RoleEditor, domain.PermissionTargetKB),
+	"GET /api/v1/knowledge_base/release/list":  permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"GET /api/v1/knowledge_base/member/list":   permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"POST /api/v1/knowledge_base/member":       permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"DELETE /api/v1/knowledge_base/member":     permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"POST /api/v1/knowledge_base/share_link":   permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"DELETE /api/v1/knowledge_base/share_link": permission(domain.RoleAdmin, domain.PermissionTargetKB),
+	"POST /api/v1/kb/retrieval/test":           permission(domain.RoleEditor, domain.PermissionTargetKB),
+
+	"GET /api/v1/knowledge_gap/report": permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"POST /api/v1/knowledge_gap/draft": permission(domain.RoleEditor, domain.PermissionTargetKB),
+
+	"GET /api/v1/model/list":                permission(domain.RoleViewer, domain.PermissionTargetAny),
+	"GET /api/v1/model/detail":              permission(domain.RoleViewer, domain.PermissionTargetAny),
+	"POST /api/v1/model":                    permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"POST /api/v1/model/check":              permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"POST /api/v1/model/provider/supported": permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"POST /api/v1/model/activate":           permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"PUT /api/v1/model":                     permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+
+	"GET /api/v1/node/list":            permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"POST /api/v1/node":                permission(domain.RoleEditor, domain.PermissionTargetKB),
+	"GET /api/v1/node/detail":          permission(domain.RoleViewer, domain.PermissionTargetNode),
+	"PUT /api/v1/node/detail":          permission(domain.RoleEditor, domain.PermissionTargetKB),
+	"POST /api/v1/node/summary":        permission(domain.RoleEditor, domain.PermissionTargetKB),
+	"POST /api/v1/node/action":         permission(domain.RoleEditor, domain.PermissionTargetKB),
+	"POST /api/v1/node/move":           permission(domain.RoleEditor, domain.PermissionTargetNode),
+	"GET /api/v1/node/recommend_nodes": permission(domain.RoleViewer, domain.PermissionTargetKB),
+	"POST /api/v1/node/auto-classify":  permission(domain.RoleEditor, domain.PermissionTargetKB),
+
+	"GET /api/v1/quota/usage": permission(domain.RoleViewer, domain.PermissionTargetKB),
+
+	"POST /api/v1/user/create":          permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"GET /api/v1/user":                  permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"GET /api/v1/user/list":             permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"PUT /api/v1/user/reset_password":   permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"DELETE /api/v1/user/delete":        permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"PUT /api/v1/user/role":             permission(domain.RoleOwner, domain.PermissionTargetGlobal),
+	"GET /api/v1/user/token/list":       permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/token":           permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"DELETE /api/v1/user/token":         permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/service_account": permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+
+	"POST /api/v1/user/2fa/enroll":         permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/2fa/enable":         permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/2fa/disable":        permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/2fa/recovery_codes": permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"DELETE /api/v1/user/2fa":              permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"GET /api/v1/user/security_setting":    permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"PUT /api/v1/user/security_setting":    permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+
+	"GET /api/v1/user/session/list":   permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"DELETE /api/v1/user/session":     permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"DELETE /api/v1/user/session/all": permission(domain.RoleViewer, domain.PermissionTargetSelf),
+	"POST /api/v1/user/logout":        permission(domain.RoleViewer, domain.PermissionTargetSelf),
+
+	"GET /api/v1/audit_log/list":   permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+	"GET /api/v1/audit_log/export": permission(domain.RoleAdmin, domain.PermissionTargetGlobal),
+}
+
+// RBACMiddleware checks the role of the authenticated user required by the route
+type RBACMiddleware struct {
+	logger      *log.Logger
+	rbacUsecase *usecase.RBACUsecase
+}
+
+func NewRBACMiddleware(logger *log.Logger, rbacUsecase *usecase.RBACUsecase) *RBACMiddleware {
+	return &RBACMiddleware{
+		logger:      logger.WithModule("middleware.rbac"),
+		rbacUsecase: rbacUsecase,
+	}
+}
+
+// Authorize must run after the user is authenticated
+func (m *RBACMiddleware) Authorize(userID string, next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		allowed, err := m.allowed(c, userID)
+		if err != nil {
+			m.logger.Error("check role failed", log.String("path", c.Path()), log.Error(err))
+		}
+		if !allowed {
+			return c.JSON(http.StatusForbidden, domain.Response{
+				Success: false,
+				Message: "Forbidden",
+			})
+		}
+		return next(c)
+	}
+}
+
+func (m *RBACMiddleware) allowed(c echo.Context, userID string) (bool, error) {
+	ctx := c.Request().Context()
+	perm, ok := routePermissions[c.Request().Method+" "+c.Path()]
+	if !ok {
+		perm = defaultPermission
+	}
+	switch perm.Target {
+	case domain.PermissionTargetSelf:
+		return true, nil
+	case domain.PermissionTargetGlobal:
+		role, err := m.rbacUsecase.GetGlobalRole(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		return role.Allows(perm.Role), nil
+	case domain.PermissionTargetAny:
+		return m.rbacUsecase.HasAnyRole(ctx, userID, perm.Role)
+	}
+	param := perm.param
+	if param == "" {
+		param = "id"
+		if perm.Target == domain.PermissionTargetKB {
+			param = "kb_id"
+		}
+	}
+	id, err := requestParam(c, param)
+	if err != nil || id == "" {
+		return false, err
+	}
+	kbID := id
+	if perm.Target != domain.PermissionTargetKB && perm.Target != domain.PermissionTargetKBByID {
+		if kbID, err = m.rbacUsecase.ResolveKBID(ctx, perm.Target, id); err != nil {
+			return false, err
+		}
+	}
+	role, err := m.rbacUsecase.GetKBRole(ctx, userID, kbID)
+	if err != nil {
+		return false, err
+	}
+	return role.Allows(perm.Role), nil
+}
+
+// errConflictingParam the binders of the handlers may read another value than the one checked
+var errConflictingParam = errors.New("conflicting request params")
+
+// requestParam the param of the query, the form or the json body, the body is restored for the handler.
+// The binders of echo read both the query and the body and match the keys case-insensitively,
+// so the request is rejected unless all the values agree.
+func requestParam(c echo.Context, name string) (string, error) {
+	values := make(map[string]bool)
+	for key, items := range c.QueryParams() {
+		if strings.EqualFold(key, name) {
+			for _, item := range items {
+				values[item] = true
+			}
+		}
+	}
+	req := c.Request()
+	contentType := req.Header.Get(echo.HeaderContentType)
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMEMultipartForm), strings.HasPrefix(contentType, echo.MIMEApplicationForm):
+		// the form of echo merges the query, only the post form is of the body
+		if _, err := c.FormParams(); err != nil {
+			return "", err
+		}
+		for key, items := range req.PostForm {
+			if strings.EqualFold(key, name) {
+				for _, item := range items {
+					values[item] = true
+				}
+			}
+		}
+	case strings.HasPrefix(contentType, echo.MIMEApplicationJSON):
+		if req.Body == nil {
+			break
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			break
+		}
+		for key, raw := range fields {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return "", errConflictingParam
+			}
+			values[value] = true
+		}
+	}
+	delete(values, "")
+	if len(values) > 1 {
+		return "", errConflictingParam
+	}
+	for value := range values {
+		return value, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestParam(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/node/list?kb_id=kb1", nil)
+	if value, _ := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); value != "kb1" {
+		t.Errorf("expected kb_id of the query, got %q", value)
+	}
+
+	body := `{"id":"n1","kb_id":"kb2","content":"x"}`
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/node/detail", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if value, _ := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); value != "kb2" {
+		t.Errorf("expected kb_id of the json body, got %q", value)
+	}
+	if restored, _ := io.ReadAll(req.Body); string(restored) != body {
+		t.Errorf("the body should be restored for the handler, got %q", restored)
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("kb_id", "kb3")
+	_ = writer.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/file/upload", &form)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	if value, _ := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); value != "kb3" {
+		t.Errorf("expected kb_id of the form, got %q", value)
+	}
+}
+
+func TestRequestParamConflict(t *testing.T) {
+	e := echo.New()
+	jsonRequest := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return req
+	}
+
+	for name, req := range map[string]*http.Request{
+		"query and json body":  jsonRequest("/api/v1/app/api_key?kb_id=mine", `{"kb_id":"victim"}`),
+		"keys of other cases":  jsonRequest("/api/v1/app/api_key", `{"kb_id":"mine","KB_ID":"victim"}`),
+		"non string json":      jsonRequest("/api/v1/app/api_key?kb_id=mine", `{"kb_id":["victim"]}`),
+		"repeated query param": httptest.NewRequest(http.MethodGet, "/api/v1/node/list?kb_id=mine&kb_id=victim", nil),
+	} {
+		if value, err := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); err == nil {
+			t.Errorf("%s: expected conflict, got %q", name, value)
+		}
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("kb_id", "victim")
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/file/upload?kb_id=mine", &form)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	if value, err := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); err == nil {
+		t.Errorf("query and form: expected conflict, got %q", value)
+	}
+
+	// the same value in both is fine
+	req = jsonRequest("/api/v1/app/api_key?kb_id=mine", `{"kb_id":"mine"}`)
+	if value, err := requestParam(e.NewContext(req, httptest.NewRecorder()), "kb_id"); err != nil || value != "mine" {
+		t.Errorf("expected kb_id mine, got %q, %v", value, err)
+	}
+}
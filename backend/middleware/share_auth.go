@@ -10,15 +10,18 @@ import (
 	"github.com/chaitin/panda-wiki/usecase"
 )
 
+// ShareAuthMiddleware authenticates the readers of the published wiki by the reader auth of the kb
 type ShareAuthMiddleware struct {
-	logger    *log.Logger
-	kbUsecase *usecase.KnowledgeBaseUsecase
+	logger            *log.Logger
+	kbUsecase         *usecase.KnowledgeBaseUsecase
+	readerAuthUsecase *usecase.ReaderAuthUsecase
 }
 
-func NewShareAuthMiddleware(logger *log.Logger, kbUsecase *usecase.KnowledgeBaseUsecase) *ShareAuthMiddleware {
+func NewShareAuthMiddleware(logger *log.Logger, kbUsecase *usecase.KnowledgeBaseUsecase, readerAuthUsecase *usecase.ReaderAuthUsecase) *ShareAuthMiddleware {
 	return &ShareAuthMiddleware{
-		logger:    logger.WithModule("middleware.share_auth"),
-		kbUsecase: kbUsecase,
+		logger:            logger.WithModule("middleware.share_auth"),
+		kbUsecase:         kbUsecase,
+		readerAuthUsecase: readerAuthUsecase,
 	}
 }
 
@@ -40,15 +43,20 @@ func (h *ShareAuthMiddleware) Authorize(next echo.HandlerFunc) echo.HandlerFunc
 				Message: "Unauthorized",
 			})
 		}
-		if kb.AccessSettings.SimpleAuth.Enabled && kb.AccessSettings.SimpleAuth.Password != "" {
-			password := c.Request().Header.Get("X-Simple-Auth-Password")
-			if password != kb.AccessSettings.SimpleAuth.Password {
-				h.logger.Error("simple auth failed", log.String("kb_id", kbID), log.String("password", password))
-				return c.JSON(http.StatusUnauthorized, domain.Response{
-					Success: false,
-					Message: "Unauthorized",
-				})
-			}
+		// the session cookie of the reader login, or the password header of the api clients
+		sessionID := ""
+		if cookie, err := c.Cookie(domain.ReaderSessionCookie); err == nil {
+			sessionID = cookie.Value
+		}
+		ok, err := h.readerAuthUsecase.Authorize(c.Request().Context(), kb, sessionID, c.Request().Header.Get("X-Simple-Auth-Password"), c.RealIP())
+		if err != nil {
+			h.logger.Error("reader auth failed", log.String("kb_id", kbID), log.Error(err))
+		}
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, domain.Response{
+				Success: false,
+				Message: "Unauthorized",
+			})
 		}
 		return next(c)
 	}
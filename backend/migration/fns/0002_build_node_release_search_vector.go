@@ -0,0 +1,35 @@
+package fns
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type MigrationNodeReleaseSearchVector struct {
+	Name     string
+	logger   *log.Logger
+	nodeRepo *pg.NodeRepository
+}
+
+func NewMigrationNodeReleaseSearchVector(logger *log.Logger, nodeRepo *pg.NodeRepository) *MigrationNodeReleaseSearchVector {
+	return &MigrationNodeReleaseSearchVector{
+		Name:     "0002_build_node_release_search_vector",
+		logger:   logger,
+		nodeRepo: nodeRepo,
+	}
+}
+
+func (m *MigrationNodeReleaseSearchVector) Execute(tx *gorm.DB) error {
+	// build full text search vector for existing node releases
+	count, err := m.nodeRepo.BuildMissingNodeReleaseSearchVectors(context.Background(), tx)
+	if err != nil {
+		return fmt.Errorf("build node release search vector failed: %w", err)
+	}
+	m.logger.Info("build node release search vector success", log.Int("count", count))
+	return nil
+}
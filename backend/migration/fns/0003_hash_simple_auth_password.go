@@ -0,0 +1,56 @@
+package fns
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
+)
+
+type MigrationHashSimpleAuthPassword struct {
+	Name    string
+	logger  *log.Logger
+	kbCache *cache.KBRepo
+}
+
+func NewMigrationHashSimpleAuthPassword(logger *log.Logger, kbCache *cache.KBRepo) *MigrationHashSimpleAuthPassword {
+	return &MigrationHashSimpleAuthPassword{
+		Name:    "0003_hash_simple_auth_password",
+		logger:  logger,
+		kbCache: kbCache,
+	}
+}
+
+func (m *MigrationHashSimpleAuthPassword) Execute(tx *gorm.DB) error {
+	// replace the plaintext access passwords of the kbs by the bcrypt hashes
+	var kbs []domain.KnowledgeBase
+	if err := tx.Model(&domain.KnowledgeBase{}).
+		Select("id", "access_settings").
+		Find(&kbs).Error; err != nil {
+		return fmt.Errorf("get kb access settings failed: %w", err)
+	}
+	for _, kb := range kbs {
+		settings := kb.AccessSettings
+		if settings.SimpleAuth.Password == "" {
+			continue
+		}
+		if err := settings.SimpleAuth.SetPassword(settings.SimpleAuth.Password); err != nil {
+			return fmt.Errorf("hash simple auth password failed: %w", err)
+		}
+		if err := tx.Model(&domain.KnowledgeBase{}).
+			Where("id = ?", kb.ID).
+			Update("access_settings", settings).Error; err != nil {
+			return fmt.Errorf("update kb access settings failed: %w", err)
+		}
+		// the cached kb still has the plaintext password
+		if err := m.kbCache.DeleteKB(context.Background(), kb.ID); err != nil {
+			return fmt.Errorf("delete kb cache failed: %w", err)
+		}
+		m.logger.Info("hash simple auth password success", log.String("kb_id", kb.ID))
+	}
+	return nil
+}
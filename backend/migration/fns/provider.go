@@ -6,4 +6,6 @@ import (
 
 var ProviderSet = wire.NewSet(
 	NewMigrationNodeVersion,
+	NewMigrationNodeReleaseSearchVector,
+	NewMigrationHashSimpleAuthPassword,
 )
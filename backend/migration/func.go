@@ -5,7 +5,9 @@ import (
 )
 
 type MigrationFuncs struct {
-	NodeMigration *fns.MigrationNodeVersion
+	NodeMigration         *fns.MigrationNodeVersion
+	SearchVectorMigration *fns.MigrationNodeReleaseSearchVector
+	SimpleAuthMigration   *fns.MigrationHashSimpleAuthPassword
 }
 
 func (mf *MigrationFuncs) GetMigrationFuncs() []MigrationFunc {
@@ -14,5 +16,13 @@ func (mf *MigrationFuncs) GetMigrationFuncs() []MigrationFunc {
 		Name: mf.NodeMigration.Name,
 		Fn:   mf.NodeMigration.Execute,
 	})
+	funcs = append(funcs, MigrationFunc{
+		Name: mf.SearchVectorMigration.Name,
+		Fn:   mf.SearchVectorMigration.Execute,
+	})
+	funcs = append(funcs, MigrationFunc{
+		Name: mf.SimpleAuthMigration.Name,
+		Fn:   mf.SimpleAuthMigration.Execute,
+	})
 	return funcs
 }
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+const chatStopChannel = "chat:stop"
+
+// ChatStreamRepo the running answer streams of all the instances,
+// a stop is published to all the instances and the instance running the stream cancels it
+type ChatStreamRepo struct {
+	cache *cache.Cache
+}
+
+func NewChatStreamRepo(cache *cache.Cache) *ChatStreamRepo {
+	return &ChatStreamRepo{cache: cache}
+}
+
+func chatStreamKey(conversationID string) string {
+	return fmt.Sprintf("chat:stream:%s", conversationID)
+}
+
+// AddStream the stream expires after ttl in case the instance is gone without removing it
+func (r *ChatStreamRepo) AddStream(ctx context.Context, conversationID string, ttl time.Duration) error {
+	return r.cache.Set(ctx, chatStreamKey(conversationID), 1, ttl).Err()
+}
+
+func (r *ChatStreamRepo) RemoveStream(ctx context.Context, conversationID string) error {
+	return r.cache.Del(ctx, chatStreamKey(conversationID)).Err()
+}
+
+func (r *ChatStreamRepo) StreamExists(ctx context.Context, conversationID string) (bool, error) {
+	count, err := r.cache.Exists(ctx, chatStreamKey(conversationID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *ChatStreamRepo) PublishStop(ctx context.Context, conversationID string) error {
+	return r.cache.Publish(ctx, chatStopChannel, conversationID).Err()
+}
+
+// SubscribeStop the conversation ids of the published stops until ctx is done,
+// the subscription is confirmed before returning and resubscribed by redis client on reconnect
+func (r *ChatStreamRepo) SubscribeStop(ctx context.Context) (<-chan string, error) {
+	pubsub := r.cache.Subscribe(ctx, chatStopChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	stops := make(chan string)
+	go func() {
+		defer close(stops)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case stops <- message.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return stops, nil
+}
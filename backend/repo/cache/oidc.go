@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+// OIDCStateRepo login states of the redirects to the identity provider, each state is used once
+type OIDCStateRepo struct {
+	cache *cache.Cache
+}
+
+func NewOIDCStateRepo(cache *cache.Cache) *OIDCStateRepo {
+	return &OIDCStateRepo{cache: cache}
+}
+
+func oidcStateKey(state string) string {
+	return fmt.Sprintf("oidc:state:%s", state)
+}
+
+func (r *OIDCStateRepo) SetState(ctx context.Context, state string, loginState *domain.OIDCLoginState, ttl time.Duration) error {
+	value, err := json.Marshal(loginState)
+	if err != nil {
+		return err
+	}
+	return r.cache.Set(ctx, oidcStateKey(state), value, ttl).Err()
+}
+
+// PopState gets and deletes the state, nil if expired or used
+func (r *OIDCStateRepo) PopState(ctx context.Context, state string) (*domain.OIDCLoginState, error) {
+	value, err := r.cache.GetDel(ctx, oidcStateKey(state)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var loginState domain.OIDCLoginState
+	if err := json.Unmarshal([]byte(value), &loginState); err != nil {
+		return nil, err
+	}
+	return &loginState, nil
+}
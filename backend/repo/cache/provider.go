@@ -9,4 +9,10 @@ import (
 var ProviderSet = wire.NewSet(
 	cache.NewCache,
 	NewKBRepo,
+	NewQuotaRepo,
+	NewOIDCStateRepo,
+	NewTwoFactorRepo,
+	NewSessionRepo,
+	NewReaderSessionRepo,
+	NewChatStreamRepo,
 )
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+const (
+	quotaMinuteTTL = 2 * time.Minute
+	quotaDailyTTL  = 48 * time.Hour
+)
+
+// QuotaRepo fixed window counters of chat quotas
+type QuotaRepo struct {
+	cache *cache.Cache
+}
+
+func NewQuotaRepo(cache *cache.Cache) *QuotaRepo {
+	return &QuotaRepo{cache: cache}
+}
+
+func ipRequestsKey(appID, ip string, now time.Time) string {
+	return fmt.Sprintf("quota:requests:ip:%s:%s:%s", appID, ip, now.Format("200601021504"))
+}
+
+func appRequestsKey(appID string, now time.Time) string {
+	return fmt.Sprintf("quota:requests:app:%s:%s", appID, now.Format("200601021504"))
+}
+
+func dailyTokensKey(scope domain.QuotaScope, id string, now time.Time) string {
+	return fmt.Sprintf("quota:tokens:%s:%s:%s", scope, id, now.Format("20060102"))
+}
+
+// IncrRequests counts a request of the ip to the app in the current minute, returns the count of the ip
+func (r *QuotaRepo) IncrRequests(ctx context.Context, appID, ip string, now time.Time) (int64, error) {
+	pipe := r.cache.TxPipeline()
+	ipCount := pipe.Incr(ctx, ipRequestsKey(appID, ip, now))
+	pipe.Expire(ctx, ipRequestsKey(appID, ip, now), quotaMinuteTTL)
+	pipe.Incr(ctx, appRequestsKey(appID, now))
+	pipe.Expire(ctx, appRequestsKey(appID, now), quotaMinuteTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return ipCount.Val(), nil
+}
+
+// GetAppRequests gets requests to the app in the current minute
+func (r *QuotaRepo) GetAppRequests(ctx context.Context, appID string, now time.Time) (int64, error) {
+	return r.getInt(ctx, appRequestsKey(appID, now))
+}
+
+// GetDailyTokens gets tokens consumed by the kb or the app today
+func (r *QuotaRepo) GetDailyTokens(ctx context.Context, scope domain.QuotaScope, id string, now time.Time) (int64, error) {
+	return r.getInt(ctx, dailyTokensKey(scope, id, now))
+}
+
+// IncrDailyTokens adds consumed tokens to the kb and the app, negative tokens are given back
+func (r *QuotaRepo) IncrDailyTokens(ctx context.Context, kbID, appID string, tokens int64, now time.Time) error {
+	pipe := r.cache.TxPipeline()
+	for scope, id := range map[domain.QuotaScope]string{domain.QuotaScopeKB: kbID, domain.QuotaScopeApp: appID} {
+		key := dailyTokensKey(scope, id, now)
+		pipe.IncrBy(ctx, key, tokens)
+		pipe.Expire(ctx, key, quotaDailyTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ReserveDailyTokens adds reserved tokens to the kb and the app, returns the tokens of the kb and the app including the reserved ones
+func (r *QuotaRepo) ReserveDailyTokens(ctx context.Context, kbID, appID string, tokens int64, now time.Time) (int64, int64, error) {
+	pipe := r.cache.TxPipeline()
+	kbTokens := pipe.IncrBy(ctx, dailyTokensKey(domain.QuotaScopeKB, kbID, now), tokens)
+	pipe.Expire(ctx, dailyTokensKey(domain.QuotaScopeKB, kbID, now), quotaDailyTTL)
+	appTokens := pipe.IncrBy(ctx, dailyTokensKey(domain.QuotaScopeApp, appID, now), tokens)
+	pipe.Expire(ctx, dailyTokensKey(domain.QuotaScopeApp, appID, now), quotaDailyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return kbTokens.Val(), appTokens.Val(), nil
+}
+
+func (r *QuotaRepo) getInt(ctx context.Context, key string) (int64, error) {
+	count, err := r.cache.Get(ctx, key).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+// ReaderSessionRepo sessions of the readers of the published wikis,
+// the sessions of a kb are indexed by a sorted set scored by the expiry
+type ReaderSessionRepo struct {
+	cache *cache.Cache
+}
+
+func NewReaderSessionRepo(cache *cache.Cache) *ReaderSessionRepo {
+	return &ReaderSessionRepo{cache: cache}
+}
+
+func readerSessionKey(id string) string {
+	return fmt.Sprintf("reader_session:%s", id)
+}
+
+func kbReaderSessionsKey(kbID string) string {
+	return fmt.Sprintf("reader_session:kb:%s", kbID)
+}
+
+func readerLoginFailuresKey(kbID, ip string) string {
+	return fmt.Sprintf("reader_login_failures:%s:%s", kbID, ip)
+}
+
+func (r *ReaderSessionRepo) CreateSession(ctx context.Context, session *domain.ReaderSession) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(session.ExpiresAt)
+	key := kbReaderSessionsKey(session.KBID)
+	pipe := r.cache.TxPipeline()
+	pipe.Set(ctx, readerSessionKey(session.ID), value, ttl)
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+	// the index lives as long as the last session, share link sessions may expire earlier
+	pipe.ExpireNX(ctx, key, ttl)
+	pipe.ExpireGT(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetSession nil if expired or revoked
+func (r *ReaderSessionRepo) GetSession(ctx context.Context, id string) (*domain.ReaderSession, error) {
+	value, err := r.cache.Get(ctx, readerSessionKey(id)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var session domain.ReaderSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *ReaderSessionRepo) DeleteSession(ctx context.Context, session *domain.ReaderSession) error {
+	pipe := r.cache.TxPipeline()
+	pipe.Del(ctx, readerSessionKey(session.ID))
+	pipe.ZRem(ctx, kbReaderSessionsKey(session.KBID), session.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteSessionsByKBID revokes all reader sessions of the kb
+func (r *ReaderSessionRepo) DeleteSessionsByKBID(ctx context.Context, kbID string) error {
+	key := kbReaderSessionsKey(kbID)
+	ids, err := r.cache.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	pipe := r.cache.TxPipeline()
+	for _, id := range ids {
+		pipe.Del(ctx, readerSessionKey(id))
+	}
+	pipe.Del(ctx, key)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// IncrLoginFailures counts the failed password logins of the ip in the window of the first failure
+func (r *ReaderSessionRepo) IncrLoginFailures(ctx context.Context, kbID, ip string, window time.Duration) (int64, error) {
+	key := readerLoginFailuresKey(kbID, ip)
+	pipe := r.cache.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *ReaderSessionRepo) GetLoginFailures(ctx context.Context, kbID, ip string) (int64, error) {
+	count, err := r.cache.Get(ctx, readerLoginFailuresKey(kbID, ip)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	return count, nil
+}
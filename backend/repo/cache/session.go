@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+// SessionRepo sessions of the jwts, each session expires with its jwt,
+// the sessions of a user are indexed by a sorted set scored by the expiry
+type SessionRepo struct {
+	cache *cache.Cache
+}
+
+func NewSessionRepo(cache *cache.Cache) *SessionRepo {
+	return &SessionRepo{cache: cache}
+}
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("session:user:%s", userID)
+}
+
+func (r *SessionRepo) CreateSession(ctx context.Context, session *domain.Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	pipe := r.cache.TxPipeline()
+	pipe.Set(ctx, sessionKey(session.ID), value, time.Until(session.ExpiresAt))
+	pipe.ZAdd(ctx, userSessionsKey(session.UserID), redis.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+	pipe.ExpireAt(ctx, userSessionsKey(session.UserID), session.ExpiresAt)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetSession nil if expired or revoked
+func (r *SessionRepo) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	value, err := r.cache.Get(ctx, sessionKey(id)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var session domain.Session
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateSession saves the last seen of the session without extending its ttl, a revoked session is not recreated
+func (r *SessionRepo) UpdateSession(ctx context.Context, session *domain.Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	err = r.cache.SetArgs(ctx, sessionKey(session.ID), value, redis.SetArgs{KeepTTL: true, Mode: "XX"}).Err()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+// GetSessionsByUserID the unexpired sessions of the user, the newest first
+func (r *SessionRepo) GetSessionsByUserID(ctx context.Context, userID string) ([]*domain.Session, error) {
+	key := userSessionsKey(userID)
+	if err := r.cache.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10)).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := r.cache.ZRevRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*domain.Session, 0, len(ids))
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+	values, err := r.cache.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var session domain.Session
+		if err := json.Unmarshal([]byte(s), &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepo) DeleteSession(ctx context.Context, userID, id string) error {
+	pipe := r.cache.TxPipeline()
+	pipe.Del(ctx, sessionKey(id))
+	pipe.ZRem(ctx, userSessionsKey(userID), id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteSessionsByUserID revokes all sessions of the user except the ids to keep
+func (r *SessionRepo) DeleteSessionsByUserID(ctx context.Context, userID string, keepIDs ...string) error {
+	key := userSessionsKey(userID)
+	ids, err := r.cache.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(keepIDs))
+	for _, id := range keepIDs {
+		keep[id] = true
+	}
+	pipe := r.cache.TxPipeline()
+	for _, id := range ids {
+		if keep[id] {
+			continue
+		}
+		pipe.Del(ctx, sessionKey(id))
+		pipe.ZRem(ctx, key, id)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+// TwoFactorRepo pending second steps of logins and the totp codes already used
+type TwoFactorRepo struct {
+	cache *cache.Cache
+}
+
+func NewTwoFactorRepo(cache *cache.Cache) *TwoFactorRepo {
+	return &TwoFactorRepo{cache: cache}
+}
+
+func twoFactorChallengeKey(token string) string {
+	return fmt.Sprintf("2fa:challenge:%s", token)
+}
+
+func twoFactorAttemptsKey(token string) string {
+	return fmt.Sprintf("2fa:attempts:%s", token)
+}
+
+func twoFactorUsedCodeKey(userID, code string) string {
+	return fmt.Sprintf("2fa:used:%s:%s", userID, code)
+}
+
+func (r *TwoFactorRepo) SetChallenge(ctx context.Context, token string, challenge *domain.TwoFactorChallenge, ttl time.Duration) error {
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	return r.cache.Set(ctx, twoFactorChallengeKey(token), value, ttl).Err()
+}
+
+// GetChallenge nil if expired or used
+func (r *TwoFactorRepo) GetChallenge(ctx context.Context, token string) (*domain.TwoFactorChallenge, error) {
+	value, err := r.cache.Get(ctx, twoFactorChallengeKey(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var challenge domain.TwoFactorChallenge
+	if err := json.Unmarshal([]byte(value), &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// IncrAttempts counts the codes tried for the challenge atomically, the counter expires with the challenge
+func (r *TwoFactorRepo) IncrAttempts(ctx context.Context, token string, ttl time.Duration) (int64, error) {
+	key := twoFactorAttemptsKey(token)
+	pipe := r.cache.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *TwoFactorRepo) DeleteChallenge(ctx context.Context, token string) error {
+	return r.cache.Del(ctx, twoFactorChallengeKey(token), twoFactorAttemptsKey(token)).Err()
+}
+
+// MarkCodeUsed records the totp code of the user, false if it was used in the ttl to prevent replays
+func (r *TwoFactorRepo) MarkCodeUsed(ctx context.Context, userID, code string, ttl time.Duration) (bool, error) {
+	return r.cache.SetNX(ctx, twoFactorUsedCodeKey(userID, code), 1, ttl).Result()
+}
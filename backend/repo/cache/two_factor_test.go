@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/store/cache"
+)
+
+func newTestCache(t *testing.T) (*cache.Cache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &cache.Cache{Client: client}, server
+}
+
+func TestTwoFactorAttempts(t *testing.T) {
+	c, server := newTestCache(t)
+	repo := NewTwoFactorRepo(c)
+	ctx := context.Background()
+
+	// each parallel guess gets its own count
+	counts := make(chan int64, 10)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := repo.IncrAttempts(ctx, "token", time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			counts <- count
+		}()
+	}
+	wg.Wait()
+	close(counts)
+	seen := make(map[int64]bool)
+	for count := range counts {
+		seen[count] = true
+	}
+	if len(seen) != 10 || !seen[1] || !seen[10] {
+		t.Errorf("IncrAttempts() counts = %v, expected 1 to 10", seen)
+	}
+
+	// the counter expires with the challenge and is deleted with it
+	if ttl := server.TTL(twoFactorAttemptsKey("token")); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl of the attempts = %v, expected at most a minute", ttl)
+	}
+	if err := repo.DeleteChallenge(ctx, "token"); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := repo.IncrAttempts(ctx, "token", time.Minute); err != nil || count != 1 {
+		t.Errorf("IncrAttempts() after delete = %d, %v, expected 1", count, err)
+	}
+}
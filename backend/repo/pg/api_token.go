@@ -0,0 +1,92 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+// apiTokenLastUsedInterval throttles the last used updates of busy tokens
+const apiTokenLastUsedInterval = time.Minute
+
+type APITokenRepository struct {
+	db *pg.DB
+}
+
+func NewAPITokenRepository(db *pg.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+func (r *APITokenRepository) CreateAPIToken(ctx context.Context, token *domain.APIToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *APITokenRepository) GetAPITokensByUserID(ctx context.Context, userID string) ([]*domain.APIToken, error) {
+	tokens := make([]*domain.APIToken, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.APIToken{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *APITokenRepository) GetAPIToken(ctx context.Context, id string) (*domain.APIToken, error) {
+	token := &domain.APIToken{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.APIToken{}).
+		Where("id = ?", id).
+		First(token).Error; err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *APITokenRepository) GetAPITokenByHash(ctx context.Context, tokenHash string) (*domain.APIToken, error) {
+	token := &domain.APIToken{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.APIToken{}).
+		Where("token_hash = ?", tokenHash).
+		First(token).Error; err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *APITokenRepository) DeleteAPIToken(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&domain.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *APITokenRepository) DeleteAPITokensByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&domain.APIToken{}).Error
+}
+
+// UpdateLastUsed records the last use of the token at most once per interval
+func (r *APITokenRepository) UpdateLastUsed(ctx context.Context, id, remoteIP string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&domain.APIToken{}).
+		Where("id = ?", id).
+		Where("last_used_at IS NULL OR last_used_at < ? OR last_used_ip != ?", now.Add(-apiTokenLastUsedInterval), remoteIP).
+		Updates(map[string]any{
+			"last_used_at": now,
+			"last_used_ip": remoteIP,
+		}).Error
+}
@@ -74,6 +74,19 @@ func (r *AppRepository) GetOrCreateApplByKBIDAndType(ctx context.Context, kbID s
 	return app, nil
 }
 
+// GetAppsByKBID returns all apps of a knowledge base
+func (r *AppRepository) GetAppsByKBID(ctx context.Context, kbID string) ([]*domain.App, error) {
+	var apps []*domain.App
+	if err := r.db.WithContext(ctx).
+		Model(&domain.App{}).
+		Where("kb_id = ?", kbID).
+		Order("type ASC").
+		Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
 // GetAppsByTypes returns all apps of a specific type
 func (r *AppRepository) GetAppsByTypes(ctx context.Context, appTypes []domain.AppType) ([]*domain.App, error) {
 	var apps []*domain.App
@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type AppAPIKeyRepository struct {
+	db *pg.DB
+}
+
+func NewAppAPIKeyRepository(db *pg.DB) *AppAPIKeyRepository {
+	return &AppAPIKeyRepository{db: db}
+}
+
+func (r *AppAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.AppAPIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *AppAPIKeyRepository) GetAPIKeysByKBID(ctx context.Context, kbID string) ([]*domain.AppAPIKey, error) {
+	keys := make([]*domain.AppAPIKey, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.AppAPIKey{}).
+		Where("kb_id = ?", kbID).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *AppAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.AppAPIKey, error) {
+	key := &domain.AppAPIKey{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.AppAPIKey{}).
+		Where("key_hash = ?", keyHash).
+		First(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *AppAPIKeyRepository) DeleteAPIKey(ctx context.Context, kbID, id string) error {
+	result := r.db.WithContext(ctx).
+		Where("kb_id = ?", kbID).
+		Where("id = ?", id).
+		Delete(&domain.AppAPIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *AppAPIKeyRepository) UpdateLastUsedAt(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.AppAPIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", time.Now()).Error
+}
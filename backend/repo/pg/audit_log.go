@@ -0,0 +1,90 @@
+package pg
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+// AuditLogRepository the audit log is only created and queried
+type AuditLogRepository struct {
+	db *pg.DB
+}
+
+func NewAuditLogRepository(db *pg.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) CreateAuditLog(ctx context.Context, auditLog *domain.AuditLog) error {
+	return r.db.WithContext(ctx).Create(auditLog).Error
+}
+
+func (r *AuditLogRepository) filter(ctx context.Context, filter *domain.AuditLogFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&domain.AuditLog{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.KBID != "" {
+		query = query.Where("kb_id = ?", filter.KBID)
+	}
+	if filter.Start != nil {
+		query = query.Where("created_at >= ?", *filter.Start)
+	}
+	if filter.End != nil {
+		query = query.Where("created_at < ?", *filter.End)
+	}
+	return query
+}
+
+func (r *AuditLogRepository) GetAuditLogList(ctx context.Context, req *domain.GetAuditLogListReq) ([]*domain.AuditLog, uint64, error) {
+	var count int64
+	if err := r.filter(ctx, &req.AuditLogFilter).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	auditLogs := make([]*domain.AuditLog, 0)
+	if err := r.filter(ctx, &req.AuditLogFilter).
+		Order("id DESC").
+		Offset(req.Offset()).
+		Limit(req.Limit()).
+		Find(&auditLogs).Error; err != nil {
+		return nil, 0, err
+	}
+	return auditLogs, uint64(count), nil
+}
+
+// TraverseAuditLogs the matched audit logs from the newest in batches, for exports
+func (r *AuditLogRepository) TraverseAuditLogs(ctx context.Context, filter *domain.AuditLogFilter, batchSize int, callback func([]*domain.AuditLog) error) error {
+	var beforeID int64
+	for {
+		query := r.filter(ctx, filter)
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		auditLogs := make([]*domain.AuditLog, 0, batchSize)
+		if err := query.Order("id DESC").Limit(batchSize).Find(&auditLogs).Error; err != nil {
+			return err
+		}
+		if len(auditLogs) == 0 {
+			return nil
+		}
+		if err := callback(auditLogs); err != nil {
+			return err
+		}
+		if len(auditLogs) < batchSize {
+			return nil
+		}
+		beforeID = auditLogs[len(auditLogs)-1].ID
+	}
+}
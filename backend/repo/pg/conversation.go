@@ -2,7 +2,9 @@ package pg
 
 import (
 	"context"
+	"time"
 
+	"github.com/cloudwego/eino/schema"
 	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/domain"
@@ -48,13 +50,19 @@ func (r *ConversationRepository) GetConversationList(ctx context.Context, reques
 	if request.RemoteIP != nil && *request.RemoteIP != "" {
 		query = query.Where("conversations.remote_ip like ?", "%"+*request.RemoteIP+"%")
 	}
+	if request.FeedbackScore != nil && *request.FeedbackScore != domain.FeedbackScoreNone {
+		query = query.Where("EXISTS (SELECT 1 FROM conversation_messages WHERE conversation_messages.conversation_id = conversations.id AND conversation_messages.feedback_score = ?)", *request.FeedbackScore)
+	}
 	var count int64
 	if err := query.Count(&count).Error; err != nil {
 		return nil, 0, err
 	}
 	if err := query.
 		Joins("left join apps on conversations.app_id = apps.id").
-		Select("conversations.*, apps.name as app_name").
+		Select("conversations.*, apps.name as app_name, "+
+			"(SELECT COUNT(*) FROM conversation_messages WHERE conversation_messages.conversation_id = conversations.id AND conversation_messages.feedback_score = ?) as like_count, "+
+			"(SELECT COUNT(*) FROM conversation_messages WHERE conversation_messages.conversation_id = conversations.id AND conversation_messages.feedback_score = ?) as dislike_count",
+			domain.FeedbackScoreLike, domain.FeedbackScoreDislike).
 		Offset(request.Offset()).
 		Limit(request.Limit()).
 		Order("conversations.created_at DESC").
@@ -98,6 +106,78 @@ func (r *ConversationRepository) GetConversationMessagesByID(ctx context.Context
 	return messages, nil
 }
 
+func (r *ConversationRepository) GetConversationMessage(ctx context.Context, conversationID, messageID string) (*domain.ConversationMessage, error) {
+	message := &domain.ConversationMessage{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.ConversationMessage{}).
+		Where("id = ?", messageID).
+		Where("conversation_id = ?", conversationID).
+		First(message).Error; err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (r *ConversationRepository) GetLatestConversationMessage(ctx context.Context, conversationID string) (*domain.ConversationMessage, error) {
+	message := &domain.ConversationMessage{}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.ConversationMessage{}).
+		Where("conversation_id = ?", conversationID).
+		Order("created_at desc").
+		First(message).Error; err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// HasConversationBranches reports whether any message of the conversation has more than one reply
+func (r *ConversationRepository) HasConversationBranches(ctx context.Context, conversationID string) (bool, error) {
+	var exists bool
+	if err := r.db.WithContext(ctx).
+		Raw(`SELECT EXISTS (
+			SELECT 1 FROM conversation_messages
+			WHERE conversation_id = ?
+			GROUP BY parent_id
+			HAVING COUNT(*) > 1
+		)`, conversationID).
+		Scan(&exists).Error; err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *ConversationRepository) UpdateMessageRewrittenQuery(ctx context.Context, messageID string, rewrite *domain.QueryRewrite) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.ConversationMessage{}).
+		Where("id = ?", messageID).
+		Updates(&domain.ConversationMessage{
+			RewrittenQuery: rewrite.Query,
+			SubQueries:     rewrite.SubQueries,
+		}).Error
+}
+
+// UpdateMessageFeedback rates an assistant message of the conversation
+func (r *ConversationRepository) UpdateMessageFeedback(ctx context.Context, req *domain.FeedbackReq) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.ConversationMessage{}).
+		Where("id = ?", req.MessageID).
+		Where("conversation_id = ?", req.ConversationID).
+		Where("role = ?", schema.Assistant).
+		Updates(map[string]any{
+			"feedback_score":   req.Score,
+			"feedback_reason":  req.Reason,
+			"feedback_comment": req.Comment,
+			"feedback_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (r *ConversationRepository) ValidateConversationNonce(ctx context.Context, conversationID, nonce string) error {
 	conversation := &domain.Conversation{}
 	if err := r.db.WithContext(ctx).
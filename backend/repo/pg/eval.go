@@ -0,0 +1,96 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type EvalRepository struct {
+	db *pg.DB
+}
+
+func NewEvalRepository(db *pg.DB) *EvalRepository {
+	return &EvalRepository{db: db}
+}
+
+func (r *EvalRepository) CreateEvalSet(ctx context.Context, set *domain.EvalSet) error {
+	return r.db.WithContext(ctx).Create(set).Error
+}
+
+func (r *EvalRepository) GetEvalSetList(ctx context.Context, kbID string) ([]*domain.EvalSetListItem, error) {
+	var sets []*domain.EvalSetListItem
+	if err := r.db.WithContext(ctx).
+		Model(&domain.EvalSet{}).
+		Where("kb_id = ?", kbID).
+		Select("id, kb_id, name, description, jsonb_array_length(cases) as case_count, created_at, updated_at").
+		Order("created_at DESC").
+		Find(&sets).Error; err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+func (r *EvalRepository) GetEvalSet(ctx context.Context, id string) (*domain.EvalSet, error) {
+	var set domain.EvalSet
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&set).Error; err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (r *EvalRepository) UpdateEvalSet(ctx context.Context, req *domain.UpdateEvalSetReq) error {
+	updateMap := map[string]any{}
+	if req.Name != nil {
+		updateMap["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updateMap["description"] = *req.Description
+	}
+	if req.Cases != nil {
+		updateMap["cases"] = domain.EvalCases(req.Cases)
+	}
+	if len(updateMap) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&domain.EvalSet{}).
+		Where("id = ?", req.ID).
+		Updates(updateMap).Error
+}
+
+func (r *EvalRepository) DeleteEvalSet(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&domain.EvalSet{}).Error
+}
+
+func (r *EvalRepository) CreateEvalReport(ctx context.Context, report *domain.EvalReport) error {
+	return r.db.WithContext(ctx).Create(report).Error
+}
+
+func (r *EvalRepository) GetEvalReportList(ctx context.Context, req *domain.GetEvalReportListReq) ([]*domain.EvalReportListItem, uint64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&domain.EvalReport{}).
+		Where("set_id = ?", req.SetID)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var reports []*domain.EvalReportListItem
+	if err := query.
+		Offset(req.Offset()).
+		Limit(req.Limit()).
+		Order("created_at DESC").
+		Find(&reports).Error; err != nil {
+		return nil, 0, err
+	}
+	return reports, uint64(count), nil
+}
+
+func (r *EvalRepository) GetEvalReport(ctx context.Context, id string) (*domain.EvalReport, error) {
+	var report domain.EvalReport
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,110 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+// resourceTables tables of the resources owned by a kb, looked up to authorize requests by resource id
+var resourceTables = map[domain.PermissionTarget]string{
+	domain.PermissionTargetNode:         "nodes",
+	domain.PermissionTargetApp:          "apps",
+	domain.PermissionTargetConversation: "conversations",
+	domain.PermissionTargetEvalSet:      "eval_sets",
+	domain.PermissionTargetEvalReport:   "eval_reports",
+}
+
+type KBMemberRepository struct {
+	db *pg.DB
+}
+
+func NewKBMemberRepository(db *pg.DB) *KBMemberRepository {
+	return &KBMemberRepository{db: db}
+}
+
+// GetMemberRole the role of the user in the kb, empty if not a member
+func (r *KBMemberRepository) GetMemberRole(ctx context.Context, kbID, userID string) (domain.Role, error) {
+	var member domain.KBMember
+	err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND user_id = ?", kbID, userID).
+		First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return member.Role, nil
+}
+
+func (r *KBMemberRepository) GetMembersByUserID(ctx context.Context, userID string) ([]*domain.KBMember, error) {
+	members := make([]*domain.KBMember, 0)
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *KBMemberRepository) GetMemberList(ctx context.Context, kbID string) ([]*domain.KBMemberListItem, error) {
+	members := make([]*domain.KBMemberListItem, 0)
+	if err := r.db.WithContext(ctx).
+		Model(&domain.KBMember{}).
+		Select("kb_members.user_id, users.account, kb_members.role, kb_members.created_at").
+		Joins("JOIN users ON users.id = kb_members.user_id").
+		Where("kb_members.kb_id = ?", kbID).
+		Order("kb_members.created_at ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *KBMemberRepository) UpsertMember(ctx context.Context, member *domain.KBMember) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "kb_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).
+		Create(member).Error
+}
+
+func (r *KBMemberRepository) DeleteMember(ctx context.Context, kbID, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("kb_id = ? AND user_id = ?", kbID, userID).
+		Delete(&domain.KBMember{}).Error
+}
+
+func (r *KBMemberRepository) DeleteMembersByUserID(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&domain.KBMember{}).Error
+}
+
+// GetResourceKBID the kb id of the node, app, conversation, eval set or eval report
+func (r *KBMemberRepository) GetResourceKBID(ctx context.Context, target domain.PermissionTarget, id string) (string, error) {
+	table, ok := resourceTables[target]
+	if !ok {
+		return "", fmt.Errorf("unsupported permission target %s", target)
+	}
+	var kbIDs []string
+	if err := r.db.WithContext(ctx).
+		Table(table).
+		Where("id = ?", id).
+		Limit(1).
+		Pluck("kb_id", &kbIDs).Error; err != nil {
+		return "", err
+	}
+	if len(kbIDs) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return kbIDs[0], nil
+}
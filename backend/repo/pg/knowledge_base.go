@@ -414,6 +414,22 @@ func (r *KnowledgeBaseRepository) UpdateKnowledgeBase(ctx context.Context, req *
 	if req.AccessSettings != nil {
 		updateMap["access_settings"] = req.AccessSettings
 	}
+	if req.RetrievalSettings != nil {
+		updateMap["retrieval_settings"] = req.RetrievalSettings
+	}
+	if req.PromptSettings != nil {
+		updateMap["prompt_settings"] = req.PromptSettings
+	}
+	if req.ChatModelIDs != nil {
+		chatModelIDs, err := json.Marshal(req.ChatModelIDs)
+		if err != nil {
+			return err
+		}
+		updateMap["chat_model_ids"] = string(chatModelIDs)
+	}
+	if req.QuotaSettings != nil {
+		updateMap["quota_settings"] = req.QuotaSettings
+	}
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&domain.KnowledgeBase{}).Where("id = ?", req.ID).Updates(updateMap).Error; err != nil {
 			return err
@@ -451,6 +467,9 @@ func (r *KnowledgeBaseRepository) DeleteKnowledgeBase(ctx context.Context, kbID
 		if err := tx.Where("kb_id = ?", kbID).Delete(&domain.App{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("kb_id = ?", kbID).Delete(&domain.KBMember{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("id = ?", kbID).Delete(&domain.KnowledgeBase{}).Error; err != nil {
 			return err
 		}
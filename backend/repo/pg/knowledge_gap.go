@@ -0,0 +1,59 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type KnowledgeGapRepository struct {
+	db *pg.DB
+}
+
+func NewKnowledgeGapRepository(db *pg.DB) *KnowledgeGapRepository {
+	return &KnowledgeGapRepository{db: db}
+}
+
+func (r *KnowledgeGapRepository) CreateUnansweredQuestion(ctx context.Context, question *domain.UnansweredQuestion) error {
+	return r.db.WithContext(ctx).Create(question).Error
+}
+
+// GetUnansweredQuestions gets unanswered questions of the kb since the time, the latest first
+func (r *KnowledgeGapRepository) GetUnansweredQuestions(ctx context.Context, kbID string, since time.Time, includeResolved bool) ([]*domain.UnansweredQuestion, error) {
+	var questions []*domain.UnansweredQuestion
+	query := r.db.WithContext(ctx).
+		Model(&domain.UnansweredQuestion{}).
+		Where("kb_id = ?", kbID).
+		Where("created_at >= ?", since)
+	if !includeResolved {
+		query = query.Where("node_id = ''")
+	}
+	if err := query.Order("created_at DESC").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+func (r *KnowledgeGapRepository) GetUnansweredQuestionsByIDs(ctx context.Context, kbID string, ids []string) ([]*domain.UnansweredQuestion, error) {
+	var questions []*domain.UnansweredQuestion
+	if err := r.db.WithContext(ctx).
+		Model(&domain.UnansweredQuestion{}).
+		Where("kb_id = ?", kbID).
+		Where("id IN ?", ids).
+		Order("created_at DESC").
+		Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// ResolveUnansweredQuestions links the questions to the draft node
+func (r *KnowledgeGapRepository) ResolveUnansweredQuestions(ctx context.Context, kbID string, ids []string, nodeID string) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.UnansweredQuestion{}).
+		Where("kb_id = ?", kbID).
+		Where("id IN ?", ids).
+		Update("node_id", nodeID).Error
+}
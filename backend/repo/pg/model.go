@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/domain"
@@ -22,9 +23,9 @@ func NewModelRepository(db *pg.DB, logger *log.Logger) *ModelRepository {
 
 func (r *ModelRepository) Create(ctx context.Context, model *domain.Model) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// activate model if it's the first model
+		// activate model if it's the first model of the type
 		var count int64
-		if err := tx.Model(&domain.Model{}).Count(&count).Error; err != nil {
+		if err := tx.Model(&domain.Model{}).Where("type = ?", model.Type).Count(&count).Error; err != nil {
 			return err
 		}
 		if count == 0 {
@@ -97,6 +98,66 @@ func (r *ModelRepository) GetChatModel(ctx context.Context) (*domain.Model, erro
 	return &model, nil
 }
 
+// GetChatModelsByIDs get chat models in the order of ids, missing ones are skipped
+func (r *ModelRepository) GetChatModelsByIDs(ctx context.Context, ids []string) ([]*domain.Model, error) {
+	var models []*domain.Model
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("type = ?", domain.ModelTypeChat).
+		Where("id IN ?", ids).
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	modelMap := lo.SliceToMap(models, func(model *domain.Model) (string, *domain.Model) {
+		return model.ID, model
+	})
+	orderedModels := make([]*domain.Model, 0, len(models))
+	for _, id := range lo.Uniq(ids) {
+		if model, ok := modelMap[id]; ok {
+			orderedModels = append(orderedModels, model)
+		}
+	}
+	return orderedModels, nil
+}
+
+// GetRerankModel get the rerank model, there is at most one
+func (r *ModelRepository) GetRerankModel(ctx context.Context) (*domain.Model, error) {
+	return r.getModelByType(ctx, domain.ModelTypeRerank)
+}
+
+// GetEmbeddingModel get the embedding model, there is at most one
+func (r *ModelRepository) GetEmbeddingModel(ctx context.Context) (*domain.Model, error) {
+	return r.getModelByType(ctx, domain.ModelTypeEmbedding)
+}
+
+// GetActiveModel get the activated model of the type, nil if none is activated
+func (r *ModelRepository) GetActiveModel(ctx context.Context, modelType domain.ModelType) (*domain.Model, error) {
+	var models []*domain.Model
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("type = ?", modelType).
+		Where("is_active = ?", true).
+		Limit(1).
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, nil
+	}
+	return models[0], nil
+}
+
+func (r *ModelRepository) getModelByType(ctx context.Context, modelType domain.ModelType) (*domain.Model, error) {
+	var model domain.Model
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("type = ?", modelType).
+		First(&model).Error; err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
 func (r *ModelRepository) UpdateUsage(ctx context.Context, modelID string, usage *schema.TokenUsage) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// update model usage
@@ -113,6 +174,17 @@ func (r *ModelRepository) UpdateUsage(ctx context.Context, modelID string, usage
 	})
 }
 
+// UpdateCitationStats counts the verified citations of an answer
+func (r *ModelRepository) UpdateCitationStats(ctx context.Context, modelID string, total, unsupported int) error {
+	return r.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("id = ?", modelID).
+		Updates(map[string]any{
+			"citation_count":             gorm.Expr("citation_count + ?", total),
+			"unsupported_citation_count": gorm.Expr("unsupported_citation_count + ?", unsupported),
+		}).Error
+}
+
 // ActivateModel activates a model and deactivates others of the same type
 func (r *ModelRepository) ActivateModel(ctx context.Context, modelID string) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -2,17 +2,21 @@ package pg
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
 	"github.com/google/uuid"
 	"github.com/samber/lo"
 
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
 	"github.com/chaitin/panda-wiki/store/pg"
+	"github.com/chaitin/panda-wiki/utils"
 )
 
 type NodeRepository struct {
@@ -110,6 +114,20 @@ func (r *NodeRepository) UpdateNodesVisibility(ctx context.Context, kbID string,
 		}).Error
 }
 
+// GetNodesByIDs the nodes of the kb without the content
+func (r *NodeRepository) GetNodesByIDs(ctx context.Context, kbID string, ids []string) ([]*domain.Node, error) {
+	var nodes []*domain.Node
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Node{}).
+		Select("id", "kb_id", "type", "status", "visibility", "name", "parent_id").
+		Where("id IN ?", ids).
+		Where("kb_id = ?", kbID).
+		Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
 func (r *NodeRepository) GetLatestNodeReleaseByNodeIDs(ctx context.Context, kbID string, ids []string) ([]*domain.NodeRelease, error) {
 	var nodeReleases []*domain.NodeRelease
 	if err := r.db.WithContext(ctx).
@@ -574,13 +592,91 @@ func (r *NodeRepository) CreateNodeReleases(ctx context.Context, kbID string, no
 		if err := tx.CreateInBatches(&nodeReleases, 100).Error; err != nil {
 			return err
 		}
-		return nil
+		// build full text search vector for keyword retrieval
+		return updateNodeReleaseSearchVectors(tx, nodeReleases)
 	}); err != nil {
 		return nil, err
 	}
 	return releaseIDs, nil
 }
 
+// updateNodeReleaseSearchVectors the vectors are built from the markdown, one update for each batch of 100 releases
+func updateNodeReleaseSearchVectors(tx *gorm.DB, nodeReleases []*domain.NodeRelease) error {
+	for batch := range slices.Chunk(nodeReleases, 100) {
+		values := make([]string, 0, len(batch))
+		args := make([]any, 0, 2*len(batch))
+		for _, nodeRelease := range batch {
+			text := nodeRelease.Content
+			if strings.HasPrefix(text, "<") {
+				if markdown, err := htmltomarkdown.ConvertString(text); err == nil {
+					text = markdown
+				}
+			}
+			values = append(values, "(?, ?)")
+			args = append(args, nodeRelease.ID, utils.BuildTSVector(nodeRelease.Name+"\n"+text))
+		}
+		if err := tx.Exec(`UPDATE node_releases SET search_vector = CAST(v.search_vector AS tsvector)
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, search_vector)
+			WHERE node_releases.id = v.id`, args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildMissingNodeReleaseSearchVectors build search vector for node releases created before keyword retrieval, in the tx of the caller
+func (r *NodeRepository) BuildMissingNodeReleaseSearchVectors(ctx context.Context, tx *gorm.DB) (int, error) {
+	count := 0
+	var nodeReleases []*domain.NodeRelease
+	err := tx.WithContext(ctx).
+		Model(&domain.NodeRelease{}).
+		Where("search_vector IS NULL").
+		Select("id, name, content").
+		FindInBatches(&nodeReleases, 100, func(batchTx *gorm.DB, batch int) error {
+			if err := updateNodeReleaseSearchVectors(tx.WithContext(ctx), nodeReleases); err != nil {
+				return err
+			}
+			count += len(nodeReleases)
+			return nil
+		}).Error
+	return count, err
+}
+
+// SearchNodeReleases full text search on indexed public node releases of kb, ordered by rank
+func (r *NodeRepository) SearchNodeReleases(ctx context.Context, kbID, query string, limit int) ([]*domain.KeywordSearchResult, error) {
+	tsQuery := utils.BuildTSQuery(query)
+	if tsQuery == "" {
+		return nil, nil
+	}
+	var rows []*struct {
+		domain.NodeRelease
+		Rank float64
+	}
+	if err := r.db.WithContext(ctx).
+		Raw(`SELECT node_releases.id, node_releases.kb_id, node_releases.node_id, node_releases.doc_id, node_releases.type,
+				node_releases.visibility, node_releases.name, node_releases.meta, node_releases.content,
+				node_releases.parent_id, node_releases.position, node_releases.created_at, node_releases.updated_at,
+				ts_rank(node_releases.search_vector, query, 1) AS rank
+			FROM node_releases, CAST(? AS tsquery) AS query
+			WHERE node_releases.kb_id = ?
+				AND node_releases.visibility = ?
+				AND node_releases.doc_id != ''
+				AND node_releases.search_vector @@ query
+			ORDER BY rank DESC
+			LIMIT ?`, tsQuery, kbID, domain.NodeVisibilityPublic, limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	results := make([]*domain.KeywordSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = &domain.KeywordSearchResult{
+			NodeRelease: &row.NodeRelease,
+			Rank:        row.Rank,
+		}
+	}
+	return results, nil
+}
+
 func (r *NodeRepository) GetOldNodeDocIDsByNodeID(ctx context.Context, nodeReleaseID, nodeID string) ([]string, error) {
 	var docIDs []string
 	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
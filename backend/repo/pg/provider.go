@@ -16,4 +16,11 @@ var ProviderSet = wire.NewSet(
 	NewUserAccessRepository,
 	NewModelRepository,
 	NewKnowledgeBaseRepository,
+	NewEvalRepository,
+	NewKnowledgeGapRepository,
+	NewAppAPIKeyRepository,
+	NewAPITokenRepository,
+	NewKBMemberRepository,
+	NewSettingRepository,
+	NewAuditLogRepository,
 )
@@ -0,0 +1,49 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+type SettingRepository struct {
+	db *pg.DB
+}
+
+func NewSettingRepository(db *pg.DB) *SettingRepository {
+	return &SettingRepository{db: db}
+}
+
+// GetSetting unmarshals the setting of the key into value, value is left as is if the setting is missing
+func (r *SettingRepository) GetSetting(ctx context.Context, key string, value any) error {
+	var setting domain.Setting
+	if err := r.db.WithContext(ctx).Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(setting.Value, value)
+}
+
+func (r *SettingRepository) UpsertSetting(ctx context.Context, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&domain.Setting{
+		Key:       key,
+		Value:     data,
+		UpdatedAt: time.Now(),
+	}).Error
+}
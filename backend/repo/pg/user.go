@@ -46,7 +46,10 @@ func (r *UserRepository) UpsertDefaultUser(ctx context.Context, user *domain.Use
 			return nil
 		}
 		// User exists, update password
-		return tx.Model(&existingUser).Update("password", user.Password).Error
+		return tx.Model(&existingUser).Updates(map[string]any{
+			"password": user.Password,
+			"role":     user.Role,
+		}).Error
 	})
 }
 
@@ -71,6 +74,17 @@ func (r *UserRepository) VerifyUser(ctx context.Context, account string, passwor
 	return &user, nil
 }
 
+func (r *UserRepository) GetUserByExternalID(ctx context.Context, source domain.UserSource, externalID string) (*domain.User, error) {
+	var user domain.User
+	err := r.db.WithContext(ctx).
+		Where("source = ? AND external_id = ?", source, externalID).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) GetUser(ctx context.Context, userID string) (*domain.UserInfoResp, error) {
 	var user domain.UserInfoResp
 	err := r.db.WithContext(ctx).
@@ -103,6 +117,45 @@ func (r *UserRepository) UpdateUserPassword(ctx context.Context, userID string,
 	return r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userID).Update("password", string(hashedPassword)).Error
 }
 
+func (r *UserRepository) UpdateUserRole(ctx context.Context, userID string, role domain.Role) error {
+	return r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
 func (r *UserRepository) DeleteUser(ctx context.Context, userID string) error {
 	return r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userID).Delete(&domain.User{}).Error
 }
+
+// GetUserByID the user with the 2fa secrets, unlike GetUser for display
+func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateTwoFactor sets the secret, the enabled flag and the recovery code hashes of the user
+func (r *UserRepository) UpdateTwoFactor(ctx context.Context, userID string, enabled bool, secret string, recoveryCodes []string) error {
+	if recoveryCodes == nil {
+		recoveryCodes = []string{}
+	}
+	return r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ?", userID).
+		Select("two_factor_enabled", "two_factor_secret", "two_factor_recovery_codes").
+		Updates(&domain.User{
+			TwoFactorEnabled:       enabled,
+			TwoFactorSecret:        secret,
+			TwoFactorRecoveryCodes: recoveryCodes,
+		}).Error
+}
+
+// UseRecoveryCode removes the hash from the unused recovery codes atomically, false if it is not unused
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ? AND two_factor_recovery_codes @> jsonb_build_array(?::text)", userID, codeHash).
+		Update("two_factor_recovery_codes", gorm.Expr("two_factor_recovery_codes - ?::text", codeHash))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,6 @@
+-- drop retrieval settings from knowledge_bases
+ALTER TABLE knowledge_bases DROP COLUMN retrieval_settings;
+
+-- drop full text search vector from node_releases
+DROP INDEX IF EXISTS idx_node_releases_search_vector;
+ALTER TABLE node_releases DROP COLUMN search_vector;
@@ -0,0 +1,6 @@
+-- add full text search vector to node_releases
+ALTER TABLE node_releases ADD COLUMN search_vector tsvector NULL;
+CREATE INDEX idx_node_releases_search_vector ON node_releases USING GIN (search_vector);
+
+-- add retrieval settings to knowledge_bases
+ALTER TABLE knowledge_bases ADD COLUMN retrieval_settings jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+-- drop retrieved chunks from conversation_messages
+ALTER TABLE conversation_messages DROP COLUMN retrieved_chunks;
@@ -0,0 +1,2 @@
+-- add retrieved chunks to conversation_messages
+ALTER TABLE conversation_messages ADD COLUMN retrieved_chunks jsonb NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "public"."eval_reports";
+DROP TABLE IF EXISTS "public"."eval_sets";
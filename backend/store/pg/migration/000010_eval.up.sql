@@ -0,0 +1,34 @@
+-- create eval_sets
+CREATE TABLE IF NOT EXISTS "public"."eval_sets" (
+    id text NOT NULL,
+    kb_id text NOT NULL,
+    name text NOT NULL,
+    description text NULL,
+    cases jsonb NOT NULL DEFAULT '[]',
+    created_at timestamptz NULL,
+    updated_at timestamptz NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_eval_sets_kb_id" ON "public"."eval_sets" ("kb_id");
+
+-- create eval_reports
+CREATE TABLE IF NOT EXISTS "public"."eval_reports" (
+    id text NOT NULL,
+    kb_id text NOT NULL,
+    set_id text NOT NULL,
+    k integer NOT NULL DEFAULT 0,
+    case_count integer NOT NULL DEFAULT 0,
+    recall_at_k double precision NOT NULL DEFAULT 0,
+    mrr double precision NOT NULL DEFAULT 0,
+    hit_rate double precision NOT NULL DEFAULT 0,
+    retrieval_settings jsonb NULL,
+    embedding_model text NULL,
+    rerank_model text NULL,
+    results jsonb NULL,
+    created_at timestamptz NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_eval_reports_kb_id" ON "public"."eval_reports" ("kb_id");
+CREATE INDEX IF NOT EXISTS "idx_eval_reports_set_id" ON "public"."eval_reports" ("set_id");
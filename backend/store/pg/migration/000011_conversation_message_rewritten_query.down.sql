@@ -0,0 +1,3 @@
+-- drop rewritten retrieval query from conversation_messages
+ALTER TABLE conversation_messages DROP COLUMN sub_queries;
+ALTER TABLE conversation_messages DROP COLUMN rewritten_query;
@@ -0,0 +1,3 @@
+-- add rewritten retrieval query to conversation_messages
+ALTER TABLE conversation_messages ADD COLUMN rewritten_query text NULL;
+ALTER TABLE conversation_messages ADD COLUMN sub_queries jsonb NULL;
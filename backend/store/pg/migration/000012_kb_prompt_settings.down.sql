@@ -0,0 +1,2 @@
+-- drop answer prompt settings from knowledge_bases
+ALTER TABLE knowledge_bases DROP COLUMN prompt_settings;
@@ -0,0 +1,2 @@
+-- add answer prompt settings to knowledge_bases
+ALTER TABLE knowledge_bases ADD COLUMN prompt_settings jsonb NOT NULL DEFAULT '{}';
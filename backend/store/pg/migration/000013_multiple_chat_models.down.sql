@@ -0,0 +1,16 @@
+-- drop ordered chat model ids from knowledge_bases
+ALTER TABLE knowledge_bases DROP COLUMN chat_model_ids;
+
+-- keep only one chat model, the activated one or the oldest, before restoring the unique index
+DELETE FROM models
+WHERE type = 'chat'
+  AND id <> (
+    SELECT id FROM models
+    WHERE type = 'chat'
+    ORDER BY is_active DESC, created_at ASC
+    LIMIT 1
+  );
+
+-- restore unique index for type
+drop index idx_models_type;
+create unique index idx_models_type on models (type);
@@ -0,0 +1,6 @@
+-- allow multiple chat models, embedding and rerank models are still unique
+drop index idx_models_type;
+create unique index idx_models_type on models (type) where type <> 'chat';
+
+-- add ordered chat model ids to knowledge_bases
+ALTER TABLE knowledge_bases ADD COLUMN chat_model_ids jsonb NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- drop chat quota settings from knowledge_bases
+ALTER TABLE knowledge_bases DROP COLUMN quota_settings;
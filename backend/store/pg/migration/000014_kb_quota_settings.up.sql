@@ -0,0 +1,2 @@
+-- add chat quota settings to knowledge_bases
+ALTER TABLE knowledge_bases ADD COLUMN quota_settings jsonb NOT NULL DEFAULT '{}';
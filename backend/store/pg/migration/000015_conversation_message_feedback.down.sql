@@ -0,0 +1,6 @@
+-- drop reader feedback from conversation_messages
+DROP INDEX IF EXISTS idx_conversation_messages_feedback_score;
+ALTER TABLE conversation_messages DROP COLUMN feedback_at;
+ALTER TABLE conversation_messages DROP COLUMN feedback_comment;
+ALTER TABLE conversation_messages DROP COLUMN feedback_reason;
+ALTER TABLE conversation_messages DROP COLUMN feedback_score;
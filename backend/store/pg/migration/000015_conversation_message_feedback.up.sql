@@ -0,0 +1,6 @@
+-- add reader feedback to conversation_messages
+ALTER TABLE conversation_messages ADD COLUMN feedback_score smallint NOT NULL DEFAULT 0;
+ALTER TABLE conversation_messages ADD COLUMN feedback_reason text NOT NULL DEFAULT '';
+ALTER TABLE conversation_messages ADD COLUMN feedback_comment text NOT NULL DEFAULT '';
+ALTER TABLE conversation_messages ADD COLUMN feedback_at timestamptz NULL;
+CREATE INDEX idx_conversation_messages_feedback_score ON conversation_messages (feedback_score) WHERE feedback_score != 0;
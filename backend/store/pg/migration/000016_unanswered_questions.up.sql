@@ -0,0 +1,15 @@
+-- create unanswered_questions
+CREATE TABLE IF NOT EXISTS "public"."unanswered_questions" (
+    id text NOT NULL,
+    kb_id text NOT NULL,
+    app_id text NOT NULL,
+    conversation_id text NOT NULL,
+    message_id text NOT NULL,
+    question text NOT NULL,
+    reason text NOT NULL,
+    node_id text NOT NULL DEFAULT '',
+    created_at timestamptz NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_unanswered_questions_kb_id_created_at" ON "public"."unanswered_questions" ("kb_id", "created_at");
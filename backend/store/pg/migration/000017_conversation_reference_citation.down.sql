@@ -0,0 +1,6 @@
+-- drop structured citation from conversation_references
+DROP INDEX IF EXISTS "idx_conversation_references_message_id";
+ALTER TABLE conversation_references DROP COLUMN snippet;
+ALTER TABLE conversation_references DROP COLUMN chunk_id;
+ALTER TABLE conversation_references DROP COLUMN number;
+ALTER TABLE conversation_references DROP COLUMN message_id;
@@ -0,0 +1,6 @@
+-- add structured citation to conversation_references
+ALTER TABLE conversation_references ADD COLUMN message_id text NULL;
+ALTER TABLE conversation_references ADD COLUMN number integer NOT NULL DEFAULT 0;
+ALTER TABLE conversation_references ADD COLUMN chunk_id text NULL;
+ALTER TABLE conversation_references ADD COLUMN snippet text NULL;
+CREATE INDEX IF NOT EXISTS "idx_conversation_references_message_id" ON "public"."conversation_references" ("message_id");
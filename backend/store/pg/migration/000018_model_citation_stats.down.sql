@@ -0,0 +1,3 @@
+-- drop citation verification stats from models
+ALTER TABLE models DROP COLUMN unsupported_citation_count;
+ALTER TABLE models DROP COLUMN citation_count;
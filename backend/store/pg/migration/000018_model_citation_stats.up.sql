@@ -0,0 +1,3 @@
+-- add citation verification stats to models
+ALTER TABLE models ADD COLUMN citation_count bigint NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN unsupported_citation_count bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- drop parent_id from conversation_messages
+DROP INDEX IF EXISTS "idx_conversation_messages_parent_id";
+ALTER TABLE conversation_messages DROP COLUMN parent_id;
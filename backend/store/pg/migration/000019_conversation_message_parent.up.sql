@@ -0,0 +1,11 @@
+-- add parent_id to conversation_messages for regenerated answers and edited questions
+ALTER TABLE conversation_messages ADD COLUMN parent_id text NULL;
+-- link existing messages in the order of creation
+UPDATE conversation_messages
+SET parent_id = previous.parent_id
+FROM (
+    SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY created_at) AS parent_id
+    FROM conversation_messages
+) AS previous
+WHERE conversation_messages.id = previous.id AND previous.parent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_conversation_messages_parent_id" ON "public"."conversation_messages" ("parent_id");
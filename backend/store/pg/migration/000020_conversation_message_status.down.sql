@@ -0,0 +1,2 @@
+-- drop status from conversation_messages
+ALTER TABLE conversation_messages DROP COLUMN status;
@@ -0,0 +1,2 @@
+-- add status to conversation_messages for stopped and failed answers
+ALTER TABLE conversation_messages ADD COLUMN status text NOT NULL DEFAULT 'completed';
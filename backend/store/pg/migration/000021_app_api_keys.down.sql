@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "public"."app_api_keys";
@@ -0,0 +1,16 @@
+-- create app_api_keys
+CREATE TABLE IF NOT EXISTS "public"."app_api_keys" (
+    id text NOT NULL,
+    kb_id text NOT NULL,
+    app_id text NOT NULL,
+    name text NOT NULL DEFAULT '',
+    key_hash text NOT NULL,
+    key_prefix text NOT NULL DEFAULT '',
+    last_used_at timestamptz NULL,
+    created_at timestamptz NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_app_api_keys_kb_id" ON "public"."app_api_keys" ("kb_id");
+CREATE INDEX IF NOT EXISTS "idx_app_api_keys_app_id" ON "public"."app_api_keys" ("app_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_app_api_keys_key_hash" ON "public"."app_api_keys" ("key_hash");
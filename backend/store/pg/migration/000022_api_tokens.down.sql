@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "public"."api_tokens";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "type";
@@ -0,0 +1,20 @@
+-- service accounts
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "type" text NOT NULL DEFAULT 'user';
+
+-- create api_tokens
+CREATE TABLE IF NOT EXISTS "public"."api_tokens" (
+    id text NOT NULL,
+    user_id text NOT NULL,
+    name text NOT NULL DEFAULT '',
+    token_hash text NOT NULL,
+    token_prefix text NOT NULL DEFAULT '',
+    scopes jsonb NOT NULL DEFAULT '[]',
+    expires_at timestamptz NULL,
+    last_used_at timestamptz NULL,
+    last_used_ip text NOT NULL DEFAULT '',
+    created_at timestamptz NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_api_tokens_user_id" ON "public"."api_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_tokens_token_hash" ON "public"."api_tokens" ("token_hash");
@@ -0,0 +1,15 @@
+-- global roles, existing users kept their full access and the default admin becomes the owner
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "role" text NOT NULL DEFAULT '';
+UPDATE "public"."users" SET "role" = 'admin';
+UPDATE "public"."users" SET "role" = 'owner' WHERE "account" = 'admin';
+
+-- create kb_members
+CREATE TABLE IF NOT EXISTS "public"."kb_members" (
+    kb_id text NOT NULL,
+    user_id text NOT NULL,
+    role text NOT NULL,
+    created_at timestamptz NULL,
+    PRIMARY KEY (kb_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_kb_members_user_id" ON "public"."kb_members" ("user_id");
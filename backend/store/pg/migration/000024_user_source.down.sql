@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_users_external_id";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "external_id";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "source";
@@ -0,0 +1,5 @@
+-- users provisioned by identity providers
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "source" text NOT NULL DEFAULT 'local';
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "external_id" text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS "idx_users_external_id" ON "public"."users" ("external_id");
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "public"."settings";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "two_factor_recovery_codes";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "two_factor_secret";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "two_factor_enabled";
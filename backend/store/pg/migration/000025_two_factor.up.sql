@@ -0,0 +1,12 @@
+-- totp two factor authentication
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "two_factor_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "two_factor_secret" text NOT NULL DEFAULT '';
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "two_factor_recovery_codes" jsonb NOT NULL DEFAULT '[]';
+
+-- create settings
+CREATE TABLE IF NOT EXISTS "public"."settings" (
+    key text NOT NULL,
+    value jsonb NOT NULL DEFAULT '{}',
+    updated_at timestamptz NULL,
+    PRIMARY KEY (key)
+);
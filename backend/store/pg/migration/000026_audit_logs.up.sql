@@ -0,0 +1,34 @@
+-- create audit_logs
+CREATE TABLE IF NOT EXISTS "public"."audit_logs" (
+    id bigserial NOT NULL,
+    actor_id text NOT NULL DEFAULT '',
+    actor_account text NOT NULL DEFAULT '',
+    api_token_id text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    action text NOT NULL,
+    target_type text NOT NULL DEFAULT '',
+    target_id text NOT NULL DEFAULT '',
+    kb_id text NOT NULL DEFAULT '',
+    diff jsonb NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "public"."audit_logs" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "public"."audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_id" ON "public"."audit_logs" ("target_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_kb_id" ON "public"."audit_logs" ("kb_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "public"."audit_logs" ("created_at");
+
+-- the audit log is append only
+CREATE OR REPLACE FUNCTION "public"."audit_logs_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "audit_logs_append_only" ON "public"."audit_logs";
+CREATE TRIGGER "audit_logs_append_only"
+    BEFORE UPDATE OR DELETE ON "public"."audit_logs"
+    FOR EACH ROW EXECUTE FUNCTION "public"."audit_logs_append_only"();
@@ -0,0 +1,4 @@
+-- the vector extension is kept, it may be used by others
+DROP TABLE IF EXISTS "public"."rag_chunks";
+DROP TABLE IF EXISTS "public"."rag_documents";
+DROP TABLE IF EXISTS "public"."rag_datasets";
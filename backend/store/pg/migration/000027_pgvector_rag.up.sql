@@ -0,0 +1,14 @@
+-- the vector extension of the pgvector rag, skipped if it is not installed or can not be created by this role,
+-- the pgvector rag fails to start until a superuser creates it.
+-- the tables are created by the pgvector rag, the dimension of the vectors depends on the embedding model
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        RAISE NOTICE 'extension vector is not installed, the pgvector rag is not available';
+        RETURN;
+    END IF;
+    CREATE EXTENSION IF NOT EXISTS vector;
+EXCEPTION WHEN insufficient_privilege THEN
+    RAISE NOTICE 'permission denied to create extension vector, the pgvector rag is not available';
+END
+$$;
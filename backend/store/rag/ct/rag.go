@@ -61,11 +61,14 @@ func (s *CTRAG) CreateKnowledgeBase(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("create dataset failed: %v, list fallback empty", err)
 }
 
-func (s *CTRAG) QueryRecords(ctx context.Context, datasetIDs []string, query string) ([]*domain.NodeContentChunk, error) {
+func (s *CTRAG) QueryRecords(ctx context.Context, datasetIDs []string, query string, topK int) ([]*domain.NodeContentChunk, error) {
+	if topK <= 0 {
+		topK = 10
+	}
 	chunks, _, err := s.client.RetrieveChunks(ctx, rag.RetrievalRequest{
 		DatasetIDs: datasetIDs,
 		Question:   query,
-		TopK:       10,
+		TopK:       topK,
 		// SimilarityThreshold: 0.2,
 	})
 	if err != nil {
@@ -0,0 +1,77 @@
+package pgvector
+
+import (
+	"strings"
+)
+
+// splitText splits markdown into chunks of at most size runes.
+// Paragraphs are kept together when possible, and adjacent chunks share
+// overlap runes so that sentences cut at a boundary are still searchable.
+func splitText(content string, size, overlap int) []string {
+	if size <= 0 {
+		size = 1024
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+
+	chunks := make([]string, 0)
+	current := make([]rune, 0, size)
+	flush := func() {
+		text := strings.TrimSpace(string(current))
+		if text != "" {
+			chunks = append(chunks, text)
+		}
+		if overlap > 0 && len(current) > overlap {
+			current = append(current[:0], current[len(current)-overlap:]...)
+		} else {
+			current = current[:0]
+		}
+	}
+
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		runes := []rune(paragraph)
+		// paragraph fits into the current chunk
+		if len(current)+len(runes)+2 <= size {
+			if len(current) > 0 {
+				current = append(current, '\n', '\n')
+			}
+			current = append(current, runes...)
+			continue
+		}
+		if len(current) > overlap {
+			flush()
+			if len(current)+len(runes)+2 <= size {
+				if len(current) > 0 {
+					current = append(current, '\n', '\n')
+				}
+				current = append(current, runes...)
+				continue
+			}
+		}
+		// paragraph is too long, cut it by size
+		for len(runes) > 0 {
+			n := min(size-len(current), len(runes))
+			current = append(current, runes[:n]...)
+			runes = runes[n:]
+			if len(current) >= size {
+				flush()
+			}
+		}
+	}
+	if len(current) > 0 && (len(chunks) == 0 || len(current) > overlap) {
+		text := strings.TrimSpace(string(current))
+		if text != "" {
+			chunks = append(chunks, text)
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,121 @@
+package pgvector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+const embeddingBatchSize = 16
+
+// Embedder turns texts into vectors with the given embedding model
+type Embedder interface {
+	Embed(ctx context.Context, model *domain.Model, texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbedder calls the OpenAI compatible /embeddings API
+type OpenAIEmbedder struct {
+	client *http.Client
+}
+
+func NewOpenAIEmbedder() *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type embeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, model *domain.Model, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		batch, err := e.embedBatch(ctx, model, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, model *domain.Model, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{
+		Model:          model.Model,
+		Input:          texts,
+		EncodingFormat: "float",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedding request failed: %w", err)
+	}
+	url := strings.TrimRight(model.BaseURL, "/") + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new embedding request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if model.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+model.APIKey)
+	}
+	for k, v := range utils.GetHeaderMap(model.APIHeader) {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding request failed: %s %s", resp.Status, string(msg))
+	}
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode embedding response failed: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding count mismatch: want %d, got %d", len(texts), len(result.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, item := range result.Data {
+		index := item.Index
+		if index < 0 || index >= len(texts) {
+			index = i
+		}
+		vectors[index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// formatVector formats a vector as a pgvector literal, e.g. [0.1,0.2]
+func formatVector(vector []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%g", v)
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -0,0 +1,351 @@
+package pgvector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+// table: rag_datasets
+type Dataset struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	CreatedAt time.Time
+}
+
+func (Dataset) TableName() string { return "rag_datasets" }
+
+// table: rag_documents
+type Document struct {
+	ID        string `gorm:"primaryKey"`
+	DatasetID string
+	Name      string
+	CreatedAt time.Time
+}
+
+func (Document) TableName() string { return "rag_documents" }
+
+// table: rag_chunks
+type Chunk struct {
+	ID         string `gorm:"primaryKey"`
+	DatasetID  string
+	DocumentID string
+	Seq        uint
+	Content    string
+	Embedding  []float32 `gorm:"-"`
+	CreatedAt  time.Time
+}
+
+func (Chunk) TableName() string { return "rag_chunks" }
+
+// schema the vector column has no fixed dimension because it depends on the embedding model,
+// the chunks of every dimension are indexed by ensureEmbeddingIndex
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS rag_datasets (
+		id text NOT NULL,
+		name text NULL,
+		created_at timestamptz NULL,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS rag_documents (
+		id text NOT NULL,
+		dataset_id text NOT NULL,
+		name text NULL,
+		created_at timestamptz NULL,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rag_documents_dataset_id ON rag_documents (dataset_id)`,
+	`CREATE TABLE IF NOT EXISTS rag_chunks (
+		id text NOT NULL,
+		dataset_id text NOT NULL,
+		document_id text NOT NULL,
+		seq integer NOT NULL DEFAULT 0,
+		content text NULL,
+		embedding vector NOT NULL,
+		created_at timestamptz NULL,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rag_chunks_dataset_id ON rag_chunks (dataset_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_rag_chunks_document_id ON rag_chunks (document_id)`,
+}
+
+// maxIndexDimensions the most dimensions hnsw can index, larger vectors are scanned
+const maxIndexDimensions = 2000
+
+type PGVectorRAG struct {
+	db       *pg.DB
+	embedder Embedder
+	logger   *log.Logger
+
+	chunkSize    int
+	chunkOverlap int
+	topK         int
+
+	// dimensions whose hnsw index has been created
+	indexedDimensions sync.Map
+}
+
+func NewPGVectorRAG(cfg *config.Config, db *pg.DB, logger *log.Logger) (*PGVectorRAG, error) {
+	// the extension is created by the migration, which needs a superuser on most servers
+	var installed bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')").Scan(&installed).Error; err != nil {
+		return nil, fmt.Errorf("check extension vector failed: %w", err)
+	}
+	if !installed {
+		return nil, errors.New("extension vector not found, install pgvector and run CREATE EXTENSION vector as a superuser")
+	}
+	for _, stmt := range schema {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, fmt.Errorf("create pgvector schema failed: %w", err)
+		}
+	}
+	topK := cfg.RAG.PGVector.TopK
+	if topK <= 0 {
+		topK = 10
+	}
+	return &PGVectorRAG{
+		db:           db,
+		embedder:     NewOpenAIEmbedder(),
+		logger:       logger.WithModule("store.vector.pgvector"),
+		chunkSize:    cfg.RAG.PGVector.ChunkSize,
+		chunkOverlap: cfg.RAG.PGVector.ChunkOverlap,
+		topK:         topK,
+	}, nil
+}
+
+func (s *PGVectorRAG) CreateKnowledgeBase(ctx context.Context) (string, error) {
+	dataset := &Dataset{
+		ID:        uuid.New().String(),
+		Name:      uuid.New().String(),
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Create(dataset).Error; err != nil {
+		return "", fmt.Errorf("create dataset failed: %w", err)
+	}
+	return dataset.ID, nil
+}
+
+func (s *PGVectorRAG) UpsertRecords(ctx context.Context, datasetID string, nodeRelease *domain.NodeRelease) (string, error) {
+	model, err := s.getEmbeddingModel(ctx)
+	if err != nil {
+		return "", err
+	}
+	// convert html to markdown
+	markdown := nodeRelease.Content
+	if strings.HasPrefix(nodeRelease.Content, "<") {
+		markdown, err = htmltomarkdown.ConvertString(nodeRelease.Content)
+		if err != nil {
+			return "", fmt.Errorf("convert html to markdown failed: %w", err)
+		}
+	}
+	doc := &Document{
+		ID:        uuid.New().String(),
+		DatasetID: datasetID,
+		Name:      nodeRelease.Name,
+		CreatedAt: time.Now(),
+	}
+	chunks, err := buildChunks(ctx, s.embedder, model, doc, markdown, s.chunkSize, s.chunkOverlap)
+	if err != nil {
+		return "", err
+	}
+	if len(chunks) > 0 {
+		if err := s.ensureEmbeddingIndex(ctx, len(chunks[0].Embedding)); err != nil {
+			return "", err
+		}
+	}
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(doc).Error; err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			if err := tx.Exec(
+				`INSERT INTO rag_chunks (id, dataset_id, document_id, seq, content, embedding, created_at) VALUES (?, ?, ?, ?, ?, ?::vector, ?)`,
+				chunk.ID, chunk.DatasetID, chunk.DocumentID, chunk.Seq, chunk.Content, formatVector(chunk.Embedding), chunk.CreatedAt,
+			).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("save document chunks failed: %w", err)
+	}
+	return doc.ID, nil
+}
+
+// ensureEmbeddingIndex creates the hnsw index of the chunks of the dimensions,
+// the index is partial because hnsw only indexes vectors of a fixed dimension
+func (s *PGVectorRAG) ensureEmbeddingIndex(ctx context.Context, dimensions int) error {
+	if dimensions > maxIndexDimensions {
+		return nil
+	}
+	if _, ok := s.indexedDimensions.Load(dimensions); ok {
+		return nil
+	}
+	if err := s.db.WithContext(ctx).Exec(fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_%[1]d ON rag_chunks
+			USING hnsw ((embedding::vector(%[1]d)) vector_cosine_ops)
+			WHERE vector_dims(embedding) = %[1]d`, dimensions,
+	)).Error; err != nil {
+		return fmt.Errorf("create embedding index failed: %w", err)
+	}
+	s.indexedDimensions.Store(dimensions, struct{}{})
+	return nil
+}
+
+// buildChunks splits the document content and embeds every chunk
+func buildChunks(ctx context.Context, embedder Embedder, model *domain.Model, doc *Document, content string, size, overlap int) ([]*Chunk, error) {
+	texts := splitText(content, size, overlap)
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	// prefix document name so that title-only queries still hit the chunks
+	inputs := make([]string, len(texts))
+	for i, text := range texts {
+		inputs[i] = doc.Name + "\n" + text
+	}
+	vectors, err := embedder.Embed(ctx, model, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("embed chunks failed: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding count mismatch: want %d, got %d", len(texts), len(vectors))
+	}
+	chunks := make([]*Chunk, len(texts))
+	for i, text := range texts {
+		if len(vectors[i]) == 0 {
+			return nil, fmt.Errorf("empty embedding for chunk %d", i)
+		}
+		chunks[i] = &Chunk{
+			ID:         uuid.New().String(),
+			DatasetID:  doc.DatasetID,
+			DocumentID: doc.ID,
+			Seq:        uint(i),
+			Content:    text,
+			Embedding:  vectors[i],
+			CreatedAt:  doc.CreatedAt,
+		}
+	}
+	return chunks, nil
+}
+
+func (s *PGVectorRAG) QueryRecords(ctx context.Context, datasetIDs []string, query string, topK int) ([]*domain.NodeContentChunk, error) {
+	if len(datasetIDs) == 0 || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if topK <= 0 {
+		topK = s.topK
+	}
+	model, err := s.getEmbeddingModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := s.embedder.Embed(ctx, model, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query failed: %w", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("embed query failed: empty embedding")
+	}
+	var chunks []*Chunk
+	// chunks embedded by another model have a different dimension, skip them,
+	// the same expression as the index of the dimension is ordered by
+	dimensions := len(vectors[0])
+	if err := s.db.WithContext(ctx).
+		Raw(fmt.Sprintf(`SELECT id, dataset_id, document_id, seq, content FROM rag_chunks
+			WHERE dataset_id IN ? AND vector_dims(embedding) = %[1]d
+			ORDER BY embedding::vector(%[1]d) <=> ?::vector(%[1]d)
+			LIMIT ?`, dimensions), datasetIDs, formatVector(vectors[0]), topK).
+		Scan(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("query chunks failed: %w", err)
+	}
+	nodeChunks := make([]*domain.NodeContentChunk, len(chunks))
+	for i, chunk := range chunks {
+		nodeChunks[i] = &domain.NodeContentChunk{
+			ID:      chunk.ID,
+			DocID:   chunk.DocumentID,
+			Seq:     chunk.Seq,
+			Content: chunk.Content,
+		}
+	}
+	return nodeChunks, nil
+}
+
+func (s *PGVectorRAG) DeleteRecords(ctx context.Context, datasetID string, docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_id = ?", datasetID).
+			Where("document_id IN ?", docIDs).
+			Delete(&Chunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("dataset_id = ?", datasetID).
+			Where("id IN ?", docIDs).
+			Delete(&Document{}).Error
+	})
+}
+
+func (s *PGVectorRAG) DeleteKnowledgeBase(ctx context.Context, datasetID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_id = ?", datasetID).Delete(&Chunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_id = ?", datasetID).Delete(&Document{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", datasetID).Delete(&Dataset{}).Error
+	})
+}
+
+// models are stored in the models table, embedding is called directly
+// with the active embedding model, so there is nothing to sync here.
+
+func (s *PGVectorRAG) GetModelList(ctx context.Context) ([]*domain.Model, error) {
+	var models []*domain.Model
+	if err := s.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("type IN ?", []domain.ModelType{domain.ModelTypeEmbedding, domain.ModelTypeRerank}).
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (s *PGVectorRAG) AddModel(ctx context.Context, model *domain.Model) (string, error) {
+	return model.ID, nil
+}
+
+func (s *PGVectorRAG) UpdateModel(ctx context.Context, model *domain.Model) error {
+	return nil
+}
+
+func (s *PGVectorRAG) DeleteModel(ctx context.Context, model *domain.Model) error {
+	return nil
+}
+
+func (s *PGVectorRAG) getEmbeddingModel(ctx context.Context) (*domain.Model, error) {
+	var model domain.Model
+	if err := s.db.WithContext(ctx).
+		Model(&domain.Model{}).
+		Where("type = ?", domain.ModelTypeEmbedding).
+		First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("embedding %w", domain.ErrModelNotConfigured)
+		}
+		return nil, err
+	}
+	return &model, nil
+}
@@ -0,0 +1,175 @@
+package pgvector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/store/pg"
+)
+
+// fakeEmbedder returns a tiny deterministic vector per text
+type fakeEmbedder struct {
+	calls int
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, model *domain.Model, texts []string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len([]rune(text))), float32(strings.Count(text, "\n")), 1}
+	}
+	return vectors, nil
+}
+
+func TestSplitText(t *testing.T) {
+	paragraphs := []string{
+		strings.Repeat("熊猫", 30),
+		strings.Repeat("b", 50),
+		strings.Repeat("c", 250),
+		"tail",
+	}
+	content := strings.Join(paragraphs, "\n\n")
+	chunks := splitText(content, 100, 10)
+	if len(chunks) < 4 {
+		t.Fatalf("expected content to be split, got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := len([]rune(chunk)); n > 100 {
+			t.Errorf("chunk %d has %d runes, exceeds chunk size", i, n)
+		}
+	}
+	if !strings.HasPrefix(chunks[0], "熊猫") {
+		t.Errorf("first chunk should start with the first paragraph, got %q", chunks[0])
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "tail") {
+		t.Errorf("last chunk should end with the last paragraph, got %q", chunks[len(chunks)-1])
+	}
+
+	if chunks := splitText("  \n\n ", 100, 10); len(chunks) != 0 {
+		t.Errorf("blank content should have no chunks, got %v", chunks)
+	}
+	if chunks := splitText("short", 100, 10); len(chunks) != 1 || chunks[0] != "short" {
+		t.Errorf("short content should be a single chunk, got %v", chunks)
+	}
+}
+
+func TestBuildChunks(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	doc := &Document{ID: "doc-1", DatasetID: "dataset-1", Name: "安装指南"}
+	content := strings.Repeat("x", 90) + "\n\n" + strings.Repeat("y", 90)
+	chunks, err := buildChunks(context.Background(), embedder, &domain.Model{Model: "fake"}, doc, content, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.DocumentID != "doc-1" || chunk.DatasetID != "dataset-1" {
+			t.Errorf("chunk %d has wrong owner: %+v", i, chunk)
+		}
+		if chunk.Seq != uint(i) {
+			t.Errorf("chunk %d has seq %d", i, chunk.Seq)
+		}
+		// document name is embedded together with the chunk content
+		if want := float32(len([]rune(doc.Name)) + 1 + 90); chunk.Embedding[0] != want {
+			t.Errorf("chunk %d embedding input length = %v, want %v", i, chunk.Embedding[0], want)
+		}
+	}
+	if embedder.calls != 1 {
+		t.Errorf("chunks should be embedded in one call, got %d", embedder.calls)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		if got := r.Header.Get("X-Custom"); got != "1" {
+			t.Errorf("unexpected custom header %q", got)
+		}
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		var resp embeddingResponse
+		// answer in reverse order to check the index is respected
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: []float32{float32(len(req.Input[i]))}})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	texts := make([]string, embeddingBatchSize+2)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1)
+	}
+	vectors, err := NewOpenAIEmbedder().Embed(context.Background(), &domain.Model{
+		Model:     "fake-embedding",
+		BaseURL:   server.URL + "/v1/",
+		APIKey:    "sk-test",
+		APIHeader: "X-Custom=1",
+	}, texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 batched requests, got %d", requests)
+	}
+	for i, vector := range vectors {
+		if vector[0] != float32(i+1) {
+			t.Errorf("vector %d = %v, want %d", i, vector, i+1)
+		}
+	}
+}
+
+func TestFormatVector(t *testing.T) {
+	if got := formatVector([]float32{0.5, -1, 2}); got != "[0.5,-1,2]" {
+		t.Errorf("formatVector = %s", got)
+	}
+}
+
+func TestEnsureEmbeddingIndex(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PGVectorRAG{db: &pg.DB{DB: db}}
+	// created once per dimension, too many dimensions are not indexed
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_1024 ON rag_chunks\s+USING hnsw \(\(embedding::vector\(1024\)\) vector_cosine_ops\)\s+WHERE vector_dims\(embedding\) = 1024`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_rag_chunks_embedding_768 `).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, dimensions := range []int{1024, 1024, 768, 3072} {
+		if err := s.ensureEmbeddingIndex(context.Background(), dimensions); err != nil {
+			t.Fatalf("ensureEmbeddingIndex(%d) error = %v", dimensions, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -9,13 +9,15 @@ import (
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/store/pg"
 	"github.com/chaitin/panda-wiki/store/rag/ct"
+	"github.com/chaitin/panda-wiki/store/rag/pgvector"
 )
 
 type RAGService interface {
 	CreateKnowledgeBase(ctx context.Context) (string, error)
 	UpsertRecords(ctx context.Context, datasetID string, nodeRelease *domain.NodeRelease) (string, error)
-	QueryRecords(ctx context.Context, datasetIDs []string, query string) ([]*domain.NodeContentChunk, error)
+	QueryRecords(ctx context.Context, datasetIDs []string, query string, topK int) ([]*domain.NodeContentChunk, error)
 	DeleteRecords(ctx context.Context, datasetID string, docIDs []string) error
 	DeleteKnowledgeBase(ctx context.Context, datasetID string) error
 
@@ -25,10 +27,12 @@ type RAGService interface {
 	DeleteModel(ctx context.Context, model *domain.Model) error
 }
 
-func NewRAGService(config *config.Config, logger *log.Logger) (RAGService, error) {
+func NewRAGService(config *config.Config, db *pg.DB, logger *log.Logger) (RAGService, error) {
 	switch config.RAG.Provider {
 	case "ct":
 		return ct.NewCTRAG(config, logger)
+	case "pgvector":
+		return pgvector.NewPGVectorRAG(config, db, logger)
 	default:
 		return nil, fmt.Errorf("unsupported vector provider: %s", config.RAG.Provider)
 	}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const apiTokenDisplayLen = 8
+
+var (
+	ErrAPITokenExpired   = errors.New("api token expired")
+	ErrAPITokenForbidden = errors.New("api tokens of other users are not accessible")
+)
+
+type APITokenUsecase struct {
+	repo     *pg.APITokenRepository
+	userRepo *pg.UserRepository
+	rbac     *RBACUsecase
+	logger   *log.Logger
+}
+
+func NewAPITokenUsecase(repo *pg.APITokenRepository, userRepo *pg.UserRepository, rbac *RBACUsecase, logger *log.Logger) *APITokenUsecase {
+	return &APITokenUsecase{
+		repo:     repo,
+		userRepo: userRepo,
+		rbac:     rbac,
+		logger:   logger.WithModule("usecase.api_token"),
+	}
+}
+
+// CreateAPIToken creates a token of the current user or a service account, the plaintext token is only returned here
+func (u *APITokenUsecase) CreateAPIToken(ctx context.Context, currentUserID string, req *domain.CreateAPITokenReq) (*domain.CreateAPITokenResp, error) {
+	userID, err := u.tokenOwner(ctx, currentUserID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires at must be in the future")
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate api token failed: %w", err)
+	}
+	plaintext := domain.APITokenPrefix + hex.EncodeToString(secret)
+	token := &domain.APIToken{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Name:        req.Name,
+		TokenHash:   hashAPIKey(plaintext),
+		TokenPrefix: plaintext[:len(domain.APITokenPrefix)+apiTokenDisplayLen],
+		Scopes:      req.Scopes,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedAt:   time.Now(),
+	}
+	if err := u.repo.CreateAPIToken(ctx, token); err != nil {
+		return nil, fmt.Errorf("create api token failed: %w", err)
+	}
+	return &domain.CreateAPITokenResp{APIToken: token, Token: plaintext}, nil
+}
+
+func (u *APITokenUsecase) GetAPITokenList(ctx context.Context, currentUserID string, req *domain.GetAPITokenListReq) ([]*domain.APIToken, error) {
+	userID, err := u.tokenOwner(ctx, currentUserID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return u.repo.GetAPITokensByUserID(ctx, userID)
+}
+
+func (u *APITokenUsecase) DeleteAPIToken(ctx context.Context, currentUserID string, req *domain.DeleteAPITokenReq) error {
+	token, err := u.repo.GetAPIToken(ctx, req.ID)
+	if err != nil {
+		return fmt.Errorf("get api token failed: %w", err)
+	}
+	if _, err := u.tokenOwner(ctx, currentUserID, token.UserID); err != nil {
+		return err
+	}
+	return u.repo.DeleteAPIToken(ctx, token.ID)
+}
+
+// CreateServiceAccount creates a user which can not login and only authenticates by api tokens
+func (u *APITokenUsecase) CreateServiceAccount(ctx context.Context, currentUserID string, req *domain.CreateServiceAccountReq) (*domain.User, error) {
+	if req.Role != "" {
+		currentUser, err := u.userRepo.GetUser(ctx, currentUserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user failed: %w", err)
+		}
+		if !currentUser.Role.Allows(req.Role) {
+			return nil, ErrRoleForbidden
+		}
+	}
+	// the password is never used, login of service accounts is rejected
+	password := make([]byte, 24)
+	if _, err := rand.Read(password); err != nil {
+		return nil, fmt.Errorf("generate password failed: %w", err)
+	}
+	user := &domain.User{
+		ID:       uuid.New().String(),
+		Account:  req.Account,
+		Password: hex.EncodeToString(password),
+		Type:     domain.UserTypeServiceAccount,
+		Role:     req.Role,
+	}
+	if err := u.userRepo.CreateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("create service account failed: %w", err)
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// Authenticate gets the unexpired token of the plaintext and records its use
+func (u *APITokenUsecase) Authenticate(ctx context.Context, plaintext, remoteIP string) (*domain.APIToken, error) {
+	if !strings.HasPrefix(plaintext, domain.APITokenPrefix) {
+		return nil, fmt.Errorf("invalid api token")
+	}
+	token, err := u.repo.GetAPITokenByHash(ctx, hashAPIKey(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("get api token failed: %w", err)
+	}
+	if token.Expired(time.Now()) {
+		return nil, ErrAPITokenExpired
+	}
+	user, err := u.userRepo.GetUser(ctx, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user failed: %w", err)
+	}
+	if user.ID == "" {
+		return nil, fmt.Errorf("user %s of api token not found", token.UserID)
+	}
+	if err := u.repo.UpdateLastUsed(ctx, token.ID, remoteIP); err != nil {
+		u.logger.Error("update api token last used failed", log.Error(err))
+	}
+	return token, nil
+}
+
+// tokenOwner the owner of the tokens to manage, the current user or a service account manageable by the current user
+func (u *APITokenUsecase) tokenOwner(ctx context.Context, currentUserID, userID string) (string, error) {
+	if userID == "" || userID == currentUserID {
+		return currentUserID, nil
+	}
+	user, err := u.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("get user failed: %w", err)
+	}
+	if user.Type != domain.UserTypeServiceAccount {
+		return "", ErrAPITokenForbidden
+	}
+	// the tokens act with the role of the service account
+	if err := u.rbac.CheckUserManageable(ctx, currentUserID, user.ID); err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
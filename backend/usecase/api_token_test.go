@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	pgstore "github.com/chaitin/panda-wiki/store/pg"
+)
+
+func newTestLogger() *log.Logger {
+	return &log.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+// newMockDB a db of the repos whose queries are expected by the mock
+func newMockDB(t *testing.T) (*pgstore.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pgstore.DB{DB: db}, mock
+}
+
+func TestAPITokenOwner(t *testing.T) {
+	users := map[string]*domain.UserInfoResp{
+		"viewer":        {ID: "viewer", Type: domain.UserTypeUser, Role: domain.RoleViewer},
+		"admin":         {ID: "admin", Type: domain.UserTypeUser, Role: domain.RoleAdmin},
+		"owner":         {ID: "owner", Type: domain.UserTypeUser, Role: domain.RoleOwner},
+		"owner_service": {ID: "owner_service", Type: domain.UserTypeServiceAccount, Role: domain.RoleOwner},
+		"admin_service": {ID: "admin_service", Type: domain.UserTypeServiceAccount, Role: domain.RoleAdmin},
+	}
+	for _, tc := range []struct {
+		current string
+		owner   string
+		// queried the users in order
+		queries  []string
+		expected error
+	}{
+		{current: "viewer", owner: "", expected: nil},
+		{current: "viewer", owner: "viewer", expected: nil},
+		{current: "viewer", owner: "owner_service", queries: []string{"owner_service", "viewer", "owner_service"}, expected: ErrRoleForbidden},
+		{current: "admin", owner: "owner_service", queries: []string{"owner_service", "admin", "owner_service"}, expected: ErrRoleForbidden},
+		{current: "admin", owner: "admin_service", queries: []string{"admin_service", "admin", "admin_service"}, expected: nil},
+		{current: "owner", owner: "owner_service", queries: []string{"owner_service", "owner", "owner_service"}, expected: nil},
+		{current: "owner", owner: "admin", queries: []string{"admin"}, expected: ErrAPITokenForbidden},
+	} {
+		db, mock := newMockDB(t)
+		for _, id := range tc.queries {
+			user := users[id]
+			mock.ExpectQuery(`SELECT \* FROM "users"`).
+				WithArgs(id).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "type", "role"}).AddRow(user.ID, user.Type, user.Role))
+		}
+		userRepo := pg.NewUserRepository(db, newTestLogger())
+		u := NewAPITokenUsecase(nil, userRepo, NewRBACUsecase(userRepo, nil, nil, newTestLogger()), newTestLogger())
+
+		expectedOwner := tc.owner
+		if expectedOwner == "" {
+			expectedOwner = tc.current
+		}
+		owner, err := u.tokenOwner(context.Background(), tc.current, tc.owner)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("tokenOwner(%s, %s) error = %v, expected %v", tc.current, tc.owner, err, tc.expected)
+		} else if err == nil && owner != expectedOwner {
+			t.Errorf("tokenOwner(%s, %s) = %s, expected %s", tc.current, tc.owner, owner, expectedOwner)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("tokenOwner(%s, %s): %v", tc.current, tc.owner, err)
+		}
+	}
+}
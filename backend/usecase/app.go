@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/chaitin/panda-wiki/config"
@@ -14,8 +15,10 @@ import (
 
 type AppUsecase struct {
 	repo          *pg.AppRepository
+	modelRepo     *pg.ModelRepository
 	nodeUsecase   *NodeUsecase
 	chatUsecase   *ChatUsecase
+	audit         *AuditUsecase
 	logger        *log.Logger
 	config        *config.Config
 	dingTalkBots  map[string]*dingtalk.DingTalkClient
@@ -26,15 +29,19 @@ type AppUsecase struct {
 
 func NewAppUsecase(
 	repo *pg.AppRepository,
+	modelRepo *pg.ModelRepository,
 	nodeUsecase *NodeUsecase,
 	logger *log.Logger,
 	config *config.Config,
 	chatUsecase *ChatUsecase,
+	audit *AuditUsecase,
 ) *AppUsecase {
 	u := &AppUsecase{
 		repo:         repo,
+		modelRepo:    modelRepo,
 		nodeUsecase:  nodeUsecase,
 		chatUsecase:  chatUsecase,
+		audit:        audit,
 		logger:       logger.WithModule("usecase.app"),
 		config:       config,
 		dingTalkBots: make(map[string]*dingtalk.DingTalkClient),
@@ -61,16 +68,43 @@ func NewAppUsecase(
 }
 
 func (u *AppUsecase) UpdateApp(ctx context.Context, id string, appRequest *domain.UpdateAppReq) error {
+	if appRequest.Settings != nil {
+		if appRequest.Settings.PromptSettings != nil {
+			if err := appRequest.Settings.PromptSettings.Validate(); err != nil {
+				return fmt.Errorf("invalid prompt settings: %w", err)
+			}
+		}
+		if err := checkChatModelIDs(ctx, u.modelRepo, appRequest.Settings.ChatModelIDs); err != nil {
+			return err
+		}
+		if appRequest.Settings.QuotaSettings != nil {
+			if err := appRequest.Settings.QuotaSettings.Validate(); err != nil {
+				return fmt.Errorf("invalid quota settings: %w", err)
+			}
+		}
+	}
+	before, err := u.repo.GetAppDetail(ctx, id)
+	if err != nil {
+		return err
+	}
 	if err := u.repo.UpdateApp(ctx, id, appRequest); err != nil {
 		return err
 	}
+	app, err := u.repo.GetAppDetail(ctx, id)
+	if err != nil {
+		return err
+	}
+	// not a change of the settings
+	app.UpdatedAt = before.UpdatedAt
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionAppUpdate,
+		TargetType: domain.AuditTargetApp,
+		TargetID:   id,
+		KBID:       app.KBID,
+	}, before, app)
 
 	// If this is a DingTalkBot app, check if we need to update the bot instance
 	if appRequest.Settings != nil {
-		app, err := u.repo.GetAppDetail(ctx, id)
-		if err != nil {
-			return err
-		}
 		switch app.Type {
 		case domain.AppTypeDingTalkBot:
 			u.updateDingTalkBot(app)
@@ -234,6 +268,10 @@ func (u *AppUsecase) GetAppDetailByKBIDAndAppType(ctx context.Context, kbID stri
 		CatalogSettings: app.Settings.CatalogSettings,
 		// footer settings
 		FooterSettings: app.Settings.FooterSettings,
+		// prompt settings
+		PromptSettings: app.Settings.PromptSettings,
+		ChatModelIDs:   app.Settings.ChatModelIDs,
+		QuotaSettings:  app.Settings.QuotaSettings,
 	}
 	if len(app.Settings.RecommendNodeIDs) > 0 {
 		nodes, err := u.nodeUsecase.GetRecommendNodeList(ctx, &domain.GetRecommendNodeListReq{
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const appAPIKeyDisplayLen = 8
+
+type AppAPIKeyUsecase struct {
+	repo    *pg.AppAPIKeyRepository
+	appRepo *pg.AppRepository
+	logger  *log.Logger
+}
+
+func NewAppAPIKeyUsecase(repo *pg.AppAPIKeyRepository, appRepo *pg.AppRepository, logger *log.Logger) *AppAPIKeyUsecase {
+	return &AppAPIKeyUsecase{
+		repo:    repo,
+		appRepo: appRepo,
+		logger:  logger.WithModule("usecase.app_api_key"),
+	}
+}
+
+// CreateAPIKey creates a key of the openai api or mcp server app of the kb, the plaintext key is only returned here
+func (u *AppAPIKeyUsecase) CreateAPIKey(ctx context.Context, req *domain.CreateAppAPIKeyReq) (*domain.CreateAppAPIKeyResp, error) {
+	appType := req.AppType
+	if appType == 0 {
+		appType = domain.AppTypeOpenAIAPI
+	}
+	app, err := u.appRepo.GetOrCreateApplByKBIDAndType(ctx, req.KBID, appType)
+	if err != nil {
+		return nil, fmt.Errorf("get app failed: %w", err)
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate api key failed: %w", err)
+	}
+	key := domain.AppAPIKeyPrefix + hex.EncodeToString(secret)
+	apiKey := &domain.AppAPIKey{
+		ID:        uuid.New().String(),
+		KBID:      req.KBID,
+		AppID:     app.ID,
+		Name:      req.Name,
+		KeyHash:   hashAPIKey(key),
+		KeyPrefix: key[:len(domain.AppAPIKeyPrefix)+appAPIKeyDisplayLen],
+		CreatedAt: time.Now(),
+	}
+	if err := u.repo.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, fmt.Errorf("create api key failed: %w", err)
+	}
+	return &domain.CreateAppAPIKeyResp{AppAPIKey: apiKey, Key: key}, nil
+}
+
+func (u *AppAPIKeyUsecase) GetAPIKeyList(ctx context.Context, kbID string) ([]*domain.AppAPIKey, error) {
+	return u.repo.GetAPIKeysByKBID(ctx, kbID)
+}
+
+func (u *AppAPIKeyUsecase) DeleteAPIKey(ctx context.Context, req *domain.DeleteAppAPIKeyReq) error {
+	return u.repo.DeleteAPIKey(ctx, req.KBID, req.ID)
+}
+
+// Authenticate gets the app of the key, the app must be of the type
+func (u *AppAPIKeyUsecase) Authenticate(ctx context.Context, key string, appType domain.AppType) (*domain.App, error) {
+	if !strings.HasPrefix(key, domain.AppAPIKeyPrefix) {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	apiKey, err := u.repo.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("get api key failed: %w", err)
+	}
+	app, err := u.appRepo.GetAppDetail(ctx, apiKey.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("get app failed: %w", err)
+	}
+	if app.Type != appType {
+		return nil, fmt.Errorf("app %s is not of type %d", app.ID, appType)
+	}
+	if err := u.repo.UpdateLastUsedAt(ctx, apiKey.ID); err != nil {
+		u.logger.Error("update api key last used at failed", log.Error(err))
+	}
+	return app, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
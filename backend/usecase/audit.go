@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+const (
+	auditRedacted        = "[REDACTED]"
+	auditExportBatchSize = 500
+)
+
+// auditSecretKeys json keys whose values are never written to the audit log besides any key of a secret or password,
+// matched by the last path segment or its suffix
+var auditSecretKeys = []string{"private_key", "api_key", "access_key", "token"}
+
+type AuditUsecase struct {
+	repo     *pg.AuditLogRepository
+	userRepo *pg.UserRepository
+	logger   *log.Logger
+}
+
+func NewAuditUsecase(repo *pg.AuditLogRepository, userRepo *pg.UserRepository, logger *log.Logger) *AuditUsecase {
+	return &AuditUsecase{
+		repo:     repo,
+		userRepo: userRepo,
+		logger:   logger.WithModule("usecase.audit"),
+	}
+}
+
+// Record writes the audit log of a mutation already done, the actor is taken from the context,
+// before is nil for creations and after is nil for deletions, nothing is written if nothing changed.
+// Failures are logged instead of failing the mutation.
+func (u *AuditUsecase) Record(ctx context.Context, auditLog *domain.AuditLog, before, after any) {
+	auditLog.Diff = auditDiff(before, after)
+	if len(auditLog.Diff) == 0 {
+		return
+	}
+	if actor := domain.AuditActorFromContext(ctx); actor != nil {
+		auditLog.ActorID = actor.UserID
+		auditLog.APITokenID = actor.APITokenID
+		auditLog.IP = actor.IP
+		auditLog.UserAgent = actor.UserAgent
+		if user, err := u.userRepo.GetUser(ctx, actor.UserID); err == nil {
+			auditLog.ActorAccount = user.Account
+		}
+	}
+	auditLog.CreatedAt = time.Now()
+	// the mutation is done even if the request is canceled now
+	if err := u.repo.CreateAuditLog(context.WithoutCancel(ctx), auditLog); err != nil {
+		u.logger.Error("write audit log failed", log.String("action", string(auditLog.Action)), log.String("target_id", auditLog.TargetID), log.Error(err))
+	}
+}
+
+func (u *AuditUsecase) GetAuditLogList(ctx context.Context, req *domain.GetAuditLogListReq) (*domain.PaginatedResult[[]*domain.AuditLog], error) {
+	auditLogs, total, err := u.repo.GetAuditLogList(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(auditLogs, total), nil
+}
+
+// ExportAuditLogs writes the matched audit logs as csv from the newest
+func (u *AuditUsecase) ExportAuditLogs(ctx context.Context, filter *domain.AuditLogFilter, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "created_at", "actor_id", "actor_account", "api_token_id", "ip", "user_agent", "action", "target_type", "target_id", "kb_id", "diff"}); err != nil {
+		return err
+	}
+	err := u.repo.TraverseAuditLogs(ctx, filter, auditExportBatchSize, func(auditLogs []*domain.AuditLog) error {
+		for _, auditLog := range auditLogs {
+			diff, err := json.Marshal(auditLog.Diff)
+			if err != nil {
+				return err
+			}
+			if err := writer.Write([]string{
+				strconv.FormatInt(auditLog.ID, 10),
+				auditLog.CreatedAt.Format(time.RFC3339),
+				csvCell(auditLog.ActorID),
+				csvCell(auditLog.ActorAccount),
+				csvCell(auditLog.APITokenID),
+				csvCell(auditLog.IP),
+				csvCell(auditLog.UserAgent),
+				csvCell(string(auditLog.Action)),
+				csvCell(string(auditLog.TargetType)),
+				csvCell(auditLog.TargetID),
+				csvCell(auditLog.KBID),
+				csvCell(string(diff)),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell prevents spreadsheet formula injection by values of users
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// auditDiff the changed fields of the json of before and after by dotted paths, secrets are redacted
+func auditDiff(before, after any) map[string]domain.AuditChange {
+	beforeFields := flattenAuditValue(before)
+	afterFields := flattenAuditValue(after)
+	diff := make(map[string]domain.AuditChange)
+	for path, value := range beforeFields {
+		if afterValue, ok := afterFields[path]; !ok || !reflect.DeepEqual(value, afterValue) {
+			diff[path] = domain.AuditChange{Before: value, After: afterValue}
+		}
+	}
+	for path, value := range afterFields {
+		if _, ok := beforeFields[path]; !ok {
+			diff[path] = domain.AuditChange{After: value}
+		}
+	}
+	for path, change := range diff {
+		if isAuditSecret(path) {
+			diff[path] = domain.AuditChange{Before: redactAuditValue(change.Before), After: redactAuditValue(change.After)}
+		}
+	}
+	return diff
+}
+
+func flattenAuditValue(value any) map[string]any {
+	fields := make(map[string]any)
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Pointer && reflect.ValueOf(value).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fields
+	}
+	if _, ok := decoded.(map[string]any); !ok {
+		fields["value"] = decoded
+		return fields
+	}
+	flattenAuditFields("", decoded, fields)
+	return fields
+}
+
+func flattenAuditFields(prefix string, value any, fields map[string]any) {
+	if object, ok := value.(map[string]any); ok && len(object) > 0 {
+		for key, item := range object {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenAuditFields(path, item, fields)
+		}
+		return
+	}
+	fields[prefix] = value
+}
+
+func isAuditSecret(path string) bool {
+	key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	if strings.Contains(key, "secret") || strings.Contains(key, "password") {
+		return true
+	}
+	for _, secret := range auditSecretKeys {
+		if key == secret || strings.HasSuffix(key, "_"+secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAuditValue keeps whether the secret is set, not the value
+func redactAuditValue(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return auditRedacted
+}
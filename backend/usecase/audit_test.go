@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := &domain.KnowledgeBase{
+		ID:   "kb",
+		Name: "wiki",
+		AccessSettings: domain.AccessSettings{
+			PrivateKey: "old key",
+			SimpleAuth: domain.SimpleAuth{Enabled: false},
+		},
+	}
+	after := &domain.KnowledgeBase{
+		ID:   "kb",
+		Name: "wiki",
+		AccessSettings: domain.AccessSettings{
+			PrivateKey: "new key",
+			SimpleAuth: domain.SimpleAuth{Enabled: true, PasswordHash: "hash"},
+		},
+	}
+	expected := map[string]domain.AuditChange{
+		"access_settings.private_key":               {Before: auditRedacted, After: auditRedacted},
+		"access_settings.simple_auth.enabled":       {Before: false, After: true},
+		"access_settings.simple_auth.password_hash": {After: auditRedacted},
+	}
+	if diff := auditDiff(before, after); !reflect.DeepEqual(diff, expected) {
+		t.Errorf("auditDiff() = %v, expected %v", diff, expected)
+	}
+	if diff := auditDiff(before, before); len(diff) != 0 {
+		t.Errorf("auditDiff() of the same value = %v, expected empty", diff)
+	}
+
+	// creations and deletions
+	user := map[string]any{"account": "alice", "api_token": "pw-abc"}
+	if diff := auditDiff(nil, user); !reflect.DeepEqual(diff, map[string]domain.AuditChange{
+		"account":   {After: "alice"},
+		"api_token": {After: auditRedacted},
+	}) {
+		t.Errorf("auditDiff() of a creation = %v", diff)
+	}
+	if diff := auditDiff(user, nil); !reflect.DeepEqual(diff, map[string]domain.AuditChange{
+		"account":   {Before: "alice"},
+		"api_token": {Before: auditRedacted},
+	}) {
+		t.Errorf("auditDiff() of a deletion = %v", diff)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	for value, expected := range map[string]string{
+		"node.delete":         "node.delete",
+		"=HYPERLINK(\"x\")":   "'=HYPERLINK(\"x\")",
+		"+1":                  "'+1",
+		"@SUM(A1)":            "'@SUM(A1)",
+		"":                    "",
+		"Mozilla/5.0 (X11) a": "Mozilla/5.0 (X11) a",
+	} {
+		if got := csvCell(value); got != expected {
+			t.Errorf("csvCell(%q) = %q, expected %q", value, got, expected)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -10,53 +13,130 @@ import (
 
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
 	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+// chatFirstChunkTimeout the next chat model is tried if no chunk is received in time
+const chatFirstChunkTimeout = 60 * time.Second
+
+// chatStreamTTL the longest time a running stream can be stopped by other instances
+const chatStreamTTL = 30 * time.Minute
+
+var (
+	errChatFirstChunkTimeout = errors.New("wait for first chunk timeout")
+	errChatStopped           = errors.New("chat stopped by the reader")
+
+	ErrChatStreamNotFound = errors.New("chat stream not found")
 )
 
 type ChatUsecase struct {
 	llmUsecase          *LLMUsecase
 	conversationUsecase *ConversationUsecase
 	modelUsecase        *ModelUsecase
+	quotaUsecase        *QuotaUsecase
+	knowledgeGapUsecase *KnowledgeGapUsecase
 	appRepo             *pg.AppRepository
+	kbRepo              *pg.KnowledgeBaseRepository
+	streamRepo          *cache.ChatStreamRepo
 	logger              *log.Logger
+
+	// running streams of this instance by conversation id
+	streams sync.Map
 }
 
-func NewChatUsecase(llmUsecase *LLMUsecase, conversationUsecase *ConversationUsecase, modelUsecase *ModelUsecase, appRepo *pg.AppRepository, logger *log.Logger) *ChatUsecase {
+type chatStream struct {
+	cancel context.CancelCauseFunc
+}
+
+func NewChatUsecase(llmUsecase *LLMUsecase, conversationUsecase *ConversationUsecase, modelUsecase *ModelUsecase, quotaUsecase *QuotaUsecase, knowledgeGapUsecase *KnowledgeGapUsecase, appRepo *pg.AppRepository, kbRepo *pg.KnowledgeBaseRepository, streamRepo *cache.ChatStreamRepo, logger *log.Logger) (*ChatUsecase, error) {
 	u := &ChatUsecase{
 		llmUsecase:          llmUsecase,
 		conversationUsecase: conversationUsecase,
 		modelUsecase:        modelUsecase,
+		quotaUsecase:        quotaUsecase,
+		knowledgeGapUsecase: knowledgeGapUsecase,
 		appRepo:             appRepo,
+		kbRepo:              kbRepo,
+		streamRepo:          streamRepo,
 		logger:              logger.WithModule("usecase.chat"),
 	}
-	return u
+	// the stops of the streams running on this instance may be received by other instances
+	stops, err := streamRepo.SubscribeStop(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("subscribe chat stops failed: %w", err)
+	}
+	go u.cancelStoppedStreams(stops)
+	return u, nil
+}
+
+func (u *ChatUsecase) cancelStoppedStreams(stops <-chan string) {
+	for conversationID := range stops {
+		if stream, ok := u.streams.Load(conversationID); ok {
+			stream.(*chatStream).cancel(errChatStopped)
+		}
+	}
 }
 
 func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan domain.SSEEvent, error) {
 	eventCh := make(chan domain.SSEEvent, 100)
 	go func() {
 		defer close(eventCh)
+		// drop the events once the client is gone
+		done := ctx.Done()
+		send := func(event domain.SSEEvent) {
+			select {
+			case eventCh <- event:
+			case <-done:
+			}
+		}
 		// 1. get app detail and validate app
 		app, err := u.appRepo.GetOrCreateApplByKBIDAndType(ctx, req.KBID, req.AppType)
 		if err != nil {
-			eventCh <- domain.SSEEvent{Type: "error", Content: "app not found"}
+			send(domain.SSEEvent{Type: "error", Content: "app not found"})
 			return
 		}
 		req.KBID = app.KBID
 		req.AppID = app.ID
 		req.AppType = app.Type
-		// 2. get model and validate model
-		model, err := u.modelUsecase.GetChatModel(ctx)
+		// 2. get models bound to the app or the kb and validate models
+		kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KBID)
+		if err != nil {
+			send(domain.SSEEvent{Type: "error", Content: "knowledge base not found"})
+			return
+		}
+		// check quotas before calling the model, allow the request if the quota store fails
+		exceeded, err := u.quotaUsecase.CheckChatQuota(ctx, kb, app, req.RemoteIP)
+		if err != nil {
+			u.logger.Error("failed to check chat quota", log.Error(err))
+		}
+		if exceeded != nil {
+			u.logger.Info("chat quota exceeded", log.String("app_id", app.ID), log.String("remote_ip", req.RemoteIP), log.Any("quota", exceeded))
+			send(domain.SSEEvent{Type: "error", Content: exceeded.Message(), Code: domain.SSEErrorCodeQuotaExceeded, Quota: exceeded})
+			return
+		}
+		modelIDs := app.Settings.ChatModelIDs
+		if len(modelIDs) == 0 {
+			modelIDs = kb.ChatModelIDs
+		}
+		models, err := u.modelUsecase.GetChatModels(ctx, modelIDs)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				eventCh <- domain.SSEEvent{Type: "error", Content: "请前往管理后台，点击右上角的“系统设置”配置推理大模型。"}
+				send(domain.SSEEvent{Type: "error", Content: "请前往管理后台，点击右上角的“系统设置”配置推理大模型。"})
+			} else if errors.Is(err, domain.ErrBoundChatModelsDeleted) {
+				send(domain.SSEEvent{Type: "error", Content: "绑定的推理大模型已被删除，请前往管理后台重新配置。"})
 			} else {
-				eventCh <- domain.SSEEvent{Type: "error", Content: "模型获取失败"}
+				send(domain.SSEEvent{Type: "error", Content: "模型获取失败"})
 			}
 			return
 		}
-		req.ModelInfo = model
+		req.ModelInfo = models[0]
 		// 3. conversation management
+		if req.Action != "" && req.ConversationID == "" {
+			send(domain.SSEEvent{Type: "error", Content: "conversation_id is required"})
+			return
+		}
 		if req.ConversationID == "" {
 			id, err := uuid.NewV7()
 			if err != nil {
@@ -66,8 +146,8 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			conversationID := id.String()
 			req.ConversationID = conversationID
 			nonce := uuid.New().String()
-			eventCh <- domain.SSEEvent{Type: "conversation_id", Content: conversationID}
-			eventCh <- domain.SSEEvent{Type: "nonce", Content: nonce}
+			send(domain.SSEEvent{Type: "conversation_id", Content: conversationID})
+			send(domain.SSEEvent{Type: "nonce", Content: nonce})
 			err = u.conversationUsecase.CreateConversation(ctx, &domain.Conversation{
 				ID:        conversationID,
 				Nonce:     nonce,
@@ -79,39 +159,56 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 			})
 			if err != nil {
 				u.logger.Error("failed to create chat conversation", log.Error(err))
-				eventCh <- domain.SSEEvent{Type: "error", Content: "failed to create chat conversation"}
+				send(domain.SSEEvent{Type: "error", Content: "failed to create chat conversation"})
 				return
 			}
 		} else {
 			if req.Nonce == "" {
-				eventCh <- domain.SSEEvent{Type: "error", Content: "nonce is required"}
+				send(domain.SSEEvent{Type: "error", Content: "nonce is required"})
 				return
 			}
 			err := u.conversationUsecase.ValidateConversationNonce(ctx, req.ConversationID, req.Nonce)
 			if err != nil {
 				u.logger.Error("failed to validate chat conversation nonce", log.Error(err))
-				eventCh <- domain.SSEEvent{Type: "error", Content: "validate chat conversation nonce failed"}
+				send(domain.SSEEvent{Type: "error", Content: "validate chat conversation nonce failed"})
 				return
 			}
 		}
-		// save user question to conversation message
-		if err := u.conversationUsecase.CreateChatConversationMessage(ctx, req.KBID, &domain.ConversationMessage{
-			ID:             uuid.New().String(),
-			ConversationID: req.ConversationID,
-			AppID:          req.AppID,
-			Role:           schema.User,
-			Content:        req.Message,
-			RemoteIP:       req.RemoteIP,
-		}); err != nil {
+		// save user question to conversation message, regenerate answers the existing one
+		question, err := u.conversationUsecase.SaveChatQuestion(ctx, req)
+		if errors.Is(err, ErrChatParentRequired) {
+			send(domain.SSEEvent{Type: "error", Content: "parent_id is required"})
+			return
+		}
+		if err != nil {
 			u.logger.Error("failed to save user question to conversation message", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save user question to conversation message"}
+			send(domain.SSEEvent{Type: "error", Content: "failed to save user question to conversation message"})
 			return
 		}
+		req.Message = question.Content
+		// user message id for regenerate and edit
+		send(domain.SSEEvent{Type: "user_message_id", Content: question.ID})
 		// 4. retrieve documents and format prompt
-		messages, rankedNodes, err := u.llmUsecase.FormatConversationMessages(ctx, req.ConversationID, req.KBID)
+		rewriteUsage := schema.TokenUsage{}
+		messages, rankedNodes, err := u.llmUsecase.FormatConversationMessages(ctx, req.ConversationID, question.ID, req.History, req.KBID, app.Settings.PromptSettings, models, &rewriteUsage)
 		if err != nil {
 			u.logger.Error("failed to format chat messages", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to format chat messages"}
+			send(domain.SSEEvent{Type: "error", Content: "failed to format chat messages"})
+			return
+		}
+		// reserve the tokens of the prompt, the answer and the rewrite before calling the model,
+		// allow the request if the quota store fails
+		reservedTokens := estimateUsage(messages, "").PromptTokens + quotaReservedCompletionTokens + rewriteUsage.TotalTokens
+		reservation, exceeded, err := u.quotaUsecase.ReserveTokens(ctx, kb, app, reservedTokens)
+		if err != nil {
+			u.logger.Error("failed to reserve quota tokens", log.Error(err))
+		}
+		if exceeded != nil {
+			if err := u.quotaUsecase.AddTokenUsage(ctx, req.KBID, req.AppID, rewriteUsage.TotalTokens); err != nil {
+				u.logger.Error("failed to add quota token usage", log.Error(err))
+			}
+			u.logger.Info("chat quota exceeded", log.String("app_id", app.ID), log.Int("reserved_tokens", reservedTokens), log.Any("quota", exceeded))
+			send(domain.SSEEvent{Type: "error", Content: exceeded.Message(), Code: domain.SSEErrorCodeQuotaExceeded, Quota: exceeded})
 			return
 		}
 		for _, node := range rankedNodes {
@@ -120,53 +217,231 @@ func (u *ChatUsecase) Chat(ctx context.Context, req *domain.ChatRequest) (<-chan
 				Name:    node.NodeName,
 				Summary: node.NodeSummary,
 			}
-			eventCh <- domain.SSEEvent{Type: "chunk_result", ChunkResult: &chunkResult}
+			send(domain.SSEEvent{Type: "chunk_result", ChunkResult: &chunkResult})
+		}
+		// 5. LLM inference (streaming callback) with fallback models, message storage, token statistics
+		// the stream is cancelled on client disconnect or stop, the partial answer is still saved
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		stream := &chatStream{cancel: cancel}
+		u.streams.Store(req.ConversationID, stream)
+		defer u.streams.CompareAndDelete(req.ConversationID, stream)
+		if err := u.streamRepo.AddStream(ctx, req.ConversationID, chatStreamTTL); err != nil {
+			u.logger.Error("failed to add chat stream", log.Error(err))
 		}
-		// 5. LLM inference (streaming callback), message storage, token statistics
+		defer func() {
+			if err := u.streamRepo.RemoveStream(context.WithoutCancel(ctx), req.ConversationID); err != nil {
+				u.logger.Error("failed to remove chat stream", log.Error(err))
+			}
+		}()
 		answer := ""
 		usage := schema.TokenUsage{}
-		chatModel, err := u.llmUsecase.GetChatModel(ctx, req.ModelInfo)
-		if err != nil {
-			u.logger.Error("failed to get chat model", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to get chat model"}
-			return
-		}
-		chatErr := u.llmUsecase.ChatWithAgent(ctx, chatModel, messages, &usage, func(ctx context.Context, dataType, chunk string) error {
+		citationTracker := newCitationTracker(rankedNodes, kb.AccessSettings.BaseURL)
+		model, chatErr := u.chatWithFallback(streamCtx, models, &usage, func(ctx context.Context, model *domain.Model, usage *schema.TokenUsage, onChunk chatChunkFunc) error {
+			return u.chatWithModel(ctx, model, messages, usage, onChunk)
+		}, func(ctx context.Context, dataType, chunk string) error {
 			answer += chunk
-			eventCh <- domain.SSEEvent{Type: dataType, Content: chunk}
+			send(domain.SSEEvent{Type: dataType, Content: chunk})
+			for _, citation := range citationTracker.Feed(answer) {
+				send(domain.SSEEvent{Type: "citation", Citation: citation})
+			}
 			return nil
 		})
+		req.ModelInfo = model
+		status := chatMessageStatus(streamCtx, chatErr)
+		if status == domain.MessageStatusStopped {
+			u.logger.Info("chat stream stopped", log.String("conversation_id", req.ConversationID), log.Any("cause", context.Cause(streamCtx)))
+			// usage is only sent at the end of the stream
+			if usage.TotalTokens == 0 {
+				usage = estimateUsage(messages, answer)
+			}
+		}
+		ctx = context.WithoutCancel(ctx)
+		// replace the reserved tokens with the consumed ones
+		consumedTokens := usage.TotalTokens + rewriteUsage.TotalTokens
+		if reservation != nil {
+			err = u.quotaUsecase.SettleTokens(ctx, reservation, consumedTokens)
+		} else {
+			err = u.quotaUsecase.AddTokenUsage(ctx, req.KBID, req.AppID, consumedTokens)
+		}
+		if err != nil {
+			u.logger.Error("failed to settle quota tokens", log.Error(err))
+		}
+		// citations only listed in the reference list
+		for _, citation := range citationTracker.Finish(answer) {
+			send(domain.SSEEvent{Type: "citation", Citation: citation})
+		}
+		// strip the citations not among the retrieved documents, the client replaces the streamed answer
+		verifiedAnswer, citationCount, unsupportedCitationCount := citationTracker.Verify(answer)
+		if unsupportedCitationCount > 0 {
+			u.logger.Warn("unsupported citations stripped",
+				log.String("model", req.ModelInfo.Model),
+				log.Int("citations", citationCount),
+				log.Int("unsupported", unsupportedCitationCount))
+			answer = verifiedAnswer
+			send(domain.SSEEvent{Type: "verified_answer", Content: answer})
+		}
 		// save assistant answer to conversation message
+		messageID := uuid.New().String()
 		if err := u.conversationUsecase.CreateChatConversationMessage(ctx, req.KBID, &domain.ConversationMessage{
-			ID:               uuid.New().String(),
+			ID:               messageID,
 			ConversationID:   req.ConversationID,
 			AppID:            req.AppID,
+			ParentID:         question.ID,
 			Role:             schema.Assistant,
 			Content:          answer,
+			Status:           status,
 			Provider:         req.ModelInfo.Provider,
 			Model:            string(req.ModelInfo.Model),
 			PromptTokens:     usage.PromptTokens,
 			CompletionTokens: usage.CompletionTokens,
 			TotalTokens:      usage.TotalTokens,
+			RetrievedChunks:  domain.NewRetrievedChunks(rankedNodes),
 			RemoteIP:         req.RemoteIP,
-		}); err != nil {
+		}, citationTracker.Citations()); err != nil {
 			u.logger.Error("failed to save assistant answer to conversation message", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"}
+			send(domain.SSEEvent{Type: "error", Content: "failed to save assistant answer to conversation message"})
 			return
 		}
 		// update model usage
 		if err := u.modelUsecase.UpdateUsage(ctx, req.ModelInfo.ID, &usage); err != nil {
 			u.logger.Error("failed to update model usage", log.Error(err))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "failed to update model usage"}
+			send(domain.SSEEvent{Type: "error", Content: "failed to update model usage"})
 			return
 		}
+		if err := u.modelUsecase.UpdateCitationStats(ctx, req.ModelInfo.ID, citationCount, unsupportedCitationCount); err != nil {
+			u.logger.Error("failed to update model citation stats", log.Error(err))
+		}
 
-		if chatErr != nil {
+		if status == domain.MessageStatusFailed {
 			u.logger.Error("对话失败", log.Error(chatErr))
-			eventCh <- domain.SSEEvent{Type: "error", Content: "对话失败，请稍后再试"}
+			send(domain.SSEEvent{Type: "error", Content: "对话失败，请稍后再试"})
 			return
 		}
-		eventCh <- domain.SSEEvent{Type: "done"}
+		if status == domain.MessageStatusStopped {
+			send(domain.SSEEvent{Type: "message_id", Content: messageID})
+			send(domain.SSEEvent{Type: "stopped"})
+			send(domain.SSEEvent{Type: "done"})
+			return
+		}
+		// flag unanswered question for the knowledge gap report
+		refusalText := kb.PromptSettings.Merge(app.Settings.PromptSettings).GetRefusalText()
+		if err := u.knowledgeGapUsecase.RecordUnanswered(ctx, req, messageID, answer, rankedNodes, refusalText); err != nil {
+			u.logger.Error("failed to record unanswered question", log.Error(err))
+		}
+		// message id for reader feedback
+		send(domain.SSEEvent{Type: "message_id", Content: messageID})
+		send(domain.SSEEvent{Type: "done"})
 	}()
 	return eventCh, nil
 }
+
+// StopChat cancels the running answer stream of the conversation, the partial answer is saved as stopped,
+// the stop is published to the instance running the stream if it is not running on this one
+func (u *ChatUsecase) StopChat(ctx context.Context, req *domain.StopChatReq) error {
+	if err := u.conversationUsecase.ValidateConversationNonce(ctx, req.ConversationID, req.Nonce); err != nil {
+		return fmt.Errorf("validate conversation nonce failed: %w", err)
+	}
+	return u.stopStream(ctx, req.ConversationID)
+}
+
+func (u *ChatUsecase) stopStream(ctx context.Context, conversationID string) error {
+	if stream, ok := u.streams.Load(conversationID); ok {
+		stream.(*chatStream).cancel(errChatStopped)
+		return nil
+	}
+	exists, err := u.streamRepo.StreamExists(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("get chat stream failed: %w", err)
+	}
+	if !exists {
+		return ErrChatStreamNotFound
+	}
+	if err := u.streamRepo.PublishStop(ctx, conversationID); err != nil {
+		return fmt.Errorf("publish chat stop failed: %w", err)
+	}
+	return nil
+}
+
+// chatMessageStatus the answer is stopped if the stream is cancelled by the reader or the client is disconnected
+func chatMessageStatus(streamCtx context.Context, chatErr error) domain.MessageStatus {
+	switch {
+	case chatErr == nil:
+		return domain.MessageStatusCompleted
+	case streamCtx.Err() != nil:
+		return domain.MessageStatusStopped
+	default:
+		return domain.MessageStatusFailed
+	}
+}
+
+type chatChunkFunc func(ctx context.Context, dataType, chunk string) error
+
+// chatWithFallback tries the models in order until one answers and returns the last tried model,
+// the next model is not tried once a chunk is streamed, since the answer can not be taken back
+func (u *ChatUsecase) chatWithFallback(
+	ctx context.Context,
+	models []*domain.Model,
+	usage *schema.TokenUsage,
+	chat func(ctx context.Context, model *domain.Model, usage *schema.TokenUsage, onChunk chatChunkFunc) error,
+	onChunk chatChunkFunc,
+) (*domain.Model, error) {
+	var err error
+	for i, model := range models {
+		*usage = schema.TokenUsage{}
+		streamed := false
+		err = chat(ctx, model, usage, func(ctx context.Context, dataType, chunk string) error {
+			streamed = true
+			return onChunk(ctx, dataType, chunk)
+		})
+		if err == nil || streamed || i == len(models)-1 || ctx.Err() != nil {
+			return model, err
+		}
+		u.logger.Warn("chat model failed, fallback to the next model",
+			log.String("model", model.Model),
+			log.String("next_model", models[i+1].Model),
+			log.Error(err))
+	}
+	return nil, err
+}
+
+// estimateUsage estimates the tokens consumed by a stopped stream
+func estimateUsage(messages []*schema.Message, answer string) schema.TokenUsage {
+	usage := schema.TokenUsage{
+		CompletionTokens: utils.EstimateTokens(answer),
+	}
+	for _, message := range messages {
+		usage.PromptTokens += utils.EstimateTokens(message.Content)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// chatWithModel streams the answer of the model, fails if no chunk is received in chatFirstChunkTimeout
+func (u *ChatUsecase) chatWithModel(
+	ctx context.Context,
+	model *domain.Model,
+	messages []*schema.Message,
+	usage *schema.TokenUsage,
+	onChunk chatChunkFunc,
+) error {
+	chatModel, err := u.llmUsecase.GetChatModel(ctx, model)
+	if err != nil {
+		return fmt.Errorf("get chat model failed: %w", err)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	timer := time.AfterFunc(chatFirstChunkTimeout, func() {
+		cancel(errChatFirstChunkTimeout)
+	})
+	defer timer.Stop()
+
+	err = u.llmUsecase.ChatWithAgent(ctx, chatModel, messages, usage, func(ctx context.Context, dataType, chunk string) error {
+		timer.Stop()
+		return onChunk(ctx, dataType, chunk)
+	})
+	if err != nil && errors.Is(context.Cause(ctx), errChatFirstChunkTimeout) {
+		return fmt.Errorf("%w: %w", errChatFirstChunkTimeout, err)
+	}
+	return err
+}
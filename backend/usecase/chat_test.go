@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/repo/cache"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+func TestChatWithFallback(t *testing.T) {
+	errChat := errors.New("chat failed")
+	models := []*domain.Model{{ID: "first", Model: "first"}, {ID: "second", Model: "second"}, {ID: "third", Model: "third"}}
+	for _, tc := range []struct {
+		name string
+		// the result of the models by id, the chunk is streamed before the error
+		chunks map[string]string
+		errs   map[string]error
+		// cancel the context after the model is called
+		cancelAfter string
+		tried       []string
+		model       string
+		err         error
+	}{
+		{name: "first answers", chunks: map[string]string{"first": "answer"}, tried: []string{"first"}, model: "first"},
+		{name: "fallback to the next", errs: map[string]error{"first": errChat}, chunks: map[string]string{"second": "answer"}, tried: []string{"first", "second"}, model: "second"},
+		{name: "streamed chunk is kept", chunks: map[string]string{"first": "partial"}, errs: map[string]error{"first": errChat}, tried: []string{"first"}, model: "first", err: errChat},
+		{name: "all failed", errs: map[string]error{"first": errChat, "second": errChat, "third": errChat}, tried: []string{"first", "second", "third"}, model: "third", err: errChat},
+		{name: "stopped", errs: map[string]error{"first": context.Canceled}, cancelAfter: "first", tried: []string{"first"}, model: "first", err: context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			u := &ChatUsecase{logger: newTestLogger()}
+			var tried []string
+			answer := ""
+			usage := schema.TokenUsage{}
+			model, err := u.chatWithFallback(ctx, models, &usage, func(ctx context.Context, model *domain.Model, usage *schema.TokenUsage, onChunk chatChunkFunc) error {
+				tried = append(tried, model.ID)
+				usage.TotalTokens = 10
+				if chunk, ok := tc.chunks[model.ID]; ok {
+					if err := onChunk(ctx, "data", chunk); err != nil {
+						return err
+					}
+				}
+				if model.ID == tc.cancelAfter {
+					cancel()
+				}
+				return tc.errs[model.ID]
+			}, func(ctx context.Context, dataType, chunk string) error {
+				answer += chunk
+				return nil
+			})
+			if !errors.Is(err, tc.err) {
+				t.Errorf("error = %v, expected %v", err, tc.err)
+			}
+			if model.ID != tc.model {
+				t.Errorf("model = %s, expected %s", model.ID, tc.model)
+			}
+			if !slices.Equal(tried, tc.tried) {
+				t.Errorf("tried = %v, expected %v", tried, tc.tried)
+			}
+			if expected := tc.chunks[tc.model]; answer != expected {
+				t.Errorf("answer = %q, expected %q", answer, expected)
+			}
+			if usage.TotalTokens != 10 {
+				t.Errorf("usage = %d, expected the usage of the last model", usage.TotalTokens)
+			}
+		})
+	}
+}
+
+func TestGetChatModelsDeleted(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "models"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}))
+	u := &ModelUsecase{modelRepo: pg.NewModelRepository(db, newTestLogger()), logger: newTestLogger()}
+	if _, err := u.GetChatModels(context.Background(), []string{"deleted"}); !errors.Is(err, domain.ErrBoundChatModelsDeleted) {
+		t.Errorf("GetChatModels error = %v, expected %v", err, domain.ErrBoundChatModelsDeleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStopChat(t *testing.T) {
+	c, _ := newTestCache(t)
+	streamRepo := cache.NewChatStreamRepo(c)
+	ctx := context.Background()
+	// the stream runs on the owner, the stop may be received by the other instance
+	owner, err := NewChatUsecase(nil, nil, nil, nil, nil, nil, nil, streamRepo, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewChatUsecase(nil, nil, nil, nil, nil, nil, nil, streamRepo, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instance := range []*ChatUsecase{owner, other} {
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		owner.streams.Store("conversation", &chatStream{cancel: cancel})
+		if err := streamRepo.AddStream(ctx, "conversation", chatStreamTTL); err != nil {
+			t.Fatal(err)
+		}
+		if err := instance.stopStream(ctx, "conversation"); err != nil {
+			t.Fatalf("stopStream error = %v", err)
+		}
+		select {
+		case <-streamCtx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("stream is not stopped")
+		}
+		if cause := context.Cause(streamCtx); !errors.Is(cause, errChatStopped) {
+			t.Errorf("stream cause = %v, expected %v", cause, errChatStopped)
+		}
+		owner.streams.Delete("conversation")
+	}
+	if err := other.stopStream(ctx, "missing"); !errors.Is(err, ErrChatStreamNotFound) {
+		t.Errorf("stopStream error = %v, expected %v", err, ErrChatStreamNotFound)
+	}
+}
+
+func TestChatMessageStatus(t *testing.T) {
+	errChat := errors.New("chat failed")
+
+	streamCtx, cancel := context.WithCancelCause(context.Background())
+	if status := chatMessageStatus(streamCtx, nil); status != domain.MessageStatusCompleted {
+		t.Errorf("completed status = %s", status)
+	}
+	if status := chatMessageStatus(streamCtx, errChat); status != domain.MessageStatusFailed {
+		t.Errorf("failed status = %s", status)
+	}
+	cancel(errChatStopped)
+	if status := chatMessageStatus(streamCtx, context.Canceled); status != domain.MessageStatusStopped {
+		t.Errorf("stopped status = %s", status)
+	}
+
+	// the stream of a disconnected client is cancelled with the request
+	requestCtx, disconnect := context.WithCancel(context.Background())
+	streamCtx, cancel = context.WithCancelCause(requestCtx)
+	defer cancel(nil)
+	disconnect()
+	if status := chatMessageStatus(streamCtx, context.Canceled); status != domain.MessageStatusStopped {
+		t.Errorf("disconnected status = %s", status)
+	}
+}
@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+const (
+	citationContextLen = 200 // runes before the marker used to pick the cited chunk
+	citationSnippetLen = 200
+)
+
+var (
+	// inline citation marker [[n](URL)]
+	citationMarkerRegexp = regexp.MustCompile(`\[\[(\d+)\]\(([^()\s]*)\)\]`)
+	// whole reference list block at the end of the answer
+	referenceBlockRegexp = regexp.MustCompile(`(?ms)((?:>|\\u003e)\s*\[\d+\]\.\s*\[.*?\]\(.*?\)\s*\n?)+$`)
+	referenceLineRegexp  = regexp.MustCompile(`(?m)^(?:>|\\u003e)\s*\[(\d+)\]\.\s*\[(.*?)\]\((.*?)\)`)
+	// reference list heading left behind when all the references are unsupported
+	referenceHeadingRegexp = regexp.MustCompile(`(?s)\n*(?:-{3,}\s*\n)?#+\s*引用列表\s*(?:\n-{3,}\s*)?$`)
+)
+
+// citationTracker maps the citation markers of a streaming answer back to the retrieved nodes
+type citationTracker struct {
+	nodes     []*domain.RankedNodeChunks
+	baseURL   string
+	scanned   int // bytes of the answer scanned for markers
+	citations []*domain.Citation
+}
+
+func newCitationTracker(nodes []*domain.RankedNodeChunks, baseURL string) *citationTracker {
+	return &citationTracker{
+		nodes:     nodes,
+		baseURL:   baseURL,
+		citations: make([]*domain.Citation, 0),
+	}
+}
+
+// Feed scans the answer received so far and returns the new citations
+func (t *citationTracker) Feed(answer string) []*domain.Citation {
+	// skip reasoning content
+	if strings.HasPrefix(answer, "<think>") {
+		endIndex := strings.Index(answer, "</think>")
+		if endIndex == -1 {
+			return nil
+		}
+		t.scanned = max(t.scanned, endIndex+len("</think>"))
+	}
+	newCitations := make([]*domain.Citation, 0)
+	offset := t.scanned
+	matches := citationMarkerRegexp.FindAllStringSubmatchIndex(answer[offset:], -1)
+	for _, match := range matches {
+		number, err := strconv.Atoi(answer[offset+match[2] : offset+match[3]])
+		if err != nil {
+			continue
+		}
+		url := answer[offset+match[4] : offset+match[5]]
+		if citation := t.cite(number, url, answer[:offset+match[0]]); citation != nil {
+			newCitations = append(newCitations, citation)
+		}
+	}
+	if len(matches) > 0 {
+		t.scanned = offset + matches[len(matches)-1][1]
+	}
+	return newCitations
+}
+
+// Finish maps the reference list of the full answer, for answers without inline markers
+func (t *citationTracker) Finish(answer string) []*domain.Citation {
+	newCitations := t.Feed(answer)
+	if endIndex := strings.Index(answer, "</think>"); endIndex != -1 {
+		answer = answer[endIndex+len("</think>"):]
+	}
+	allMatches := referenceBlockRegexp.FindAllStringIndex(answer, -1)
+	if len(allMatches) == 0 {
+		return newCitations
+	}
+	block := answer[allMatches[len(allMatches)-1][0]:]
+	for _, match := range referenceLineRegexp.FindAllStringSubmatch(block, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if citation := t.cite(number, match[3], ""); citation != nil {
+			newCitations = append(newCitations, citation)
+		}
+	}
+	return newCitations
+}
+
+// Citations all the citations in the order of appearance
+func (t *citationTracker) Citations() []*domain.Citation {
+	return t.citations
+}
+
+// Verify strips the citations not supported by the retrieved nodes from the full answer,
+// a citation is unsupported if the url is not a retrieved node or the number is out of range or reused for another node.
+// returns the verified answer, the number of citations and the number of unsupported ones
+func (t *citationTracker) Verify(answer string) (string, int, int) {
+	reasoning := ""
+	if endIndex := strings.Index(answer, "</think>"); endIndex != -1 {
+		reasoning, answer = answer[:endIndex+len("</think>")], answer[endIndex+len("</think>"):]
+	}
+	total, unsupported := 0, 0
+	numberNodes := make(map[int]string)
+	supported := func(numberText, url string) bool {
+		total++
+		number, err := strconv.Atoi(numberText)
+		node := t.findNode(url)
+		if err != nil || number < 1 || number > len(t.nodes) || node == nil {
+			unsupported++
+			return false
+		}
+		if nodeID, ok := numberNodes[number]; ok && nodeID != node.NodeID {
+			unsupported++
+			return false
+		}
+		numberNodes[number] = node.NodeID
+		return true
+	}
+	answer = citationMarkerRegexp.ReplaceAllStringFunc(answer, func(marker string) string {
+		match := citationMarkerRegexp.FindStringSubmatch(marker)
+		if supported(match[1], match[2]) {
+			return marker
+		}
+		return ""
+	})
+	if allMatches := referenceBlockRegexp.FindAllStringIndex(answer, -1); len(allMatches) > 0 {
+		start := allMatches[len(allMatches)-1][0]
+		block := referenceLineRegexp.ReplaceAllStringFunc(answer[start:], func(line string) string {
+			match := referenceLineRegexp.FindStringSubmatch(line)
+			if supported(match[1], match[3]) {
+				return line
+			}
+			return ""
+		})
+		// drop the emptied lines
+		lines := lo.Filter(strings.SplitAfter(block, "\n"), func(line string, _ int) bool {
+			return strings.TrimSpace(line) != ""
+		})
+		block = strings.Join(lines, "")
+		if !referenceLineRegexp.MatchString(block) {
+			answer = referenceHeadingRegexp.ReplaceAllString(answer[:start], "")
+		} else {
+			answer = answer[:start] + block
+		}
+	}
+	return reasoning + answer, total, unsupported
+}
+
+// cite maps a marker to the retrieved node and picks the chunk most similar to the text before the marker,
+// returns nil if the number is out of range or already cited or the url is not a retrieved node
+func (t *citationTracker) cite(number int, url, text string) *domain.Citation {
+	if number < 1 || number > len(t.nodes) || lo.ContainsBy(t.citations, func(c *domain.Citation) bool { return c.Number == number }) {
+		return nil
+	}
+	node := t.findNode(url)
+	if node == nil {
+		return nil
+	}
+	citation := &domain.Citation{
+		Number:   number,
+		NodeID:   node.NodeID,
+		NodeName: node.NodeName,
+		URL:      node.GetURL(t.baseURL),
+	}
+	context := citationContext(text)
+	if chunk := pickCitedChunk(node.Chunks, context); chunk != nil {
+		citation.ChunkID = chunk.ID
+		citation.Snippet = keywordExcerpt(chunk.Content, context, citationSnippetLen)
+	}
+	t.citations = append(t.citations, citation)
+	return citation
+}
+
+func (t *citationTracker) findNode(url string) *domain.RankedNodeChunks {
+	url = strings.TrimRight(strings.TrimSpace(url), "/")
+	if url == "" {
+		return nil
+	}
+	for _, node := range t.nodes {
+		if strings.TrimRight(node.GetURL(t.baseURL), "/") == url {
+			return node
+		}
+	}
+	// the model may rewrite the base url, match by the node path
+	for _, node := range t.nodes {
+		if strings.HasSuffix(url, "/node/"+node.NodeID) {
+			return node
+		}
+	}
+	return nil
+}
+
+// citationContext the sentence before the marker, other markers are removed
+func citationContext(text string) string {
+	text = citationMarkerRegexp.ReplaceAllString(text, "")
+	if utf8.RuneCountInString(text) > citationContextLen {
+		runes := []rune(text)
+		text = string(runes[len(runes)-citationContextLen:])
+	}
+	if index := strings.LastIndexAny(strings.TrimRight(text, "。.!！?？\n "), "。!！?？\n"); index != -1 {
+		_, size := utf8.DecodeRuneInString(text[index:])
+		text = text[index+size:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// pickCitedChunk picks the chunk most similar to the context, the best ranked chunk by default
+func pickCitedChunk(chunks []*domain.NodeContentChunk, context string) *domain.NodeContentChunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+	best := chunks[0]
+	if context == "" {
+		return best
+	}
+	contextTokens := questionTokens(context)
+	bestSimilarity := 0.0
+	for _, chunk := range chunks {
+		if similarity := diceSimilarity(contextTokens, questionTokens(chunk.Content)); similarity > bestSimilarity {
+			best, bestSimilarity = chunk, similarity
+		}
+	}
+	return best
+}
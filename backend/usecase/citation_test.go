@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestCitationTracker(t *testing.T) {
+	nodes := []*domain.RankedNodeChunks{
+		{NodeID: "a", NodeName: "安装", Chunks: []*domain.NodeContentChunk{
+			{ID: "a1", Content: "使用 docker compose 安装服务"},
+			{ID: "a2", Content: "配置 SSO 登录需要填写回调地址"},
+		}},
+		{NodeID: "b", NodeName: "价格"},
+	}
+	tracker := newCitationTracker(nodes, "https://wiki.example.com")
+
+	if citations := tracker.Feed("<think>参考 [[1](https://wiki.example.com/node/a)]"); len(citations) != 0 {
+		t.Fatalf("markers in reasoning should be ignored, got %d", len(citations))
+	}
+	answer := "<think>参考 [[1](https://wiki.example.com/node/a)]</think>配置 SSO 登录需要回调地址[[1](https://wiki.example.com/node/a)]。"
+	citations := tracker.Feed(answer)
+	if len(citations) != 1 || citations[0].NodeID != "a" || citations[0].ChunkID != "a2" {
+		t.Fatalf("unexpected citations %+v", citations)
+	}
+	// rescanning the same answer yields nothing new
+	if citations := tracker.Feed(answer); len(citations) != 0 {
+		t.Fatalf("citations should be emitted once, got %d", len(citations))
+	}
+	// the model rewrote the base url, unknown nodes are dropped
+	answer += "价格见文档[[2](http://localhost/node/b)]，另见[[3](http://localhost/node/x)]。"
+	if citations := tracker.Feed(answer); len(citations) != 1 || citations[0].NodeID != "b" || citations[0].URL != "https://wiki.example.com/node/b" {
+		t.Fatalf("unexpected citations %+v", citations)
+	}
+
+	tracker = newCitationTracker(nodes, "https://wiki.example.com")
+	citations = tracker.Finish("答案\n\n> [1]. [安装](https://wiki.example.com/node/a)\n")
+	if len(citations) != 1 || citations[0].Number != 1 || citations[0].ChunkID != "a1" {
+		t.Fatalf("reference list should be mapped, got %+v", citations)
+	}
+	if len(tracker.Citations()) != 1 {
+		t.Fatalf("expected 1 citation, got %d", len(tracker.Citations()))
+	}
+}
+
+func TestCitationTrackerVerify(t *testing.T) {
+	nodes := []*domain.RankedNodeChunks{{NodeID: "a", NodeName: "安装"}, {NodeID: "b", NodeName: "价格"}}
+	tracker := newCitationTracker(nodes, "https://wiki.example.com")
+
+	answer := "<think>[[9](x)]</think>安装见文档[[1](https://wiki.example.com/node/a)]，价格见[[2](https://wiki.example.com/node/x)]，" +
+		"另见[[7](https://wiki.example.com/node/b)]，[[1](https://wiki.example.com/node/b)]。\n\n---\n### 引用列表\n" +
+		"> [1]. [安装](https://wiki.example.com/node/a)\n> [2]. [其他](https://wiki.example.com/node/x)\n"
+	verified, total, unsupported := tracker.Verify(answer)
+	if total != 6 || unsupported != 4 {
+		t.Errorf("expected 6 citations and 4 unsupported, got %d %d", total, unsupported)
+	}
+	expected := "<think>[[9](x)]</think>安装见文档[[1](https://wiki.example.com/node/a)]，价格见，另见，。\n\n---\n### 引用列表\n" +
+		"> [1]. [安装](https://wiki.example.com/node/a)\n"
+	if verified != expected {
+		t.Errorf("unexpected verified answer %q", verified)
+	}
+
+	verified, _, unsupported = tracker.Verify("答案[[3](https://wiki.example.com/node/c)]。\n\n---\n### 引用列表\n> [3]. [其他](https://wiki.example.com/node/c)\n")
+	if unsupported != 2 || verified != "答案。" {
+		t.Errorf("the empty reference list should be removed, got %d %q", unsupported, verified)
+	}
+}
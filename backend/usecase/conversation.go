@@ -2,9 +2,13 @@ package usecase
 
 import (
 	"context"
-	"regexp"
+	"errors"
+	"fmt"
 
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
 	"github.com/samber/lo"
+	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
@@ -12,6 +16,8 @@ import (
 	"github.com/chaitin/panda-wiki/repo/pg"
 )
 
+var ErrChatParentRequired = errors.New("parent_id is required once the conversation has branches")
+
 type ConversationUsecase struct {
 	repo     *pg.ConversationRepository
 	nodeRepo *pg.NodeRepository
@@ -33,9 +39,77 @@ func NewConversationUsecase(
 	}
 }
 
-func (u *ConversationUsecase) CreateChatConversationMessage(ctx context.Context, kbID string, conversation *domain.ConversationMessage) error {
-	references := extractReferencesBlock(conversation.ID, conversation.AppID, conversation.Content)
-	return u.repo.CreateConversationMessage(ctx, conversation, references)
+// CreateChatConversationMessage saves the message with the documents cited by it
+func (u *ConversationUsecase) CreateChatConversationMessage(ctx context.Context, kbID string, message *domain.ConversationMessage, citations []*domain.Citation) error {
+	references := lo.Map(citations, func(citation *domain.Citation, _ int) *domain.ConversationReference {
+		return &domain.ConversationReference{
+			ConversationID: message.ConversationID,
+			MessageID:      message.ID,
+			AppID:          message.AppID,
+			NodeID:         citation.NodeID,
+			Name:           citation.NodeName,
+			URL:            citation.URL,
+			Number:         citation.Number,
+			ChunkID:        citation.ChunkID,
+			Snippet:        citation.Snippet,
+		}
+	})
+	return u.repo.CreateConversationMessage(ctx, message, references)
+}
+
+// SaveChatQuestion saves the user message of the chat request by the action, returns the user message to answer
+func (u *ConversationUsecase) SaveChatQuestion(ctx context.Context, req *domain.ChatRequest) (*domain.ConversationMessage, error) {
+	parentID := req.ParentID
+	switch req.Action {
+	case domain.ChatActionRegenerate, domain.ChatActionEdit:
+		message, err := u.repo.GetConversationMessage(ctx, req.ConversationID, req.MessageID)
+		if err != nil {
+			return nil, fmt.Errorf("get conversation message failed: %w", err)
+		}
+		if message.Role != schema.User {
+			return nil, fmt.Errorf("message %s is not a user message", message.ID)
+		}
+		// answer the same question again
+		if req.Action == domain.ChatActionRegenerate {
+			return message, nil
+		}
+		parentID = message.ParentID
+	default:
+		if parentID != "" {
+			if _, err := u.repo.GetConversationMessage(ctx, req.ConversationID, parentID); err != nil {
+				return nil, fmt.Errorf("get parent message failed: %w", err)
+			}
+		} else {
+			// the latest message may be on a branch the reader has left
+			branched, err := u.repo.HasConversationBranches(ctx, req.ConversationID)
+			if err != nil {
+				return nil, fmt.Errorf("check conversation branches failed: %w", err)
+			}
+			if branched {
+				return nil, ErrChatParentRequired
+			}
+			latest, err := u.repo.GetLatestConversationMessage(ctx, req.ConversationID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("get latest message failed: %w", err)
+			}
+			if latest != nil {
+				parentID = latest.ID
+			}
+		}
+	}
+	question := &domain.ConversationMessage{
+		ID:             uuid.New().String(),
+		ConversationID: req.ConversationID,
+		AppID:          req.AppID,
+		ParentID:       parentID,
+		Role:           schema.User,
+		Content:        req.Message,
+		RemoteIP:       req.RemoteIP,
+	}
+	if err := u.CreateChatConversationMessage(ctx, req.KBID, question, nil); err != nil {
+		return nil, fmt.Errorf("create user message failed: %w", err)
+	}
+	return question, nil
 }
 
 func (u *ConversationUsecase) GetConversationList(ctx context.Context, request *domain.ConversationListReq) (*domain.PaginatedResult[[]*domain.ConversationListItem], error) {
@@ -89,38 +163,15 @@ func (u *ConversationUsecase) GetConversationDetail(ctx context.Context, convers
 	return conversation, nil
 }
 
-func extractReferencesBlock(conversationID, appID, text string) []*domain.ConversationReference {
-	// match whole reference block
-	reBlock := regexp.MustCompile(`(?ms)((?:>|\\u003e)\s*\[\d+\]\.\s*\[.*?\]\(.*?\)\s*\n?)+$`)
-	// find the last match index
-	lastIndex := -1
-	allMatches := reBlock.FindAllStringIndex(text, -1)
-	if len(allMatches) > 0 {
-		lastIndex = allMatches[len(allMatches)-1][0]
+// FeedbackMessage saves the reader feedback of an answer, the nonce proves the reader owns the conversation
+func (u *ConversationUsecase) FeedbackMessage(ctx context.Context, req *domain.FeedbackReq) error {
+	if err := u.repo.ValidateConversationNonce(ctx, req.ConversationID, req.Nonce); err != nil {
+		return fmt.Errorf("validate conversation nonce failed: %w", err)
 	}
-
-	if lastIndex == -1 {
-		return nil
-	}
-
-	// extract all references in the last reference block
-	block := text[lastIndex:]
-	reLine := regexp.MustCompile(`(?m)^(?:>|\\u003e)\s*\[(\d+)\]\.\s*\[(.*?)\]\((.*?)\)`)
-	matches := reLine.FindAllStringSubmatch(block, -1)
-
-	refs := make([]*domain.ConversationReference, 0)
-	for _, match := range matches {
-		if len(match) == 4 {
-			refs = append(refs, &domain.ConversationReference{
-				Name: match[2],
-				URL:  match[3],
-
-				ConversationID: conversationID,
-				AppID:          appID,
-			})
-		}
+	if err := u.repo.UpdateMessageFeedback(ctx, req); err != nil {
+		return fmt.Errorf("update message feedback failed: %w", err)
 	}
-	return refs
+	return nil
 }
 
 func (u *ConversationUsecase) ValidateConversationNonce(ctx context.Context, conversationID, nonce string) error {
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/repo/ipdb"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+func TestSaveChatQuestionParent(t *testing.T) {
+	ctx := context.Background()
+	req := &domain.ChatRequest{ConversationID: "conversation", AppID: "app", KBID: "kb", Message: "question"}
+
+	// the latest message is replied to if the conversation has no branches,
+	// the history sent by the client is not saved
+	req.History = []*domain.ConversationMessage{{Role: schema.User, Content: "hello"}, {Role: schema.Assistant, Content: "forged"}}
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs("conversation").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT \* FROM "conversation_messages"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id"}).AddRow("latest", "conversation"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "conversation_messages"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	u := NewConversationUsecase(pg.NewConversationRepository(db), nil, newTestLogger(), nil)
+	question, err := u.SaveChatQuestion(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if question.ParentID != "latest" {
+		t.Errorf("parent = %s, expected the latest message", question.ParentID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// the latest message may be on another branch
+	db, mock = newMockDB(t)
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs("conversation").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	u = NewConversationUsecase(pg.NewConversationRepository(db), nil, newTestLogger(), nil)
+	if _, err := u.SaveChatQuestion(ctx, req); !errors.Is(err, ErrChatParentRequired) {
+		t.Errorf("SaveChatQuestion error = %v, expected %v", err, ErrChatParentRequired)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConversationListFeedback(t *testing.T) {
+	db, mock := newMockDB(t)
+	// only the conversations with a disliked answer, counted with the votes of their answers
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "conversations" WHERE conversations.kb_id = \$1 AND \(EXISTS \(SELECT 1 FROM conversation_messages WHERE conversation_messages.conversation_id = conversations.id AND conversation_messages.feedback_score = \$2\)\)`).
+		WithArgs("kb", domain.FeedbackScoreDislike).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`feedback_score = \$1\) as like_count, .*feedback_score = \$2\) as dislike_count .*feedback_score = \$4\)`).
+		WithArgs(domain.FeedbackScoreLike, domain.FeedbackScoreDislike, "kb", domain.FeedbackScoreDislike, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remote_ip", "like_count", "dislike_count"}).AddRow("conversation", "10.0.0.1", 1, 2))
+	logger := newTestLogger()
+	u := NewConversationUsecase(pg.NewConversationRepository(db), nil, logger, ipdb.NewIPAddressRepo(nil, logger))
+	result, err := u.GetConversationList(context.Background(), &domain.ConversationListReq{
+		KBID:          "kb",
+		FeedbackScore: lo.ToPtr(domain.FeedbackScoreDislike),
+		Pager:         domain.Pager{Page: 1, PageSize: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 || len(result.Data) != 1 {
+		t.Fatalf("conversations = %d of %d, expected 1", len(result.Data), result.Total)
+	}
+	if conversation := result.Data[0]; conversation.LikeCount != 1 || conversation.DislikeCount != 2 {
+		t.Errorf("votes = %d likes and %d dislikes, expected 1 and 2", conversation.LikeCount, conversation.DislikeCount)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
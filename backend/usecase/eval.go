@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+type EvalUsecase struct {
+	evalRepo   *pg.EvalRepository
+	kbRepo     *pg.KnowledgeBaseRepository
+	modelRepo  *pg.ModelRepository
+	llmUsecase *LLMUsecase
+	logger     *log.Logger
+}
+
+func NewEvalUsecase(evalRepo *pg.EvalRepository, kbRepo *pg.KnowledgeBaseRepository, modelRepo *pg.ModelRepository, llmUsecase *LLMUsecase, logger *log.Logger) *EvalUsecase {
+	return &EvalUsecase{
+		evalRepo:   evalRepo,
+		kbRepo:     kbRepo,
+		modelRepo:  modelRepo,
+		llmUsecase: llmUsecase,
+		logger:     logger.WithModule("usecase.eval"),
+	}
+}
+
+func (u *EvalUsecase) CreateEvalSet(ctx context.Context, req *domain.CreateEvalSetReq) (string, error) {
+	if _, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KBID); err != nil {
+		return "", fmt.Errorf("get kb failed: %w", err)
+	}
+	cases := req.Cases
+	if cases == nil {
+		cases = make([]*domain.EvalCase, 0)
+	}
+	set := &domain.EvalSet{
+		ID:          uuid.New().String(),
+		KBID:        req.KBID,
+		Name:        req.Name,
+		Description: req.Description,
+		Cases:       cases,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := u.evalRepo.CreateEvalSet(ctx, set); err != nil {
+		return "", err
+	}
+	return set.ID, nil
+}
+
+func (u *EvalUsecase) GetEvalSetList(ctx context.Context, kbID string) ([]*domain.EvalSetListItem, error) {
+	return u.evalRepo.GetEvalSetList(ctx, kbID)
+}
+
+func (u *EvalUsecase) GetEvalSet(ctx context.Context, id string) (*domain.EvalSet, error) {
+	return u.evalRepo.GetEvalSet(ctx, id)
+}
+
+func (u *EvalUsecase) UpdateEvalSet(ctx context.Context, req *domain.UpdateEvalSetReq) error {
+	return u.evalRepo.UpdateEvalSet(ctx, req)
+}
+
+func (u *EvalUsecase) DeleteEvalSet(ctx context.Context, id string) error {
+	return u.evalRepo.DeleteEvalSet(ctx, id)
+}
+
+// RunEvalSet retrieves every question of the set with the current retrieval pipeline and persists a report
+func (u *EvalUsecase) RunEvalSet(ctx context.Context, req *domain.RunEvalSetReq) (*domain.EvalReport, error) {
+	set, err := u.evalRepo.GetEvalSet(ctx, req.SetID)
+	if err != nil {
+		return nil, fmt.Errorf("get eval set failed: %w", err)
+	}
+	if len(set.Cases) == 0 {
+		return nil, fmt.Errorf("eval set %s has no case", set.ID)
+	}
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, set.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	k := req.K
+	if k <= 0 {
+		k = kb.RetrievalSettings.GetTopK()
+	}
+	// retrieve exactly k nodes
+	evalKB := *kb
+	evalKB.RetrievalSettings.TopK = k
+
+	report := &domain.EvalReport{
+		ID:                uuid.New().String(),
+		KBID:              kb.ID,
+		SetID:             set.ID,
+		K:                 k,
+		CaseCount:         len(set.Cases),
+		RetrievalSettings: kb.RetrievalSettings,
+		Results:           make(domain.EvalCaseResults, 0, len(set.Cases)),
+		CreatedAt:         time.Now(),
+	}
+	if model, err := u.modelRepo.GetEmbeddingModel(ctx); err == nil {
+		report.EmbeddingModel = model.Model
+	}
+	if model, err := u.modelRepo.GetRerankModel(ctx); err == nil {
+		report.RerankModel = model.Model
+	}
+
+	for _, evalCase := range set.Cases {
+		var retrievedNodeIDs []string
+		result, err := u.llmUsecase.Retrieve(ctx, &evalKB, evalCase.Question)
+		if err == nil {
+			retrievedNodeIDs = lo.Map(result.RankedNodes, func(node *domain.RankedNodeChunks, _ int) string {
+				return node.NodeID
+			})
+		}
+		caseResult := evaluateCase(evalCase, retrievedNodeIDs, k)
+		if err != nil {
+			u.logger.Error("retrieve eval question failed", log.String("question", evalCase.Question), log.Error(err))
+			caseResult.Error = err.Error()
+		}
+		report.Results = append(report.Results, caseResult)
+	}
+	report.RecallAtK, report.MRR, report.HitRate = summarizeEvalResults(report.Results)
+
+	if err := u.evalRepo.CreateEvalReport(ctx, report); err != nil {
+		return nil, fmt.Errorf("save eval report failed: %w", err)
+	}
+	u.logger.Info("eval set finished",
+		log.String("set_id", set.ID),
+		log.Int("k", k),
+		log.Any("recall_at_k", report.RecallAtK),
+		log.Any("mrr", report.MRR),
+		log.Any("hit_rate", report.HitRate))
+	return report, nil
+}
+
+func (u *EvalUsecase) GetEvalReportList(ctx context.Context, req *domain.GetEvalReportListReq) (*domain.GetEvalReportListResp, error) {
+	reports, total, err := u.evalRepo.GetEvalReportList(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPaginatedResult(reports, total), nil
+}
+
+func (u *EvalUsecase) GetEvalReport(ctx context.Context, id string) (*domain.EvalReport, error) {
+	return u.evalRepo.GetEvalReport(ctx, id)
+}
+
+// evaluateCase computes recall@k, reciprocal rank and hit of the top k retrieved nodes
+func evaluateCase(evalCase *domain.EvalCase, retrievedNodeIDs []string, k int) *domain.EvalCaseResult {
+	if len(retrievedNodeIDs) > k {
+		retrievedNodeIDs = retrievedNodeIDs[:k]
+	}
+	result := &domain.EvalCaseResult{
+		Question:         evalCase.Question,
+		ExpectedNodeIDs:  evalCase.ExpectedNodeIDs,
+		RetrievedNodeIDs: retrievedNodeIDs,
+	}
+	expected := lo.Uniq(evalCase.ExpectedNodeIDs)
+	if len(expected) == 0 {
+		return result
+	}
+	found := 0
+	for i, nodeID := range retrievedNodeIDs {
+		if !lo.Contains(expected, nodeID) {
+			continue
+		}
+		found++
+		if result.ReciprocalRank == 0 {
+			result.ReciprocalRank = 1 / float64(i+1)
+		}
+	}
+	result.Recall = float64(found) / float64(len(expected))
+	result.Hit = found > 0
+	return result
+}
+
+func summarizeEvalResults(results domain.EvalCaseResults) (recall, mrr, hitRate float64) {
+	if len(results) == 0 {
+		return 0, 0, 0
+	}
+	for _, result := range results {
+		recall += result.Recall
+		mrr += result.ReciprocalRank
+		if result.Hit {
+			hitRate++
+		}
+	}
+	n := float64(len(results))
+	return recall / n, mrr / n, hitRate / n
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestEvaluateCase(t *testing.T) {
+	evalCase := &domain.EvalCase{Question: "q", ExpectedNodeIDs: []string{"a", "b"}}
+
+	result := evaluateCase(evalCase, []string{"x", "b", "y", "a"}, 3)
+	if result.Recall != 0.5 || result.ReciprocalRank != 0.5 || !result.Hit {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(result.RetrievedNodeIDs) != 3 {
+		t.Errorf("retrieved nodes should be cut to k, got %v", result.RetrievedNodeIDs)
+	}
+
+	result = evaluateCase(evalCase, nil, 3)
+	if result.Recall != 0 || result.ReciprocalRank != 0 || result.Hit {
+		t.Errorf("unexpected result for empty retrieval %+v", result)
+	}
+
+	recall, mrr, hitRate := summarizeEvalResults(domain.EvalCaseResults{
+		{Recall: 1, ReciprocalRank: 1, Hit: true},
+		{Recall: 0.5, ReciprocalRank: 1.0 / 3, Hit: true},
+		{},
+	})
+	if recall != 0.5 || math.Abs(mrr-4.0/9) > 1e-9 || math.Abs(hitRate-2.0/3) > 1e-9 {
+		t.Errorf("unexpected summary recall=%v mrr=%v hit_rate=%v", recall, mrr, hitRate)
+	}
+}
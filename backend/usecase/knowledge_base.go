@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,24 +18,30 @@ import (
 )
 
 type KnowledgeBaseUsecase struct {
-	repo     *pg.KnowledgeBaseRepository
-	nodeRepo *pg.NodeRepository
-	ragRepo  *mq.RAGRepository
-	rag      rag.RAGService
-	kbCache  *cache.KBRepo
-	logger   *log.Logger
-	config   *config.Config
+	repo      *pg.KnowledgeBaseRepository
+	nodeRepo  *pg.NodeRepository
+	modelRepo *pg.ModelRepository
+	ragRepo   *mq.RAGRepository
+	rag       rag.RAGService
+	kbCache   *cache.KBRepo
+	readers   *cache.ReaderSessionRepo
+	audit     *AuditUsecase
+	logger    *log.Logger
+	config    *config.Config
 }
 
-func NewKnowledgeBaseUsecase(repo *pg.KnowledgeBaseRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, rag rag.RAGService, kbCache *cache.KBRepo, logger *log.Logger, config *config.Config) (*KnowledgeBaseUsecase, error) {
+func NewKnowledgeBaseUsecase(repo *pg.KnowledgeBaseRepository, nodeRepo *pg.NodeRepository, modelRepo *pg.ModelRepository, ragRepo *mq.RAGRepository, rag rag.RAGService, kbCache *cache.KBRepo, readers *cache.ReaderSessionRepo, audit *AuditUsecase, logger *log.Logger, config *config.Config) (*KnowledgeBaseUsecase, error) {
 	u := &KnowledgeBaseUsecase{
-		repo:     repo,
-		nodeRepo: nodeRepo,
-		ragRepo:  ragRepo,
-		rag:      rag,
-		logger:   logger.WithModule("usecase.knowledge_base"),
-		config:   config,
-		kbCache:  kbCache,
+		repo:      repo,
+		nodeRepo:  nodeRepo,
+		modelRepo: modelRepo,
+		ragRepo:   ragRepo,
+		rag:       rag,
+		logger:    logger.WithModule("usecase.knowledge_base"),
+		config:    config,
+		kbCache:   kbCache,
+		readers:   readers,
+		audit:     audit,
 	}
 	return u, nil
 }
@@ -73,12 +80,102 @@ func (u *KnowledgeBaseUsecase) GetKnowledgeBaseList(ctx context.Context) ([]*dom
 }
 
 func (u *KnowledgeBaseUsecase) UpdateKnowledgeBase(ctx context.Context, req *domain.UpdateKnowledgeBaseReq) error {
+	if req.RetrievalSettings != nil {
+		if err := req.RetrievalSettings.Validate(); err != nil {
+			return fmt.Errorf("invalid retrieval settings: %w", err)
+		}
+	}
+	if req.PromptSettings != nil {
+		if err := req.PromptSettings.Validate(); err != nil {
+			return fmt.Errorf("invalid prompt settings: %w", err)
+		}
+	}
+	if err := checkChatModelIDs(ctx, u.modelRepo, req.ChatModelIDs); err != nil {
+		return err
+	}
+	if req.QuotaSettings != nil {
+		if err := req.QuotaSettings.Validate(); err != nil {
+			return fmt.Errorf("invalid quota settings: %w", err)
+		}
+	}
+	before, err := u.repo.GetKnowledgeBaseByID(ctx, req.ID)
+	if err != nil {
+		return fmt.Errorf("get knowledge base failed: %w", err)
+	}
+	if req.AccessSettings != nil {
+		if err := prepareReaderAuth(&before.AccessSettings, req.AccessSettings); err != nil {
+			return err
+		}
+	}
+	return u.updateKnowledgeBase(ctx, req, before)
+}
+
+// RevokeShareLinks regenerates the secret signing the share links of the kb, the links stop working
+func (u *KnowledgeBaseUsecase) RevokeShareLinks(ctx context.Context, kbID string) error {
+	kb, err := u.repo.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		return fmt.Errorf("get knowledge base failed: %w", err)
+	}
+	settings := kb.AccessSettings
+	if settings.ReaderAuth.ShareLink.Secret, err = randomHex(32); err != nil {
+		return err
+	}
+	return u.updateKnowledgeBase(ctx, &domain.UpdateKnowledgeBaseReq{ID: kbID, AccessSettings: &settings}, kb)
+}
+
+// prepareReaderAuth hashes the new password and keeps the secrets not editable by the request
+func prepareReaderAuth(current, settings *domain.AccessSettings) error {
+	if settings.SimpleAuth.Password != "" {
+		if err := settings.SimpleAuth.SetPassword(settings.SimpleAuth.Password); err != nil {
+			return fmt.Errorf("hash password failed: %w", err)
+		}
+	} else {
+		settings.SimpleAuth.PasswordHash = current.SimpleAuth.PasswordHash
+	}
+	if settings.SimpleAuth.Enabled && settings.SimpleAuth.PasswordHash == "" {
+		return fmt.Errorf("password of simple auth is required")
+	}
+	if settings.ReaderAuth.OIDC.Enabled && len(settings.ReaderAuth.OIDC.EmailDomains) == 0 {
+		return fmt.Errorf("email domains of oidc reader auth are required")
+	}
+	settings.ReaderAuth.ShareLink.Secret = current.ReaderAuth.ShareLink.Secret
+	if settings.ReaderAuth.ShareLink.Enabled && settings.ReaderAuth.ShareLink.Secret == "" {
+		secret, err := randomHex(32)
+		if err != nil {
+			return err
+		}
+		settings.ReaderAuth.ShareLink.Secret = secret
+	}
+	return nil
+}
+
+// updateKnowledgeBase saves the update, the reader sessions are revoked if the reader auth changed
+func (u *KnowledgeBaseUsecase) updateKnowledgeBase(ctx context.Context, req *domain.UpdateKnowledgeBaseReq, before *domain.KnowledgeBase) error {
 	if err := u.repo.UpdateKnowledgeBase(ctx, req); err != nil {
 		return err
 	}
 	if err := u.kbCache.DeleteKB(ctx, req.ID); err != nil {
 		return err
 	}
+	if req.AccessSettings != nil &&
+		(!reflect.DeepEqual(req.AccessSettings.SimpleAuth, before.AccessSettings.SimpleAuth) ||
+			!reflect.DeepEqual(req.AccessSettings.ReaderAuth, before.AccessSettings.ReaderAuth)) {
+		if err := u.readers.DeleteSessionsByKBID(ctx, req.ID); err != nil {
+			return fmt.Errorf("revoke reader sessions failed: %w", err)
+		}
+	}
+	after, err := u.repo.GetKnowledgeBaseByID(ctx, req.ID)
+	if err != nil {
+		return fmt.Errorf("get knowledge base failed: %w", err)
+	}
+	// not a change of the settings
+	after.UpdatedAt = before.UpdatedAt
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionKBUpdate,
+		TargetType: domain.AuditTargetKB,
+		TargetID:   req.ID,
+		KBID:       req.ID,
+	}, before, after)
 	return nil
 }
 
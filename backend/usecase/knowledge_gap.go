@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+const (
+	defaultKnowledgeGapDays = 30
+	// questions with token dice similarity not less than the threshold are clustered together
+	knowledgeGapSimilarity = 0.5
+	knowledgeGapMaxSamples = 10
+)
+
+type KnowledgeGapUsecase struct {
+	repo        *pg.KnowledgeGapRepository
+	nodeUsecase *NodeUsecase
+	logger      *log.Logger
+}
+
+func NewKnowledgeGapUsecase(repo *pg.KnowledgeGapRepository, nodeUsecase *NodeUsecase, logger *log.Logger) *KnowledgeGapUsecase {
+	return &KnowledgeGapUsecase{
+		repo:        repo,
+		nodeUsecase: nodeUsecase,
+		logger:      logger.WithModule("usecase.knowledge_gap"),
+	}
+}
+
+// RecordUnanswered flags the answer if nothing was retrieved or the model refused to answer
+func (u *KnowledgeGapUsecase) RecordUnanswered(ctx context.Context, req *domain.ChatRequest, messageID, answer string, rankedNodes []*domain.RankedNodeChunks, refusalText string) error {
+	reason, ok := detectUnanswered(answer, rankedNodes, refusalText)
+	if !ok {
+		return nil
+	}
+	u.logger.Info("unanswered question", log.String("kb_id", req.KBID), log.String("question", req.Message), log.String("reason", string(reason)))
+	return u.repo.CreateUnansweredQuestion(ctx, &domain.UnansweredQuestion{
+		ID:             uuid.New().String(),
+		KBID:           req.KBID,
+		AppID:          req.AppID,
+		ConversationID: req.ConversationID,
+		MessageID:      messageID,
+		Question:       req.Message,
+		Reason:         reason,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// GetReport clusters the unanswered questions of the kb and ranks the clusters by frequency
+func (u *KnowledgeGapUsecase) GetReport(ctx context.Context, req *domain.KnowledgeGapReportReq) (*domain.KnowledgeGapReport, error) {
+	days := req.Days
+	if days <= 0 {
+		days = defaultKnowledgeGapDays
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	questions, err := u.repo.GetUnansweredQuestions(ctx, req.KBID, since, req.IncludeResolved)
+	if err != nil {
+		return nil, fmt.Errorf("get unanswered questions failed: %w", err)
+	}
+	return &domain.KnowledgeGapReport{
+		KBID:     req.KBID,
+		Since:    since,
+		Total:    len(questions),
+		Clusters: clusterQuestions(questions),
+	}, nil
+}
+
+// CreateDraftNode creates a private draft document pre-filled with the questions of a cluster
+func (u *KnowledgeGapUsecase) CreateDraftNode(ctx context.Context, req *domain.CreateGapDraftNodeReq) (string, error) {
+	questions, err := u.repo.GetUnansweredQuestionsByIDs(ctx, req.KBID, req.QuestionIDs)
+	if err != nil {
+		return "", fmt.Errorf("get unanswered questions failed: %w", err)
+	}
+	if len(questions) == 0 {
+		return "", fmt.Errorf("unanswered questions not found")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = questions[0].Question
+	}
+	content := strings.Builder{}
+	content.WriteString("<p>读者提出了以下问题，但知识库中没有找到答案：</p><ul>")
+	for _, question := range lo.Uniq(lo.Map(questions, func(q *domain.UnansweredQuestion, _ int) string { return q.Question })) {
+		content.WriteString(fmt.Sprintf("<li>%s</li>", html.EscapeString(question)))
+	}
+	content.WriteString("</ul>")
+
+	visibility := domain.NodeVisibilityPrivate
+	nodeID, err := u.nodeUsecase.Create(ctx, &domain.CreateNodeReq{
+		KBID:       req.KBID,
+		Type:       domain.NodeTypeDocument,
+		Name:       name,
+		Content:    content.String(),
+		Visibility: &visibility,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create draft node failed: %w", err)
+	}
+	ids := lo.Map(questions, func(q *domain.UnansweredQuestion, _ int) string { return q.ID })
+	if err := u.repo.ResolveUnansweredQuestions(ctx, req.KBID, ids, nodeID); err != nil {
+		return "", fmt.Errorf("resolve unanswered questions failed: %w", err)
+	}
+	return nodeID, nil
+}
+
+// detectUnanswered checks whether the answer is a refusal or nothing was retrieved
+func detectUnanswered(answer string, rankedNodes []*domain.RankedNodeChunks, refusalText string) (domain.UnansweredReason, bool) {
+	if len(rankedNodes) == 0 {
+		return domain.UnansweredReasonNoChunks, true
+	}
+	if endIndex := strings.Index(answer, "</think>"); endIndex != -1 {
+		answer = answer[endIndex+len("</think>"):]
+	}
+	refusal := strings.TrimRight(strings.TrimSpace(refusalText), "。.!！ ")
+	if refusal != "" && strings.Contains(strings.ToLower(answer), strings.ToLower(refusal)) {
+		return domain.UnansweredReasonRefusal, true
+	}
+	return "", false
+}
+
+type questionCluster struct {
+	tokens  map[string]struct{}
+	cluster *domain.KnowledgeGapCluster
+}
+
+// clusterQuestions greedily clusters the latest first questions by token similarity to the first question of each cluster
+func clusterQuestions(questions []*domain.UnansweredQuestion) []*domain.KnowledgeGapCluster {
+	clusters := make([]*questionCluster, 0)
+	for _, question := range questions {
+		tokens := questionTokens(question.Question)
+		var best *questionCluster
+		bestSimilarity := 0.0
+		for _, c := range clusters {
+			if similarity := diceSimilarity(tokens, c.tokens); similarity >= knowledgeGapSimilarity && similarity > bestSimilarity {
+				best, bestSimilarity = c, similarity
+			}
+		}
+		if best == nil {
+			best = &questionCluster{
+				tokens: tokens,
+				cluster: &domain.KnowledgeGapCluster{
+					Question:    question.Question,
+					QuestionIDs: make([]string, 0),
+					Samples:     make([]string, 0),
+					Reasons:     make(map[domain.UnansweredReason]int),
+					NodeIDs:     make([]string, 0),
+					LastAskedAt: question.CreatedAt,
+				},
+			}
+			clusters = append(clusters, best)
+		}
+		cluster := best.cluster
+		cluster.Count++
+		cluster.QuestionIDs = append(cluster.QuestionIDs, question.ID)
+		cluster.Reasons[question.Reason]++
+		if len(cluster.Samples) < knowledgeGapMaxSamples && !lo.Contains(cluster.Samples, question.Question) {
+			cluster.Samples = append(cluster.Samples, question.Question)
+		}
+		if question.NodeID != "" && !lo.Contains(cluster.NodeIDs, question.NodeID) {
+			cluster.NodeIDs = append(cluster.NodeIDs, question.NodeID)
+		}
+	}
+	result := lo.Map(clusters, func(c *questionCluster, _ int) *domain.KnowledgeGapCluster { return c.cluster })
+	// stable sort keeps the latest cluster first for the same count
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Count > result[j].Count
+	})
+	return result
+}
+
+func questionTokens(question string) map[string]struct{} {
+	tokens := make(map[string]struct{})
+	for _, token := range utils.SearchTokens(question) {
+		tokens[token] = struct{}{}
+	}
+	return tokens
+}
+
+func diceSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for token := range a {
+		if _, ok := b[token]; ok {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestDetectUnanswered(t *testing.T) {
+	nodes := []*domain.RankedNodeChunks{{NodeID: "a"}}
+	refusal := domain.DefaultRefusalText
+
+	if reason, ok := detectUnanswered("answer", nil, refusal); !ok || reason != domain.UnansweredReasonNoChunks {
+		t.Errorf("no chunks should be unanswered, got %v %v", reason, ok)
+	}
+	if reason, ok := detectUnanswered("<think>抱歉，我当前的知识不足以回答这个问题</think>\n答案如下", nodes, refusal); ok {
+		t.Errorf("refusal in reasoning should be ignored, got %v", reason)
+	}
+	if reason, ok := detectUnanswered("抱歉，我当前的知识不足以回答这个问题。", nodes, refusal); !ok || reason != domain.UnansweredReasonRefusal {
+		t.Errorf("refusal answer should be unanswered, got %v %v", reason, ok)
+	}
+	if _, ok := detectUnanswered("Sorry, I don't know", nodes, "sorry, I don't know."); !ok {
+		t.Errorf("refusal should be matched case insensitively")
+	}
+}
+
+func TestClusterQuestions(t *testing.T) {
+	now := time.Now()
+	questions := []*domain.UnansweredQuestion{
+		{ID: "1", Question: "如何配置 SSO 登录", Reason: domain.UnansweredReasonNoChunks, CreatedAt: now},
+		{ID: "2", Question: "价格是多少", Reason: domain.UnansweredReasonRefusal, CreatedAt: now.Add(-time.Minute)},
+		{ID: "3", Question: "SSO 登录怎么配置", Reason: domain.UnansweredReasonRefusal, CreatedAt: now.Add(-2 * time.Minute)},
+		{ID: "4", Question: "如何配置 SSO 登录", Reason: domain.UnansweredReasonNoChunks, CreatedAt: now.Add(-3 * time.Minute)},
+	}
+	clusters := clusterQuestions(questions)
+	if len(clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %d", len(clusters))
+	}
+	sso := clusters[0]
+	if sso.Count != 3 || sso.Question != "如何配置 SSO 登录" || len(sso.Samples) != 2 || !sso.LastAskedAt.Equal(now) {
+		t.Errorf("unexpected sso cluster %+v", sso)
+	}
+	if sso.Reasons[domain.UnansweredReasonNoChunks] != 2 || sso.Reasons[domain.UnansweredReasonRefusal] != 1 {
+		t.Errorf("unexpected reasons %v", sso.Reasons)
+	}
+	if clusters[1].Count != 1 || clusters[1].QuestionIDs[0] != "2" {
+		t.Errorf("unexpected price cluster %+v", clusters[1])
+	}
+}
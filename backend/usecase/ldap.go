@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+const ldapTimeout = 10 * time.Second
+
+var (
+	ErrLDAPUserNotFound       = errors.New("ldap user not found")
+	ErrLDAPInvalidCredentials = errors.New("invalid account or password")
+)
+
+type LDAPUsecase struct {
+	config      *config.Config
+	userUsecase *UserUsecase
+	logger      *log.Logger
+}
+
+func NewLDAPUsecase(config *config.Config, userUsecase *UserUsecase, logger *log.Logger) *LDAPUsecase {
+	return &LDAPUsecase{
+		config:      config,
+		userUsecase: userUsecase,
+		logger:      logger.WithModule("usecase.ldap"),
+	}
+}
+
+func (u *LDAPUsecase) Enabled() bool {
+	return u.config.Auth.Type == "ldap"
+}
+
+// Login binds the account against the directory, provisions the user and signs the jwt or starts the 2fa,
+// accounts missing from the directory fall back to the local login, e.g. the default admin
+func (u *LDAPUsecase) Login(ctx context.Context, req domain.LoginReq, client *domain.SessionClient) (*domain.LoginResp, error) {
+	external, err := newLDAPClient(u.config.Auth.LDAP).authenticate(req.Account, req.Password)
+	if errors.Is(err, ErrLDAPUserNotFound) {
+		return u.userUsecase.Login(ctx, req, client)
+	}
+	if err != nil {
+		u.logger.Warn("ldap login failed", log.String("account", req.Account), log.Error(err))
+		return nil, err
+	}
+	user, err := u.userUsecase.ProvisionExternalUser(ctx, external, u.config.Auth.LDAP.GroupRoles)
+	if err != nil {
+		return nil, err
+	}
+	return u.userUsecase.LoginUser(ctx, user, client)
+}
+
+type ldapClient struct {
+	cfg config.LDAPConfig
+}
+
+func newLDAPClient(cfg config.LDAPConfig) *ldapClient {
+	return &ldapClient{cfg: cfg}
+}
+
+func (c *ldapClient) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(c.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	conn, err := ldap.DialURL(c.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("dial ldap failed: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+	if c.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap start tls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// authenticate searches the user by the service account and binds as the user with the password
+func (c *ldapClient) authenticate(account, password string) (*domain.ExternalUser, error) {
+	// an empty password is an unauthenticated bind, which many servers accept
+	if account == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.cfg.BindDN != "" {
+		if err := conn.Bind(c.cfg.BindDN, c.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+	attributes := []string{"dn", "memberOf"}
+	if c.cfg.AccountAttr != "" {
+		attributes = append(attributes, c.cfg.AccountAttr)
+	}
+	filter := strings.ReplaceAll(c.cfg.UserFilter, "{account}", ldap.EscapeFilter(account))
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		filter, attributes, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrLDAPUserNotFound
+		}
+		return nil, fmt.Errorf("search ldap user failed: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrLDAPUserNotFound
+	case 1:
+	default:
+		return nil, fmt.Errorf("ldap user filter %s matches more than one entry", filter)
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind failed: %w", err)
+	}
+
+	groupDNs := entry.GetAttributeValues("memberOf")
+	if c.cfg.GroupBaseDN != "" && c.cfg.GroupFilter != "" {
+		// search as the service account again, the user may not read the groups
+		if c.cfg.BindDN != "" {
+			if err := conn.Bind(c.cfg.BindDN, c.cfg.BindPassword); err != nil {
+				return nil, fmt.Errorf("ldap service bind failed: %w", err)
+			}
+		}
+		groups, err := conn.Search(ldap.NewSearchRequest(
+			c.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false,
+			strings.ReplaceAll(c.cfg.GroupFilter, "{dn}", ldap.EscapeFilter(entry.DN)), []string{"dn"}, nil,
+		))
+		if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, fmt.Errorf("search ldap groups failed: %w", err)
+		}
+		if groups != nil {
+			for _, group := range groups.Entries {
+				if !slices.Contains(groupDNs, group.DN) {
+					groupDNs = append(groupDNs, group.DN)
+				}
+			}
+		}
+	}
+
+	if value := entry.GetAttributeValue(c.cfg.AccountAttr); c.cfg.AccountAttr != "" && value != "" {
+		account = value
+	}
+	return &domain.ExternalUser{
+		Source:     domain.UserSourceLDAP,
+		ExternalID: entry.DN,
+		Account:    account,
+		Groups:     groupNames(groupDNs),
+	}, nil
+}
+
+// groupNames the group roles match the dn or the cn of the groups
+func groupNames(groupDNs []string) []string {
+	names := make([]string, 0, len(groupDNs)*2)
+	for _, groupDN := range groupDNs {
+		names = append(names, groupDN)
+		dn, err := ldap.ParseDN(groupDN)
+		if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+			continue
+		}
+		if name := dn.RDNs[0].Attributes[0].Value; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
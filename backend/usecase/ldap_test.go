@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+
+	"github.com/chaitin/panda-wiki/config"
+)
+
+func TestLDAPClient(t *testing.T) {
+	// the users of the test directory have the password "password"
+	users := testdirectory.NewUsers(t, []string{"alice", "bob"}, testdirectory.WithMembersOf(t, "cn=wiki-admins,ou=groups,dc=example,dc=org"))
+	d := testdirectory.Start(t,
+		testdirectory.WithNoTLS(t),
+		testdirectory.WithDefaults(t, &testdirectory.Defaults{
+			AllowAnonymousBind: true,
+			Users:              users,
+			Groups:             []*gldap.Entry{testdirectory.NewGroup(t, "docs", []string{"alice"})},
+		}),
+	)
+	client := newLDAPClient(config.LDAPConfig{
+		URL:         fmt.Sprintf("ldap://%s:%d", d.Host(), d.Port()),
+		UserBaseDN:  "ou=people,dc=example,dc=org",
+		UserFilter:  "(cn={account})",
+		AccountAttr: "cn",
+		GroupBaseDN: "ou=groups,dc=example,dc=org",
+		GroupFilter: "(member={dn})",
+	})
+
+	user, err := client.authenticate("alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Account != "alice" || user.ExternalID != "cn=alice,ou=people,dc=example,dc=org" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	for _, group := range []string{"wiki-admins", "docs", "cn=docs,ou=groups,dc=example,dc=org"} {
+		if !slices.Contains(user.Groups, group) {
+			t.Errorf("group %s missing from %v", group, user.Groups)
+		}
+	}
+
+	if _, err := client.authenticate("alice", "wrong"); !errors.Is(err, ErrLDAPInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if _, err := client.authenticate("alice", ""); !errors.Is(err, ErrLDAPInvalidCredentials) {
+		t.Errorf("an empty password should never bind, got %v", err)
+	}
+	if _, err := client.authenticate("carol", "password"); !errors.Is(err, ErrLDAPUserNotFound) {
+		t.Errorf("expected user not found, got %v", err)
+	}
+}
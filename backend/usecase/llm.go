@@ -9,14 +9,11 @@ import (
 	"net/http"
 	"slices"
 	"strings"
-	"time"
 
 	"github.com/cloudwego/eino-ext/components/model/deepseek"
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
-	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/schema"
-	"github.com/samber/lo"
 
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/domain"
@@ -81,15 +78,22 @@ func (u *LLMUsecase) GetChatModel(ctx context.Context, model *domain.Model) (mod
 func (u *LLMUsecase) FormatConversationMessages(
 	ctx context.Context,
 	conversationID string,
+	messageID string,
+	history []*domain.ConversationMessage,
 	kbID string,
+	appPromptSettings *domain.PromptSettings,
+	models []*domain.Model,
+	rewriteUsage *schema.TokenUsage,
 ) ([]*schema.Message, []*domain.RankedNodeChunks, error) {
 	messages := make([]*schema.Message, 0)
 	rankedNodes := make([]*domain.RankedNodeChunks, 0)
 
-	msgs, err := u.conversationRepo.GetConversationMessagesByID(ctx, conversationID)
+	allMsgs, err := u.conversationRepo.GetConversationMessagesByID(ctx, conversationID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("get conversation messages failed: %w", err)
 	}
+	// history of the branch ending with the user message only, after the history sent by the client
+	msgs := append(slices.Clone(history), domain.ConversationBranch(allMsgs, messageID)...)
 	if len(msgs) > 0 {
 		historyMessages := make([]*schema.Message, 0)
 		for _, msg := range msgs {
@@ -105,61 +109,40 @@ func (u *LLMUsecase) FormatConversationMessages(
 		if len(historyMessages) > 0 {
 			question := historyMessages[len(historyMessages)-1].Content
 
-			template := prompt.FromMessages(schema.GoTemplate,
-				schema.SystemMessage(domain.SystemPrompt),
-				schema.UserMessage(domain.UserQuestionFormatter),
-			)
 			// query dataset id from kb
 			kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, kbID)
 			if err != nil {
 				return nil, nil, fmt.Errorf("get kb failed: %w", err)
 			}
-			// get related documents from raglite
-			records, err := u.rag.QueryRecords(ctx, []string{kb.DatasetID}, question)
-			if err != nil {
-				return nil, nil, fmt.Errorf("get records from raglite failed: %w", err)
-			}
-			u.logger.Info("get related documents from raglite", log.Any("record_count", len(records)))
-			rankedNodesMap := make(map[string]*domain.RankedNodeChunks)
-			// get raw node by doc_id
-			if len(records) > 0 {
-				docIDs := lo.Uniq(lo.Map(records, func(item *domain.NodeContentChunk, _ int) string {
-					return item.DocID
-				}))
-				u.logger.Info("docIDs", log.Any("docIDs", docIDs))
-				docIDNode, err := u.nodeRepo.GetNodeReleasesByDocIDs(ctx, docIDs)
+			// rewrite follow up question into standalone query
+			query := question
+			var subQueries []string
+			if settings := kb.RetrievalSettings; settings.QueryRewrite && (len(historyMessages) > 1 || settings.MaxSubQueries > 0) {
+				rewrite, err := u.RewriteQuery(ctx, models, historyMessages[:len(historyMessages)-1], question, settings.MaxSubQueries, rewriteUsage)
 				if err != nil {
-					return nil, nil, fmt.Errorf("get nodes by ids failed: %w", err)
-				}
-				u.logger.Info("get nodes by ids", log.Any("docIDNode", docIDNode))
-				for _, record := range records {
-					if nodeChunk, ok := rankedNodesMap[record.DocID]; !ok {
-						if docNode, ok := docIDNode[record.DocID]; ok {
-							rankNodeChunk := &domain.RankedNodeChunks{
-								NodeID:      docNode.NodeID,
-								NodeName:    docNode.Name,
-								NodeSummary: docNode.Meta.Summary,
-								Chunks:      []*domain.NodeContentChunk{record},
-							}
-							rankedNodes = append(rankedNodes, rankNodeChunk)
-							rankedNodesMap[record.DocID] = rankNodeChunk
+					u.logger.Error("rewrite query failed, use the original question", log.Error(err))
+				} else {
+					query, subQueries = rewrite.Query, rewrite.SubQueries
+					u.logger.Info("rewrite query", log.String("query", query), log.Any("sub_queries", subQueries))
+					if lastMsg := msgs[len(msgs)-1]; lastMsg.Role == schema.User {
+						if err := u.conversationRepo.UpdateMessageRewrittenQuery(ctx, lastMsg.ID, rewrite); err != nil {
+							u.logger.Error("save rewritten query failed", log.Error(err))
 						}
-					} else {
-						nodeChunk.Chunks = append(nodeChunk.Chunks, record)
 					}
 				}
 			}
+			// hybrid retrieval of related documents
+			result, err := u.Retrieve(ctx, kb, query, subQueries...)
+			if err != nil {
+				return nil, nil, err
+			}
+			promptSettings := kb.PromptSettings.Merge(appPromptSettings)
+			rankedNodes = promptSettings.LimitDocs(result.RankedNodes)
 			u.logger.Info("ranked nodes", log.Int("rankedNodesCount", len(rankedNodes)))
-			documents := domain.FormatNodeChunks(rankedNodes, kb.AccessSettings.BaseURL)
-			u.logger.Info("documents", log.String("documents", documents))
 
-			formattedMessages, err := template.Format(ctx, map[string]any{
-				"CurrentDate": time.Now().Format("2006-01-02"),
-				"Question":    question,
-				"Documents":   documents,
-			})
+			formattedMessages, err := u.FormatPromptMessages(ctx, kb, promptSettings, question, rankedNodes)
 			if err != nil {
-				return nil, nil, fmt.Errorf("format messages failed: %w", err)
+				return nil, nil, err
 			}
 			messages = slices.Insert(formattedMessages, 1, historyMessages[:len(historyMessages)-1]...)
 		}
@@ -167,6 +150,18 @@ func (u *LLMUsecase) FormatConversationMessages(
 	return messages, rankedNodes, nil
 }
 
+// FormatPromptMessages renders the system prompt and the user question with the retrieved documents
+func (u *LLMUsecase) FormatPromptMessages(ctx context.Context, kb *domain.KnowledgeBase, settings domain.PromptSettings, question string, rankedNodes []*domain.RankedNodeChunks) ([]*schema.Message, error) {
+	documents := domain.FormatNodeChunks(rankedNodes, kb.AccessSettings.BaseURL)
+	u.logger.Info("documents", log.String("documents", documents))
+
+	formattedMessages, err := settings.FormatMessages(ctx, settings.TemplateVars(kb.Name, question, documents))
+	if err != nil {
+		return nil, fmt.Errorf("format messages failed: %w", err)
+	}
+	return formattedMessages, nil
+}
+
 func (u *LLMUsecase) ChatWithAgent(
 	ctx context.Context,
 	chatModel model.BaseChatModel,
@@ -178,6 +173,7 @@ func (u *LLMUsecase) ChatWithAgent(
 	if err != nil {
 		return fmt.Errorf("stream failed: %w", err)
 	}
+	defer resp.Close()
 	firstReasoning := false
 	firstData := false
 
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/store/rag"
+)
+
+var ErrMCPAccessDenied = errors.New("the knowledge base requires the access password")
+
+// MCPUsecase read only access to the public released documents of the kb of the mcp server app
+type MCPUsecase struct {
+	rag      rag.RAGService
+	nodeRepo *pg.NodeRepository
+	kbRepo   *pg.KnowledgeBaseRepository
+	// passwords verified recently, the password is sent on every call
+	passwords *readerPasswordCache
+	logger    *log.Logger
+}
+
+func NewMCPUsecase(rag rag.RAGService, nodeRepo *pg.NodeRepository, kbRepo *pg.KnowledgeBaseRepository, logger *log.Logger) *MCPUsecase {
+	return &MCPUsecase{
+		rag:       rag,
+		nodeRepo:  nodeRepo,
+		kbRepo:    kbRepo,
+		passwords: newReaderPasswordCache(readerPasswordCacheTTL),
+		logger:    logger.WithModule("usecase.mcp"),
+	}
+}
+
+// SearchKB searches the chunks of the public documents by vector similarity
+func (u *MCPUsecase) SearchKB(ctx context.Context, app *domain.App, password, query string, topK int) ([]*domain.MCPSearchResult, error) {
+	kb, err := u.getKnowledgeBase(ctx, app, password)
+	if err != nil {
+		return nil, err
+	}
+	topK = min(max(topK, 1), domain.MCPMaxSearchTopK)
+	records, err := u.rag.QueryRecords(ctx, []string{kb.DatasetID}, query, topK)
+	if err != nil {
+		return nil, fmt.Errorf("query records failed: %w", err)
+	}
+	results := make([]*domain.MCPSearchResult, 0, len(records))
+	if len(records) == 0 {
+		return results, nil
+	}
+	docIDs := lo.Uniq(lo.Map(records, func(record *domain.NodeContentChunk, _ int) string { return record.DocID }))
+	// private documents are filtered out
+	docIDNode, err := u.nodeRepo.GetNodeReleasesByDocIDs(ctx, docIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get node releases failed: %w", err)
+	}
+	for _, record := range records {
+		node, ok := docIDNode[record.DocID]
+		if !ok || node.KBID != kb.ID {
+			continue
+		}
+		results = append(results, &domain.MCPSearchResult{
+			NodeID:   node.NodeID,
+			NodeName: node.Name,
+			URL:      nodeURL(kb, node.NodeID),
+			Content:  record.Content,
+		})
+	}
+	return results, nil
+}
+
+// ListNodes lists the public documents and folders of the latest release
+func (u *MCPUsecase) ListNodes(ctx context.Context, app *domain.App, password string) ([]*domain.MCPNode, error) {
+	kb, err := u.getKnowledgeBase(ctx, app, password)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := u.nodeRepo.GetNodeReleaseListByKBID(ctx, kb.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get node release list failed: %w", err)
+	}
+	return lo.Map(nodes, func(node *domain.ShareNodeListItemResp, _ int) *domain.MCPNode {
+		return &domain.MCPNode{
+			ID:       node.ID,
+			Name:     node.Name,
+			Type:     node.Type,
+			ParentID: node.ParentID,
+			Summary:  node.Summary,
+			URL:      nodeURL(kb, node.ID),
+		}
+	}), nil
+}
+
+// GetNode reads a public document of the latest release as markdown
+func (u *MCPUsecase) GetNode(ctx context.Context, app *domain.App, password, nodeID string) (*domain.MCPNodeDetail, error) {
+	kb, err := u.getKnowledgeBase(ctx, app, password)
+	if err != nil {
+		return nil, err
+	}
+	node, err := u.nodeRepo.GetNodeReleaseDetailByKBIDAndID(ctx, kb.ID, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("get node release detail failed: %w", err)
+	}
+	content := node.Content
+	if strings.HasPrefix(content, "<") {
+		if markdown, err := htmltomarkdown.ConvertString(content); err == nil {
+			content = markdown
+		}
+	}
+	return &domain.MCPNodeDetail{
+		ID:        node.ID,
+		Name:      node.Name,
+		URL:       nodeURL(kb, node.ID),
+		Content:   content,
+		UpdatedAt: node.UpdatedAt,
+	}, nil
+}
+
+// getKnowledgeBase gets the kb of the app, the access password is required if the readers are authenticated,
+// kbs only allowing the oidc or share link readers are not accessible by mcp
+func (u *MCPUsecase) getKnowledgeBase(ctx context.Context, app *domain.App, password string) (*domain.KnowledgeBase, error) {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, app.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	methods := kb.AccessSettings.ReaderAuthMethods()
+	if len(methods) == 0 || (slices.Contains(methods, domain.ReaderAuthMethodPassword) && u.passwords.Verified(kb, password, time.Now())) {
+		return kb, nil
+	}
+	if !slices.Contains(methods, domain.ReaderAuthMethodPassword) || !kb.AccessSettings.SimpleAuth.CheckPassword(password) {
+		return nil, ErrMCPAccessDenied
+	}
+	u.passwords.Add(kb, password, time.Now())
+	return kb, nil
+}
+
+func nodeURL(kb *domain.KnowledgeBase, nodeID string) string {
+	return fmt.Sprintf("%s/node/%s", kb.AccessSettings.BaseURL, nodeID)
+}
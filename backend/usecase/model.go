@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"slices"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -31,9 +32,10 @@ type ModelUsecase struct {
 	ragRepo   *mq.RAGRepository
 	ragStore  rag.RAGService
 	kbRepo    *pg.KnowledgeBaseRepository
+	audit     *AuditUsecase
 }
 
-func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, ragStore rag.RAGService, logger *log.Logger, config *config.Config, kbRepo *pg.KnowledgeBaseRepository) *ModelUsecase {
+func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, ragStore rag.RAGService, logger *log.Logger, config *config.Config, kbRepo *pg.KnowledgeBaseRepository, audit *AuditUsecase) *ModelUsecase {
 	u := &ModelUsecase{
 		modelRepo: modelRepo,
 		logger:    logger.WithModule("usecase.model"),
@@ -42,6 +44,7 @@ func NewModelUsecase(modelRepo *pg.ModelRepository, nodeRepo *pg.NodeRepository,
 		ragRepo:   ragRepo,
 		ragStore:  ragStore,
 		kbRepo:    kbRepo,
+		audit:     audit,
 	}
 	if err := u.initEmbeddingAndRerankModel(context.Background()); err != nil {
 		logger.Error("init embedding & rerank model failed", log.Any("error", err))
@@ -131,9 +134,6 @@ func (u *ModelUsecase) Create(ctx context.Context, model *domain.Model) error {
 			model.ID = id
 		}
 	}
-	if model.Type == domain.ModelTypeEmbedding {
-		return u.TriggerUpsertRecords(ctx)
-	}
 	return nil
 }
 
@@ -213,13 +213,82 @@ func (u *ModelUsecase) GetChatModel(ctx context.Context) (*domain.Model, error)
 	return u.modelRepo.GetChatModel(ctx)
 }
 
+// GetChatModels get the ordered chat models to fallback, the active chat model is used if no ids are bound
+func (u *ModelUsecase) GetChatModels(ctx context.Context, modelIDs []string) ([]*domain.Model, error) {
+	if len(modelIDs) > 0 {
+		models, err := u.modelRepo.GetChatModelsByIDs(ctx, modelIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(models) == 0 {
+			u.logger.Warn("bound chat models are all deleted", log.Any("model_ids", modelIDs))
+			return nil, domain.ErrBoundChatModelsDeleted
+		}
+		return models, nil
+	}
+	model, err := u.modelRepo.GetChatModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []*domain.Model{model}, nil
+}
+
+// checkChatModelIDs checks all the ids are chat models
+func checkChatModelIDs(ctx context.Context, modelRepo *pg.ModelRepository, modelIDs []string) error {
+	if len(modelIDs) == 0 {
+		return nil
+	}
+	models, err := modelRepo.GetChatModelsByIDs(ctx, modelIDs)
+	if err != nil {
+		return fmt.Errorf("get chat models failed: %w", err)
+	}
+	for _, id := range modelIDs {
+		if !slices.ContainsFunc(models, func(model *domain.Model) bool { return model.ID == id }) {
+			return fmt.Errorf("chat model %s not found", id)
+		}
+	}
+	return nil
+}
+
 func (u *ModelUsecase) UpdateUsage(ctx context.Context, modelID string, usage *schema.TokenUsage) error {
 	return u.modelRepo.UpdateUsage(ctx, modelID, usage)
 }
 
+func (u *ModelUsecase) UpdateCitationStats(ctx context.Context, modelID string, total, unsupported int) error {
+	if total == 0 {
+		return nil
+	}
+	return u.modelRepo.UpdateCitationStats(ctx, modelID, total, unsupported)
+}
+
 // ActivateModel activates a model and deactivates others of the same type
 func (u *ModelUsecase) ActivateModel(ctx context.Context, modelID string) error {
-	return u.modelRepo.ActivateModel(ctx, modelID)
+	model, err := u.modelRepo.Get(ctx, modelID)
+	if err != nil {
+		return fmt.Errorf("get model failed: %w", err)
+	}
+	active, err := u.modelRepo.GetActiveModel(ctx, model.Type)
+	if err != nil {
+		return fmt.Errorf("get active model failed: %w", err)
+	}
+	if err := u.modelRepo.ActivateModel(ctx, modelID); err != nil {
+		return err
+	}
+	before := map[string]any{"type": model.Type}
+	if active != nil {
+		before["active_model_id"] = active.ID
+		before["active_model"] = string(active.Provider) + "/" + active.Model
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionModelActivate,
+		TargetType: domain.AuditTargetModel,
+		TargetID:   modelID,
+	}, before, map[string]any{
+		"type":            model.Type,
+		"active_model_id": model.ID,
+		"active_model":    string(model.Provider) + "/" + model.Model,
+	})
+	return nil
 }
 
 func (u *ModelUsecase) GetUserModelList(ctx context.Context, req *domain.GetProviderModelListReq) (*domain.GetProviderModelListResp, error) {
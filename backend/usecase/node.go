@@ -26,11 +26,12 @@ type NodeUsecase struct {
 	kbRepo     *pg.KnowledgeBaseRepository
 	modelRepo  *pg.ModelRepository
 	llmUsecase *LLMUsecase
+	audit      *AuditUsecase
 	logger     *log.Logger
 	s3Client   *s3.MinioClient
 }
 
-func NewNodeUsecase(nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, kbRepo *pg.KnowledgeBaseRepository, llmUsecase *LLMUsecase, logger *log.Logger, s3Client *s3.MinioClient, modelRepo *pg.ModelRepository) *NodeUsecase {
+func NewNodeUsecase(nodeRepo *pg.NodeRepository, ragRepo *mq.RAGRepository, kbRepo *pg.KnowledgeBaseRepository, llmUsecase *LLMUsecase, audit *AuditUsecase, logger *log.Logger, s3Client *s3.MinioClient, modelRepo *pg.ModelRepository) *NodeUsecase {
 	return &NodeUsecase{
 		nodeRepo:   nodeRepo,
 		ragRepo:    ragRepo,
@@ -62,7 +63,38 @@ func (u *NodeUsecase) GetByID(ctx context.Context, id string) (*domain.NodeDetai
 	return u.nodeRepo.GetByID(ctx, id)
 }
 
+// NodeAction deletes the nodes or changes their visibility, recorded in the audit log per node
 func (u *NodeUsecase) NodeAction(ctx context.Context, req *domain.NodeActionReq) error {
+	nodes, err := u.nodeRepo.GetNodesByIDs(ctx, req.KBID, req.IDs)
+	if err != nil {
+		return fmt.Errorf("get nodes failed: %w", err)
+	}
+	if err := u.nodeAction(ctx, req); err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		before := map[string]any{"name": node.Name, "type": node.Type, "status": node.Status, "visibility": node.Visibility}
+		var after map[string]any
+		action := domain.AuditActionNodeDelete
+		switch req.Action {
+		case "private":
+			action = domain.AuditActionNodePrivate
+			after = map[string]any{"name": node.Name, "type": node.Type, "status": domain.NodeStatusDraft, "visibility": domain.NodeVisibilityPrivate}
+		case "public":
+			action = domain.AuditActionNodePublic
+			after = map[string]any{"name": node.Name, "type": node.Type, "status": domain.NodeStatusDraft, "visibility": domain.NodeVisibilityPublic}
+		}
+		u.audit.Record(ctx, &domain.AuditLog{
+			Action:     action,
+			TargetType: domain.AuditTargetNode,
+			TargetID:   node.ID,
+			KBID:       req.KBID,
+		}, before, after)
+	}
+	return nil
+}
+
+func (u *NodeUsecase) nodeAction(ctx context.Context, req *domain.NodeActionReq) error {
 	switch req.Action {
 	case "delete":
 		docIDs, err := u.nodeRepo.Delete(ctx, req.KBID, req.IDs)
@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrOIDCDisabled     = errors.New("oidc login is disabled")
+	ErrOIDCInvalidState = errors.New("oidc login state is invalid or expired")
+)
+
+// oidcReaderCallbackPath the callback of the reader login on the host of the published wiki
+const oidcReaderCallbackPath = "/share/v1/auth/oidc/callback"
+
+type OIDCUsecase struct {
+	config      *config.Config
+	userUsecase *UserUsecase
+	stateRepo   *cache.OIDCStateRepo
+	logger      *log.Logger
+
+	// the provider is discovered on the first login, the api starts even if the issuer is down
+	mu     sync.Mutex
+	client *oidcClient
+}
+
+func NewOIDCUsecase(config *config.Config, userUsecase *UserUsecase, stateRepo *cache.OIDCStateRepo, logger *log.Logger) *OIDCUsecase {
+	return &OIDCUsecase{
+		config:      config,
+		userUsecase: userUsecase,
+		stateRepo:   stateRepo,
+		logger:      logger.WithModule("usecase.oidc"),
+	}
+}
+
+func (u *OIDCUsecase) Enabled() bool {
+	return u.config.Auth.Type == "oidc"
+}
+
+// ReaderEnabled readers of the published wikis login by the provider once it is configured, whatever the console login is
+func (u *OIDCUsecase) ReaderEnabled() bool {
+	return u.config.Auth.OIDC.Issuer != "" && u.config.Auth.OIDC.ClientID != ""
+}
+
+// AuthURL starts the authorization code flow with pkce, redirect is the console path to return to after login
+func (u *OIDCUsecase) AuthURL(ctx context.Context, redirect string) (string, error) {
+	client, err := u.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	loginState := &domain.OIDCLoginState{
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Redirect:     safeRedirect(redirect),
+	}
+	if err := u.stateRepo.SetState(ctx, state, loginState, oidcStateTTL); err != nil {
+		return "", fmt.Errorf("save oidc state failed: %w", err)
+	}
+	return client.authURL(state, loginState), nil
+}
+
+// Login exchanges the code of the callback and provisions the user, returns the console path to redirect to,
+// the jwt is signed or the 2fa challenge is issued the same as the password login
+func (u *OIDCUsecase) Login(ctx context.Context, code, state string, sessionClient *domain.SessionClient) (*domain.LoginResp, string, error) {
+	client, err := u.getClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	loginState, err := u.stateRepo.PopState(ctx, state)
+	if err != nil {
+		return nil, "", fmt.Errorf("get oidc state failed: %w", err)
+	}
+	if loginState == nil {
+		return nil, "", ErrOIDCInvalidState
+	}
+	external, err := client.exchange(ctx, code, loginState)
+	if err != nil {
+		return nil, "", err
+	}
+	user, err := u.userUsecase.ProvisionExternalUser(ctx, external, u.config.Auth.OIDC.GroupRoles)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := u.userUsecase.LoginUser(ctx, user, sessionClient)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, loginState.Redirect, nil
+}
+
+// ReaderAuthURL starts the reader login of the kb, the callback is on the base url of the kb
+// which must be registered as a redirect uri of the client
+func (u *OIDCUsecase) ReaderAuthURL(ctx context.Context, kb *domain.KnowledgeBase, redirect string) (string, error) {
+	if !u.ReaderEnabled() {
+		return "", ErrOIDCDisabled
+	}
+	client, err := u.loadClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	callback, err := readerCallbackURL(kb)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	loginState := &domain.OIDCLoginState{
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Redirect:     safeRedirect(redirect),
+		KBID:         kb.ID,
+	}
+	if err := u.stateRepo.SetState(ctx, state, loginState, oidcStateTTL); err != nil {
+		return "", fmt.Errorf("save oidc state failed: %w", err)
+	}
+	return client.authURL(state, loginState, oauth2.SetAuthURLParam("redirect_uri", callback)), nil
+}
+
+// ReaderLogin exchanges the code of the reader callback, returns the reader and the wiki path to redirect to
+func (u *OIDCUsecase) ReaderLogin(ctx context.Context, kb *domain.KnowledgeBase, code, state string) (*domain.ExternalUser, string, error) {
+	if !u.ReaderEnabled() {
+		return nil, "", ErrOIDCDisabled
+	}
+	client, err := u.loadClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	loginState, err := u.stateRepo.PopState(ctx, state)
+	if err != nil {
+		return nil, "", fmt.Errorf("get oidc state failed: %w", err)
+	}
+	// the state of the console login or another kb is not accepted
+	if loginState == nil || loginState.KBID != kb.ID {
+		return nil, "", ErrOIDCInvalidState
+	}
+	callback, err := readerCallbackURL(kb)
+	if err != nil {
+		return nil, "", err
+	}
+	external, err := client.exchange(ctx, code, loginState, oauth2.SetAuthURLParam("redirect_uri", callback))
+	if err != nil {
+		return nil, "", err
+	}
+	return external, loginState.Redirect, nil
+}
+
+func readerCallbackURL(kb *domain.KnowledgeBase) (string, error) {
+	baseURL := strings.TrimSuffix(kb.AccessSettings.BaseURL, "/")
+	if baseURL == "" {
+		return "", fmt.Errorf("base url of the kb is required by the oidc reader login")
+	}
+	return baseURL + oidcReaderCallbackPath, nil
+}
+
+func (u *OIDCUsecase) getClient(ctx context.Context) (*oidcClient, error) {
+	if !u.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+	return u.loadClient(ctx)
+}
+
+func (u *OIDCUsecase) loadClient(ctx context.Context) (*oidcClient, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.client == nil {
+		client, err := newOIDCClient(ctx, u.config.Auth.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		u.client = client
+	}
+	return u.client, nil
+}
+
+type oidcClient struct {
+	oauth2       oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	accountClaim string
+	groupsClaim  string
+}
+
+func newOIDCClient(ctx context.Context, cfg config.OIDCConfig) (*oidcClient, error) {
+	// the provider keeps the context to fetch the keys later
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover oidc provider failed: %w", err)
+	}
+	return &oidcClient{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		accountClaim: cfg.AccountClaim,
+		groupsClaim:  cfg.GroupsClaim,
+	}, nil
+}
+
+func (c *oidcClient) authURL(state string, loginState *domain.OIDCLoginState, opts ...oauth2.AuthCodeOption) string {
+	opts = append(opts, oidc.Nonce(loginState.Nonce), oauth2.S256ChallengeOption(loginState.CodeVerifier))
+	return c.oauth2.AuthCodeURL(state, opts...)
+}
+
+func (c *oidcClient) exchange(ctx context.Context, code string, loginState *domain.OIDCLoginState, opts ...oauth2.AuthCodeOption) (*domain.ExternalUser, error) {
+	token, err := c.oauth2.Exchange(ctx, code, append(opts, oauth2.VerifierOption(loginState.CodeVerifier))...)
+	if err != nil {
+		return nil, fmt.Errorf("exchange oidc code failed: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("id token is missing")
+	}
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token failed: %w", err)
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return nil, fmt.Errorf("id token nonce mismatch")
+	}
+	claims := make(map[string]any)
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("parse id token claims failed: %w", err)
+	}
+	account := ""
+	for _, claim := range []string{c.accountClaim, "email", "sub"} {
+		if value, ok := claims[claim].(string); ok && value != "" {
+			account = value
+			break
+		}
+	}
+	// providers not sending email_verified are trusted, e.g. azure ad
+	email, _ := claims["email"].(string)
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		email = ""
+	}
+	return &domain.ExternalUser{
+		Source:     domain.UserSourceOIDC,
+		ExternalID: idToken.Issuer + "|" + idToken.Subject,
+		Account:    account,
+		Email:      email,
+		Groups:     stringsClaim(claims[c.groupsClaim]),
+	}, nil
+}
+
+// stringsClaim the groups claim is a list of strings or a single string
+func stringsClaim(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// safeRedirect only console paths are allowed to prevent open redirects
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random failed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+// mockIssuer a minimal oidc provider issuing id tokens for the code of the last authorization url
+type mockIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                m.URL,
+			"aud":                "panda-wiki",
+			"sub":                "u1",
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"nonce":              m.nonce,
+			"preferred_username": "alice",
+			"email":              "alice@example.com",
+			"email_verified":     true,
+			"groups":             []string{"wiki-admins", "docs"},
+		})
+		idToken.Header["kid"] = "test"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestOIDCClient(t *testing.T) {
+	ctx := context.Background()
+	issuer := newMockIssuer(t)
+	client, err := newOIDCClient(ctx, config.OIDCConfig{
+		Issuer:       issuer.URL,
+		ClientID:     "panda-wiki",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/v1/user/oidc/callback",
+		Scopes:       []string{"openid", "groups"},
+		AccountClaim: "preferred_username",
+		GroupsClaim:  "groups",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loginState := &domain.OIDCLoginState{Nonce: "nonce", CodeVerifier: oauth2.GenerateVerifier()}
+	authURL, err := url.Parse(client.authURL("state", loginState))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("state") != "state" || query.Get("code_challenge_method") != "S256" || query.Get("nonce") != "nonce" {
+		t.Fatalf("unexpected authorization url %s", authURL)
+	}
+	issuer.challenge, issuer.nonce = query.Get("code_challenge"), query.Get("nonce")
+
+	user, err := client.exchange(ctx, "code", loginState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Account != "alice" || user.Email != "alice@example.com" || user.ExternalID != issuer.URL+"|u1" || len(user.Groups) != 2 {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if _, err := client.exchange(ctx, "code", &domain.OIDCLoginState{Nonce: "nonce", CodeVerifier: oauth2.GenerateVerifier()}); err == nil {
+		t.Error("the code should not be exchanged without the verifier of the challenge")
+	}
+	if _, err := client.exchange(ctx, "code", &domain.OIDCLoginState{Nonce: "other", CodeVerifier: loginState.CodeVerifier}); err == nil {
+		t.Error("the id token of another nonce should be rejected")
+	}
+}
+
+func TestMapGroupRoles(t *testing.T) {
+	groupRoles := []config.GroupRoleConfig{
+		{Group: "wiki-admins", Role: "admin"},
+		{Group: "everyone", Role: "viewer"},
+		{Group: "docs", KBID: "kb1", Role: "editor"},
+		{Group: "docs-owners", KBID: "kb1", Role: "owner"},
+		{Group: "sales", KBID: "kb2", Role: "viewer"},
+	}
+	role, kbRoles := mapGroupRoles(groupRoles, []string{"everyone", "wiki-admins", "docs"})
+	if role != domain.RoleAdmin {
+		t.Errorf("expected the highest global role, got %q", role)
+	}
+	if kbRoles["kb1"] != domain.RoleEditor || kbRoles["kb2"] != "" || len(kbRoles) != 2 {
+		t.Errorf("unexpected kb roles %v", kbRoles)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	for redirect, expected := range map[string]string{
+		"":                 "/",
+		"/kb/1":            "/kb/1",
+		"//evil.com":       "/",
+		"/\\evil.com":      "/",
+		"https://evil.com": "/",
+	} {
+		if got := safeRedirect(redirect); got != expected {
+			t.Errorf("safeRedirect(%q) = %q, expected %q", redirect, got, expected)
+		}
+	}
+}
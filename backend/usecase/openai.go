@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+var (
+	ErrOpenAIModelNotFound = errors.New("model not found")
+	ErrOpenAINoQuestion    = errors.New("the last message must be a user message")
+)
+
+// OpenAIUsecase maps the openai compatible api to the knowledge base of the api app
+type OpenAIUsecase struct {
+	chatUsecase *ChatUsecase
+	kbRepo      *pg.KnowledgeBaseRepository
+	logger      *log.Logger
+}
+
+func NewOpenAIUsecase(chatUsecase *ChatUsecase, kbRepo *pg.KnowledgeBaseRepository, logger *log.Logger) *OpenAIUsecase {
+	return &OpenAIUsecase{
+		chatUsecase: chatUsecase,
+		kbRepo:      kbRepo,
+		logger:      logger.WithModule("usecase.openai"),
+	}
+}
+
+// ListModels the knowledge base of the app as the only model
+func (u *OpenAIUsecase) ListModels(ctx context.Context, app *domain.App) (*domain.OpenAIModelListResp, error) {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, app.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	return &domain.OpenAIModelListResp{
+		Object: domain.OpenAIObjectList,
+		Data: []*domain.OpenAIModel{
+			{
+				ID:      kb.ID,
+				Object:  domain.OpenAIObjectModel,
+				Created: kb.CreatedAt.Unix(),
+				OwnedBy: domain.OpenAIModelOwner,
+			},
+		},
+	}, nil
+}
+
+// ChatCompletions answers the last user message through the rag chat flow, earlier messages are the history
+func (u *OpenAIUsecase) ChatCompletions(ctx context.Context, app *domain.App, req *domain.OpenAIChatCompletionReq, remoteIP string) (<-chan domain.SSEEvent, error) {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, app.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	if req.Model != kb.ID && req.Model != kb.Name {
+		return nil, ErrOpenAIModelNotFound
+	}
+	question := req.Messages[len(req.Messages)-1]
+	if question.Role != string(schema.User) || question.Content == "" {
+		return nil, ErrOpenAINoQuestion
+	}
+	// the system prompt of the kb is used, system and tool messages are ignored
+	history := make([]*domain.ConversationMessage, 0)
+	for _, message := range req.Messages[:len(req.Messages)-1] {
+		switch role := schema.RoleType(message.Role); role {
+		case schema.User, schema.Assistant:
+			history = append(history, &domain.ConversationMessage{Role: role, Content: string(message.Content)})
+		}
+	}
+	return u.chatUsecase.Chat(ctx, &domain.ChatRequest{
+		Message:  string(question.Content),
+		AppType:  domain.AppTypeOpenAIAPI,
+		KBID:     app.KBID,
+		History:  history,
+		RemoteIP: remoteIP,
+	})
+}
@@ -30,4 +30,17 @@ var ProviderSet = wire.NewSet(
 	NewNotionUsecase,
 	NewEpubUsecase,
 	NewFileUsecase,
+	NewEvalUsecase,
+	NewQuotaUsecase,
+	NewKnowledgeGapUsecase,
+	NewAppAPIKeyUsecase,
+	NewOpenAIUsecase,
+	NewMCPUsecase,
+	NewAPITokenUsecase,
+	NewRBACUsecase,
+	NewOIDCUsecase,
+	NewLDAPUsecase,
+	NewSessionUsecase,
+	NewAuditUsecase,
+	NewReaderAuthUsecase,
 )
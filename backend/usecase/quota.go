@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+// quotaReservedCompletionTokens the completion tokens reserved before the chat, the chat models have no max tokens
+const quotaReservedCompletionTokens = 2048
+
+// TokenReservation the tokens taken from the daily budgets of the kb and the app before the chat
+type TokenReservation struct {
+	kbID   string
+	appID  string
+	tokens int64
+	day    time.Time
+}
+
+type QuotaUsecase struct {
+	quotaRepo *cache.QuotaRepo
+	appRepo   *pg.AppRepository
+	kbRepo    *pg.KnowledgeBaseRepository
+	config    *config.Config
+	logger    *log.Logger
+}
+
+func NewQuotaUsecase(quotaRepo *cache.QuotaRepo, appRepo *pg.AppRepository, kbRepo *pg.KnowledgeBaseRepository, config *config.Config, logger *log.Logger) *QuotaUsecase {
+	return &QuotaUsecase{
+		quotaRepo: quotaRepo,
+		appRepo:   appRepo,
+		kbRepo:    kbRepo,
+		config:    config,
+		logger:    logger.WithModule("usecase.quota"),
+	}
+}
+
+// CheckChatQuota counts the request and checks the quotas before chat, returns nil if no quota is exceeded
+func (u *QuotaUsecase) CheckChatQuota(ctx context.Context, kb *domain.KnowledgeBase, app *domain.App, remoteIP string) (*domain.QuotaExceeded, error) {
+	now := time.Now()
+	// requests per minute of the remote ip, bots have no remote ip
+	if limit := u.ipRequestsLimit(kb, app); limit > 0 && remoteIP != "" {
+		count, err := u.quotaRepo.IncrRequests(ctx, app.ID, remoteIP, now)
+		if err != nil {
+			return nil, fmt.Errorf("incr ip requests failed: %w", err)
+		}
+		if count > limit {
+			return &domain.QuotaExceeded{
+				Scope:      domain.QuotaScopeIP,
+				Limit:      limit,
+				Used:       count,
+				RetryAfter: 60 - now.Second(),
+			}, nil
+		}
+	}
+	// daily tokens of the app and the kb
+	retryAfter := int(time.Until(tomorrow(now)).Seconds())
+	if limit := u.appDailyTokensLimit(app); limit > 0 {
+		used, err := u.quotaRepo.GetDailyTokens(ctx, domain.QuotaScopeApp, app.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("get app daily tokens failed: %w", err)
+		}
+		if used >= limit {
+			return &domain.QuotaExceeded{Scope: domain.QuotaScopeApp, Limit: limit, Used: used, RetryAfter: retryAfter}, nil
+		}
+	}
+	if limit := u.kbDailyTokensLimit(kb); limit > 0 {
+		used, err := u.quotaRepo.GetDailyTokens(ctx, domain.QuotaScopeKB, kb.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("get kb daily tokens failed: %w", err)
+		}
+		if used >= limit {
+			return &domain.QuotaExceeded{Scope: domain.QuotaScopeKB, Limit: limit, Used: used, RetryAfter: retryAfter}, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddTokenUsage adds the consumed tokens of a chat to the daily budgets
+func (u *QuotaUsecase) AddTokenUsage(ctx context.Context, kbID, appID string, tokens int) error {
+	if tokens <= 0 {
+		return nil
+	}
+	return u.quotaRepo.IncrDailyTokens(ctx, kbID, appID, int64(tokens), time.Now())
+}
+
+// ReserveTokens takes the estimated tokens of a chat from the daily budgets before calling the model,
+// so parallel chats can not overshoot the limits, the reservation is settled by SettleTokens
+func (u *QuotaUsecase) ReserveTokens(ctx context.Context, kb *domain.KnowledgeBase, app *domain.App, tokens int) (*TokenReservation, *domain.QuotaExceeded, error) {
+	now := time.Now()
+	reservation := &TokenReservation{kbID: kb.ID, appID: app.ID, tokens: int64(tokens), day: now}
+	kbUsed, appUsed, err := u.quotaRepo.ReserveDailyTokens(ctx, kb.ID, app.ID, reservation.tokens, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reserve daily tokens failed: %w", err)
+	}
+	retryAfter := int(time.Until(tomorrow(now)).Seconds())
+	var exceeded *domain.QuotaExceeded
+	if limit := u.appDailyTokensLimit(app); limit > 0 && appUsed > limit {
+		exceeded = &domain.QuotaExceeded{Scope: domain.QuotaScopeApp, Limit: limit, Used: appUsed - reservation.tokens, RetryAfter: retryAfter}
+	} else if limit := u.kbDailyTokensLimit(kb); limit > 0 && kbUsed > limit {
+		exceeded = &domain.QuotaExceeded{Scope: domain.QuotaScopeKB, Limit: limit, Used: kbUsed - reservation.tokens, RetryAfter: retryAfter}
+	}
+	if exceeded != nil {
+		// the chat is rejected, give the tokens back
+		if err := u.quotaRepo.IncrDailyTokens(ctx, kb.ID, app.ID, -reservation.tokens, now); err != nil {
+			return nil, nil, fmt.Errorf("release daily tokens failed: %w", err)
+		}
+		return nil, exceeded, nil
+	}
+	return reservation, nil, nil
+}
+
+// SettleTokens replaces the reserved tokens with the consumed ones on the day of the reservation
+func (u *QuotaUsecase) SettleTokens(ctx context.Context, reservation *TokenReservation, tokens int) error {
+	delta := int64(tokens) - reservation.tokens
+	if delta == 0 {
+		return nil
+	}
+	return u.quotaRepo.IncrDailyTokens(ctx, reservation.kbID, reservation.appID, delta, reservation.day)
+}
+
+// GetQuotaUsage gets today's consumption of the kb and its apps
+func (u *QuotaUsecase) GetQuotaUsage(ctx context.Context, kbID string) (*domain.QuotaUsageResp, error) {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	apps, err := u.appRepo.GetAppsByKBID(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("get apps failed: %w", err)
+	}
+	now := time.Now()
+	resp := &domain.QuotaUsageResp{
+		KBID: kb.ID,
+		Date: now.Format("2006-01-02"),
+		Tokens: domain.QuotaUsage{
+			Limit: u.kbDailyTokensLimit(kb),
+		},
+		Apps: make([]*domain.AppQuotaUsage, 0, len(apps)),
+	}
+	if resp.Tokens.Used, err = u.quotaRepo.GetDailyTokens(ctx, domain.QuotaScopeKB, kb.ID, now); err != nil {
+		return nil, fmt.Errorf("get kb daily tokens failed: %w", err)
+	}
+	for _, app := range apps {
+		appUsage := &domain.AppQuotaUsage{
+			AppID:    app.ID,
+			Name:     app.Name,
+			Type:     app.Type,
+			Tokens:   domain.QuotaUsage{Limit: u.appDailyTokensLimit(app)},
+			Requests: domain.QuotaUsage{Limit: u.ipRequestsLimit(kb, app)},
+		}
+		if appUsage.Tokens.Used, err = u.quotaRepo.GetDailyTokens(ctx, domain.QuotaScopeApp, app.ID, now); err != nil {
+			return nil, fmt.Errorf("get app daily tokens failed: %w", err)
+		}
+		if appUsage.Requests.Used, err = u.quotaRepo.GetAppRequests(ctx, app.ID, now); err != nil {
+			return nil, fmt.Errorf("get app requests failed: %w", err)
+		}
+		resp.Apps = append(resp.Apps, appUsage)
+	}
+	return resp, nil
+}
+
+func (u *QuotaUsecase) ipRequestsLimit(kb *domain.KnowledgeBase, app *domain.App) int64 {
+	if app.Settings.QuotaSettings != nil && app.Settings.QuotaSettings.IPRequestsPerMinute != nil {
+		return int64(*app.Settings.QuotaSettings.IPRequestsPerMinute)
+	}
+	if kb.QuotaSettings.IPRequestsPerMinute != nil {
+		return int64(*kb.QuotaSettings.IPRequestsPerMinute)
+	}
+	return int64(u.config.Quota.IPRequestsPerMinute)
+}
+
+func (u *QuotaUsecase) appDailyTokensLimit(app *domain.App) int64 {
+	if app.Settings.QuotaSettings != nil && app.Settings.QuotaSettings.DailyTokens != nil {
+		return *app.Settings.QuotaSettings.DailyTokens
+	}
+	return u.config.Quota.AppDailyTokens
+}
+
+func (u *QuotaUsecase) kbDailyTokensLimit(kb *domain.KnowledgeBase) int64 {
+	if kb.QuotaSettings.DailyTokens != nil {
+		return *kb.QuotaSettings.DailyTokens
+	}
+	return u.config.Quota.KBDailyTokens
+}
+
+func tomorrow(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
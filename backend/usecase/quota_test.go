@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/config"
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/repo/cache"
+)
+
+func TestChatQuota(t *testing.T) {
+	c, _ := newTestCache(t)
+	quotaRepo := cache.NewQuotaRepo(c)
+	u := NewQuotaUsecase(quotaRepo, nil, nil, &config.Config{Quota: config.QuotaConfig{
+		IPRequestsPerMinute: 2,
+		AppDailyTokens:      100,
+		KBDailyTokens:       1000,
+	}}, newTestLogger())
+	ctx := context.Background()
+	kb := &domain.KnowledgeBase{ID: "kb"}
+	app := &domain.App{ID: "app", KBID: kb.ID}
+
+	// requests per minute of the ip
+	for i := range 3 {
+		exceeded, err := u.CheckChatQuota(ctx, kb, app, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 && exceeded != nil {
+			t.Errorf("request %d should be allowed, got %+v", i+1, exceeded)
+		}
+		if i == 2 && (exceeded == nil || exceeded.Scope != domain.QuotaScopeIP) {
+			t.Errorf("request %d should exceed the ip quota, got %+v", i+1, exceeded)
+		}
+	}
+
+	// daily tokens of the app
+	if err := u.AddTokenUsage(ctx, kb.ID, app.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	exceeded, err := u.CheckChatQuota(ctx, kb, app, "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exceeded == nil || exceeded.Scope != domain.QuotaScopeApp || exceeded.Used != 100 || exceeded.RetryAfter <= 0 {
+		t.Errorf("the app daily tokens should be exceeded, got %+v", exceeded)
+	}
+
+	// daily tokens of the kb, the app quota is raised by its settings
+	app.Settings.QuotaSettings = &domain.QuotaSettings{DailyTokens: lo.ToPtr(int64(10000))}
+	if err := u.AddTokenUsage(ctx, kb.ID, app.ID, 900); err != nil {
+		t.Fatal(err)
+	}
+	exceeded, err = u.CheckChatQuota(ctx, kb, app, "10.0.0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exceeded == nil || exceeded.Scope != domain.QuotaScopeKB || exceeded.Used != 1000 {
+		t.Errorf("the kb daily tokens should be exceeded, got %+v", exceeded)
+	}
+}
+
+func TestDailyTokensReset(t *testing.T) {
+	c, server := newTestCache(t)
+	quotaRepo := cache.NewQuotaRepo(c)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 23, 59, 0, 0, time.Local)
+
+	if err := quotaRepo.IncrDailyTokens(ctx, "kb", "app", 500, now); err != nil {
+		t.Fatal(err)
+	}
+	for scope, id := range map[domain.QuotaScope]string{domain.QuotaScopeKB: "kb", domain.QuotaScopeApp: "app"} {
+		if used, err := quotaRepo.GetDailyTokens(ctx, scope, id, now); err != nil || used != 500 {
+			t.Errorf("%s tokens today = %d, %v, expected 500", scope, used, err)
+		}
+		// a new budget the next day
+		if used, err := quotaRepo.GetDailyTokens(ctx, scope, id, tomorrow(now)); err != nil || used != 0 {
+			t.Errorf("%s tokens tomorrow = %d, %v, expected 0", scope, used, err)
+		}
+	}
+	// the counters of past days are not kept forever
+	for _, key := range server.Keys() {
+		if ttl := server.TTL(key); ttl <= 0 {
+			t.Errorf("counter %s has no ttl", key)
+		}
+	}
+	if got := tomorrow(now); !got.Equal(time.Date(2025, 6, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("tomorrow() = %v", got)
+	}
+}
+
+func TestReserveTokens(t *testing.T) {
+	c, _ := newTestCache(t)
+	quotaRepo := cache.NewQuotaRepo(c)
+	u := NewQuotaUsecase(quotaRepo, nil, nil, &config.Config{Quota: config.QuotaConfig{
+		AppDailyTokens: 1000,
+		KBDailyTokens:  10000,
+	}}, newTestLogger())
+	ctx := context.Background()
+	kb := &domain.KnowledgeBase{ID: "kb"}
+	app := &domain.App{ID: "app", KBID: kb.ID}
+	appTokens := func() int64 {
+		used, err := quotaRepo.GetDailyTokens(ctx, domain.QuotaScopeApp, app.ID, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return used
+	}
+
+	first, exceeded, err := u.ReserveTokens(ctx, kb, app, 600)
+	if err != nil || exceeded != nil {
+		t.Fatalf("the first reservation should be allowed, got %+v, %v", exceeded, err)
+	}
+	// a parallel chat can not take the reserved tokens
+	second, exceeded, err := u.ReserveTokens(ctx, kb, app, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != nil || exceeded == nil || exceeded.Scope != domain.QuotaScopeApp || exceeded.Used != 600 {
+		t.Errorf("the second reservation should exceed the app quota, got %+v", exceeded)
+	}
+	if used := appTokens(); used != 600 {
+		t.Errorf("app tokens = %d after the rejected reservation, expected 600", used)
+	}
+	// the unused tokens are given back after the chat
+	if err := u.SettleTokens(ctx, first, 200); err != nil {
+		t.Fatal(err)
+	}
+	if used := appTokens(); used != 200 {
+		t.Errorf("app tokens = %d after settled, expected 200", used)
+	}
+	if _, exceeded, err := u.ReserveTokens(ctx, kb, app, 600); err != nil || exceeded != nil {
+		t.Errorf("the reservation after settled should be allowed, got %+v, %v", exceeded, err)
+	}
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/pg"
+)
+
+var ErrRoleForbidden = errors.New("permission denied")
+
+type RBACUsecase struct {
+	userRepo   *pg.UserRepository
+	memberRepo *pg.KBMemberRepository
+	audit      *AuditUsecase
+	logger     *log.Logger
+}
+
+func NewRBACUsecase(userRepo *pg.UserRepository, memberRepo *pg.KBMemberRepository, audit *AuditUsecase, logger *log.Logger) *RBACUsecase {
+	return &RBACUsecase{
+		userRepo:   userRepo,
+		memberRepo: memberRepo,
+		audit:      audit,
+		logger:     logger.WithModule("usecase.rbac"),
+	}
+}
+
+// GetGlobalRole the role of the user in all kbs
+func (u *RBACUsecase) GetGlobalRole(ctx context.Context, userID string) (domain.Role, error) {
+	user, err := u.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("get user failed: %w", err)
+	}
+	return user.Role, nil
+}
+
+// GetKBRole the higher of the global role and the member role of the user in the kb
+func (u *RBACUsecase) GetKBRole(ctx context.Context, userID, kbID string) (domain.Role, error) {
+	role, err := u.GetGlobalRole(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	memberRole, err := u.memberRepo.GetMemberRole(ctx, kbID, userID)
+	if err != nil {
+		return "", fmt.Errorf("get member role failed: %w", err)
+	}
+	return role.Max(memberRole), nil
+}
+
+// HasAnyRole reports whether the user has the role globally or in any kb
+func (u *RBACUsecase) HasAnyRole(ctx context.Context, userID string, required domain.Role) (bool, error) {
+	role, err := u.GetGlobalRole(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if role.Allows(required) {
+		return true, nil
+	}
+	members, err := u.memberRepo.GetMembersByUserID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get members failed: %w", err)
+	}
+	for _, member := range members {
+		if member.Role.Allows(required) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetAccessibleKBIDs the kbs the user is a member of, all is true for users with a global role
+func (u *RBACUsecase) GetAccessibleKBIDs(ctx context.Context, userID string) (kbIDs map[string]bool, all bool, err error) {
+	role, err := u.GetGlobalRole(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if role.Allows(domain.RoleViewer) {
+		return nil, true, nil
+	}
+	members, err := u.memberRepo.GetMembersByUserID(ctx, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("get members failed: %w", err)
+	}
+	kbIDs = make(map[string]bool, len(members))
+	for _, member := range members {
+		kbIDs[member.KBID] = true
+	}
+	return kbIDs, false, nil
+}
+
+// ResolveKBID the kb of the resource of the id
+func (u *RBACUsecase) ResolveKBID(ctx context.Context, target domain.PermissionTarget, id string) (string, error) {
+	return u.memberRepo.GetResourceKBID(ctx, target, id)
+}
+
+func (u *RBACUsecase) GetMemberList(ctx context.Context, kbID string) ([]*domain.KBMemberListItem, error) {
+	return u.memberRepo.GetMemberList(ctx, kbID)
+}
+
+// UpsertMember grants the role of the kb, nobody grants a role above their own or changes a member above them
+func (u *RBACUsecase) UpsertMember(ctx context.Context, currentUserID string, req *domain.UpsertKBMemberReq) error {
+	if err := u.checkMemberManageable(ctx, currentUserID, req.KBID, req.UserID, req.Role); err != nil {
+		return err
+	}
+	user, err := u.userRepo.GetUser(ctx, req.UserID)
+	if err != nil {
+		return fmt.Errorf("get user failed: %w", err)
+	}
+	if user.ID == "" {
+		return fmt.Errorf("user %s not found", req.UserID)
+	}
+	role, err := u.memberRepo.GetMemberRole(ctx, req.KBID, req.UserID)
+	if err != nil {
+		return fmt.Errorf("get member role failed: %w", err)
+	}
+	if err := u.memberRepo.UpsertMember(ctx, &domain.KBMember{
+		KBID:      req.KBID,
+		UserID:    req.UserID,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+	u.recordMember(ctx, domain.AuditActionKBMemberUpsert, req.KBID, req.UserID, role, req.Role)
+	return nil
+}
+
+func (u *RBACUsecase) DeleteMember(ctx context.Context, currentUserID string, req *domain.DeleteKBMemberReq) error {
+	if err := u.checkMemberManageable(ctx, currentUserID, req.KBID, req.UserID, ""); err != nil {
+		return err
+	}
+	role, err := u.memberRepo.GetMemberRole(ctx, req.KBID, req.UserID)
+	if err != nil {
+		return fmt.Errorf("get member role failed: %w", err)
+	}
+	if err := u.memberRepo.DeleteMember(ctx, req.KBID, req.UserID); err != nil {
+		return err
+	}
+	u.recordMember(ctx, domain.AuditActionKBMemberDelete, req.KBID, req.UserID, role, "")
+	return nil
+}
+
+func (u *RBACUsecase) recordMember(ctx context.Context, action domain.AuditAction, kbID, userID string, before, after domain.Role) {
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     action,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+		KBID:       kbID,
+	}, map[string]any{"kb_role": before}, map[string]any{"kb_role": after})
+}
+
+// UpdateUserRole sets the global role of the user, owners can not change their own role to keep the instance manageable
+func (u *RBACUsecase) UpdateUserRole(ctx context.Context, currentUserID string, req *domain.UpdateUserRoleReq) error {
+	if currentUserID == req.UserID {
+		return fmt.Errorf("cannot change your own role")
+	}
+	role, err := u.GetGlobalRole(ctx, req.UserID)
+	if err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdateUserRole(ctx, req.UserID, req.Role); err != nil {
+		return err
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionUserUpdateRole,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   req.UserID,
+	}, map[string]any{"role": role}, map[string]any{"role": req.Role})
+	return nil
+}
+
+// CheckUserManageable global admins manage users not above their own global role
+func (u *RBACUsecase) CheckUserManageable(ctx context.Context, currentUserID, userID string) error {
+	role, err := u.GetGlobalRole(ctx, currentUserID)
+	if err != nil {
+		return err
+	}
+	userRole, err := u.GetGlobalRole(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !role.Allows(domain.RoleAdmin) || (userRole != "" && !role.Allows(userRole)) {
+		return ErrRoleForbidden
+	}
+	return nil
+}
+
+func (u *RBACUsecase) checkMemberManageable(ctx context.Context, currentUserID, kbID, userID string, role domain.Role) error {
+	currentRole, err := u.GetKBRole(ctx, currentUserID, kbID)
+	if err != nil {
+		return err
+	}
+	if role != "" && !currentRole.Allows(role) {
+		return ErrRoleForbidden
+	}
+	memberRole, err := u.memberRepo.GetMemberRole(ctx, kbID, userID)
+	if err != nil {
+		return fmt.Errorf("get member role failed: %w", err)
+	}
+	if memberRole != "" && !currentRole.Allows(memberRole) {
+		return ErrRoleForbidden
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
+)
+
+const (
+	readerSessionTTL = 7 * 24 * time.Hour
+	// readerLoginFailureWindow the password of a kb is locked for the ip after too many failures in the window
+	readerLoginFailureWindow = 10 * time.Minute
+	readerMaxLoginFailures   = 10
+	// readerPasswordCacheTTL the password header of the api clients is verified by bcrypt once in the ttl
+	readerPasswordCacheTTL = time.Minute
+
+	shareLinkPath = "/share/v1/auth/share_link"
+)
+
+var (
+	ErrReaderAuthMethodDisabled = errors.New("the reader auth method is disabled")
+	ErrReaderInvalidPassword    = errors.New("invalid password")
+	ErrReaderLoginLocked        = errors.New("too many failed logins, try again later")
+	ErrReaderEmailNotAllowed    = errors.New("the email is not allowed to read the wiki")
+	ErrShareLinkInvalid         = errors.New("share link is invalid or expired")
+)
+
+// ReaderAuthUsecase authentication of the readers of the published wikis by the methods of the access settings
+type ReaderAuthUsecase struct {
+	kbUsecase   *KnowledgeBaseUsecase
+	oidc        *OIDCUsecase
+	sessionRepo *cache.ReaderSessionRepo
+	passwords   *readerPasswordCache
+	logger      *log.Logger
+}
+
+func NewReaderAuthUsecase(kbUsecase *KnowledgeBaseUsecase, oidc *OIDCUsecase, sessionRepo *cache.ReaderSessionRepo, logger *log.Logger) *ReaderAuthUsecase {
+	return &ReaderAuthUsecase{
+		kbUsecase:   kbUsecase,
+		oidc:        oidc,
+		sessionRepo: sessionRepo,
+		passwords:   newReaderPasswordCache(readerPasswordCacheTTL),
+		logger:      logger.WithModule("usecase.reader_auth"),
+	}
+}
+
+// Authorize whether the reader can read the kb by the session of the cookie,
+// or by the password header of the api clients
+func (u *ReaderAuthUsecase) Authorize(ctx context.Context, kb *domain.KnowledgeBase, sessionID, password, ip string) (bool, error) {
+	methods := u.methods(kb)
+	if len(methods) == 0 {
+		return true, nil
+	}
+	if sessionID != "" {
+		session, err := u.sessionRepo.GetSession(ctx, sessionID)
+		if err != nil {
+			return false, fmt.Errorf("get reader session failed: %w", err)
+		}
+		// sessions of a method disabled later are rejected
+		if session != nil && session.KBID == kb.ID && slices.Contains(methods, session.Method) {
+			return true, nil
+		}
+	}
+	if password != "" && slices.Contains(methods, domain.ReaderAuthMethodPassword) {
+		if u.passwords.Verified(kb, password, time.Now()) {
+			return true, nil
+		}
+		err := u.checkPassword(ctx, kb, password, ip)
+		if errors.Is(err, ErrReaderInvalidPassword) || errors.Is(err, ErrReaderLoginLocked) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		u.passwords.Add(kb, password, time.Now())
+		return true, nil
+	}
+	return false, nil
+}
+
+func (u *ReaderAuthUsecase) GetAuthInfo(ctx context.Context, kbID, sessionID, ip string) (*domain.ReaderAuthInfoResp, error) {
+	kb, err := u.kbUsecase.GetKnowledgeBase(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	authenticated, err := u.Authorize(ctx, kb, sessionID, "", ip)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ReaderAuthInfoResp{
+		Methods:       u.methods(kb),
+		Authenticated: authenticated,
+	}, nil
+}
+
+func (u *ReaderAuthUsecase) LoginPassword(ctx context.Context, kbID, password, ip string) (*domain.ReaderSession, error) {
+	kb, err := u.getKnowledgeBase(ctx, kbID, domain.ReaderAuthMethodPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkPassword(ctx, kb, password, ip); err != nil {
+		return nil, err
+	}
+	return u.createSession(ctx, &domain.ReaderSession{
+		KBID:      kb.ID,
+		Method:    domain.ReaderAuthMethodPassword,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(readerSessionTTL),
+	})
+}
+
+// OIDCAuthURL starts the oidc login of the reader, redirect is the wiki path to return to
+func (u *ReaderAuthUsecase) OIDCAuthURL(ctx context.Context, kbID, redirect string) (string, error) {
+	kb, err := u.getKnowledgeBase(ctx, kbID, domain.ReaderAuthMethodOIDC)
+	if err != nil {
+		return "", err
+	}
+	return u.oidc.ReaderAuthURL(ctx, kb, redirect)
+}
+
+// LoginOIDC only verified emails of the allowed domains get a session, returns the wiki path to redirect to
+func (u *ReaderAuthUsecase) LoginOIDC(ctx context.Context, kbID, code, state, ip string) (*domain.ReaderSession, string, error) {
+	kb, err := u.getKnowledgeBase(ctx, kbID, domain.ReaderAuthMethodOIDC)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, redirect, err := u.oidc.ReaderLogin(ctx, kb, code, state)
+	if err != nil {
+		return nil, "", err
+	}
+	if reader.Email == "" || !kb.AccessSettings.ReaderAuth.OIDC.AllowsEmail(reader.Email) {
+		u.logger.Warn("reader email not allowed", log.String("kb_id", kb.ID), log.String("email", reader.Email))
+		return nil, "", ErrReaderEmailNotAllowed
+	}
+	session, err := u.createSession(ctx, &domain.ReaderSession{
+		KBID:      kb.ID,
+		Method:    domain.ReaderAuthMethodOIDC,
+		Email:     reader.Email,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(readerSessionTTL),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, redirect, nil
+}
+
+// LoginShareLink the session of a share link expires with the link, returns the wiki path to redirect to
+func (u *ReaderAuthUsecase) LoginShareLink(ctx context.Context, kbID, token, redirect, ip string) (*domain.ReaderSession, string, error) {
+	kb, err := u.getKnowledgeBase(ctx, kbID, domain.ReaderAuthMethodShareLink)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := verifyShareLink(kb.AccessSettings.ReaderAuth.ShareLink.Secret, token, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	if claims.KBID != kb.ID {
+		return nil, "", ErrShareLinkInvalid
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if maxExpiresAt := time.Now().Add(readerSessionTTL); expiresAt.After(maxExpiresAt) {
+		expiresAt = maxExpiresAt
+	}
+	session, err := u.createSession(ctx, &domain.ReaderSession{
+		KBID:      kb.ID,
+		Method:    domain.ReaderAuthMethodShareLink,
+		IP:        ip,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, safeRedirect(redirect), nil
+}
+
+func (u *ReaderAuthUsecase) Logout(ctx context.Context, sessionID string) error {
+	session, err := u.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("get reader session failed: %w", err)
+	}
+	if session == nil {
+		return nil
+	}
+	return u.sessionRepo.DeleteSession(ctx, session)
+}
+
+// CreateShareLink signs a link of the kb valid until it expires or the links are revoked
+func (u *ReaderAuthUsecase) CreateShareLink(ctx context.Context, req *domain.CreateShareLinkReq) (*domain.ShareLinkResp, error) {
+	kb, err := u.kbUsecase.GetKnowledgeBase(ctx, req.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	shareLink := kb.AccessSettings.ReaderAuth.ShareLink
+	if !shareLink.Enabled || shareLink.Secret == "" {
+		return nil, ErrReaderAuthMethodDisabled
+	}
+	baseURL := strings.TrimSuffix(kb.AccessSettings.BaseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("base url of the kb is required by the share links")
+	}
+	expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	token, err := signShareLink(shareLink.Secret, &domain.ShareLinkClaims{KBID: kb.ID, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"token": {token}, "redirect": {safeRedirect(req.Redirect)}}
+	return &domain.ShareLinkResp{
+		URL:       baseURL + shareLinkPath + "?" + query.Encode(),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// methods the enabled methods of the kb, the oidc method also requires the provider
+func (u *ReaderAuthUsecase) methods(kb *domain.KnowledgeBase) []domain.ReaderAuthMethod {
+	methods := kb.AccessSettings.ReaderAuthMethods()
+	if !u.oidc.ReaderEnabled() {
+		methods = slices.DeleteFunc(methods, func(method domain.ReaderAuthMethod) bool {
+			return method == domain.ReaderAuthMethodOIDC
+		})
+	}
+	return methods
+}
+
+func (u *ReaderAuthUsecase) getKnowledgeBase(ctx context.Context, kbID string, method domain.ReaderAuthMethod) (*domain.KnowledgeBase, error) {
+	kb, err := u.kbUsecase.GetKnowledgeBase(ctx, kbID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	if !slices.Contains(u.methods(kb), method) {
+		return nil, ErrReaderAuthMethodDisabled
+	}
+	return kb, nil
+}
+
+func (u *ReaderAuthUsecase) checkPassword(ctx context.Context, kb *domain.KnowledgeBase, password, ip string) error {
+	failures, err := u.sessionRepo.GetLoginFailures(ctx, kb.ID, ip)
+	if err != nil {
+		return fmt.Errorf("get login failures failed: %w", err)
+	}
+	if failures >= readerMaxLoginFailures {
+		return ErrReaderLoginLocked
+	}
+	if kb.AccessSettings.SimpleAuth.CheckPassword(password) {
+		return nil
+	}
+	if _, err := u.sessionRepo.IncrLoginFailures(ctx, kb.ID, ip, readerLoginFailureWindow); err != nil {
+		return fmt.Errorf("count login failures failed: %w", err)
+	}
+	u.logger.Warn("reader password auth failed", log.String("kb_id", kb.ID), log.String("ip", ip))
+	return ErrReaderInvalidPassword
+}
+
+func (u *ReaderAuthUsecase) createSession(ctx context.Context, session *domain.ReaderSession) (*domain.ReaderSession, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = id
+	session.CreatedAt = time.Now()
+	if err := u.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("create reader session failed: %w", err)
+	}
+	return session, nil
+}
+
+// signShareLink the token is the base64 json of the claims and its hmac-sha256 by the secret of the kb
+func signShareLink(secret string, claims *domain.ShareLinkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(shareLinkMAC(secret, encoded)), nil
+}
+
+func verifyShareLink(secret, token string, now time.Time) (*domain.ShareLinkClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return nil, ErrShareLinkInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, shareLinkMAC(secret, encoded)) {
+		return nil, ErrShareLinkInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+	var claims domain.ShareLinkClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrShareLinkInvalid
+	}
+	return &claims, nil
+}
+
+func shareLinkMAC(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// readerPasswordCache the passwords verified recently, keyed by the hmac of the kb id and the password
+// by a random key of the process, the entry is valid only for the password hash it was verified against
+type readerPasswordCache struct {
+	key     []byte
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]verifiedReaderPassword
+}
+
+type verifiedReaderPassword struct {
+	passwordHash string
+	expiresAt    time.Time
+}
+
+func newReaderPasswordCache(ttl time.Duration) *readerPasswordCache {
+	key := make([]byte, 32)
+	// never fails, see crypto/rand.Read
+	_, _ = rand.Read(key)
+	return &readerPasswordCache{
+		key:     key,
+		ttl:     ttl,
+		entries: make(map[string]verifiedReaderPassword),
+	}
+}
+
+func (c *readerPasswordCache) cacheKey(kbID, password string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(kbID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return string(mac.Sum(nil))
+}
+
+func (c *readerPasswordCache) Verified(kb *domain.KnowledgeBase, password string, now time.Time) bool {
+	key := c.cacheKey(kb.ID, password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if !now.Before(entry.expiresAt) || entry.passwordHash != kb.AccessSettings.SimpleAuth.PasswordHash {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+func (c *readerPasswordCache) Add(kb *domain.KnowledgeBase, password string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[c.cacheKey(kb.ID, password)] = verifiedReaderPassword{
+		passwordHash: kb.AccessSettings.SimpleAuth.PasswordHash,
+		expiresAt:    now.Add(c.ttl),
+	}
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chaitin/panda-wiki/domain"
+)
+
+func TestShareLink(t *testing.T) {
+	now := time.Now()
+	claims := &domain.ShareLinkClaims{KBID: "kb", ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := signShareLink("secret", claims)
+	if err != nil {
+		t.Fatalf("signShareLink() error = %v", err)
+	}
+	got, err := verifyShareLink("secret", token, now)
+	if err != nil {
+		t.Fatalf("verifyShareLink() error = %v", err)
+	}
+	if *got != *claims {
+		t.Errorf("verifyShareLink() = %+v, expected %+v", got, claims)
+	}
+
+	other, err := signShareLink("secret", &domain.ShareLinkClaims{KBID: "other", ExpiresAt: claims.ExpiresAt})
+	if err != nil {
+		t.Fatalf("signShareLink() error = %v", err)
+	}
+	for name, tc := range map[string]struct {
+		secret string
+		token  string
+		now    time.Time
+	}{
+		"wrong secret": {secret: "revoked", token: token, now: now},
+		"no secret":    {secret: "", token: token, now: now},
+		"expired":      {secret: "secret", token: token, now: now.Add(time.Hour)},
+		"tampered":     {secret: "secret", token: other[:len(other)/2] + token[len(token)/2:], now: now},
+		"malformed":    {secret: "secret", token: "token", now: now},
+	} {
+		if _, err := verifyShareLink(tc.secret, tc.token, tc.now); !errors.Is(err, ErrShareLinkInvalid) {
+			t.Errorf("verifyShareLink() of %s error = %v, expected %v", name, err, ErrShareLinkInvalid)
+		}
+	}
+}
+
+func TestPrepareReaderAuth(t *testing.T) {
+	current := &domain.AccessSettings{}
+	settings := &domain.AccessSettings{
+		SimpleAuth: domain.SimpleAuth{Enabled: true, Password: "secret"},
+		ReaderAuth: domain.ReaderAuth{ShareLink: domain.ShareLinkAuth{Enabled: true}},
+	}
+	if err := prepareReaderAuth(current, settings); err != nil {
+		t.Fatalf("prepareReaderAuth() error = %v", err)
+	}
+	if settings.SimpleAuth.Password != "" || !settings.SimpleAuth.CheckPassword("secret") {
+		t.Errorf("prepareReaderAuth() should keep only the hash of the password, got %+v", settings.SimpleAuth)
+	}
+	if settings.ReaderAuth.ShareLink.Secret == "" {
+		t.Errorf("prepareReaderAuth() should generate the share link secret")
+	}
+
+	// the hash and the secret are kept when not changed
+	updated := &domain.AccessSettings{
+		SimpleAuth: domain.SimpleAuth{Enabled: true},
+		ReaderAuth: domain.ReaderAuth{ShareLink: domain.ShareLinkAuth{Enabled: true, Secret: "forged"}},
+	}
+	if err := prepareReaderAuth(settings, updated); err != nil {
+		t.Fatalf("prepareReaderAuth() error = %v", err)
+	}
+	if updated.SimpleAuth.PasswordHash != settings.SimpleAuth.PasswordHash {
+		t.Errorf("prepareReaderAuth() should keep the password hash")
+	}
+	if updated.ReaderAuth.ShareLink.Secret != settings.ReaderAuth.ShareLink.Secret {
+		t.Errorf("prepareReaderAuth() should keep the share link secret")
+	}
+
+	if err := prepareReaderAuth(current, &domain.AccessSettings{SimpleAuth: domain.SimpleAuth{Enabled: true}}); err == nil {
+		t.Errorf("prepareReaderAuth() should require the password of simple auth")
+	}
+	if err := prepareReaderAuth(current, &domain.AccessSettings{ReaderAuth: domain.ReaderAuth{OIDC: domain.OIDCReaderAuth{Enabled: true}}}); err == nil {
+		t.Errorf("prepareReaderAuth() should require the email domains of oidc")
+	}
+}
+
+func TestReaderPasswordCache(t *testing.T) {
+	kb := &domain.KnowledgeBase{ID: "kb", AccessSettings: domain.AccessSettings{
+		SimpleAuth: domain.SimpleAuth{Enabled: true, PasswordHash: "hash"},
+	}}
+	now := time.Now()
+	c := newReaderPasswordCache(time.Minute)
+	if c.Verified(kb, "secret", now) {
+		t.Fatalf("Verified() should be false before the password is added")
+	}
+	c.Add(kb, "secret", now)
+	if !c.Verified(kb, "secret", now.Add(30*time.Second)) {
+		t.Errorf("Verified() should be true in the ttl")
+	}
+	if c.Verified(kb, "guess", now) {
+		t.Errorf("Verified() should be false for another password")
+	}
+	if c.Verified(&domain.KnowledgeBase{ID: "other", AccessSettings: kb.AccessSettings}, "secret", now) {
+		t.Errorf("Verified() should be false for another kb")
+	}
+	if c.Verified(kb, "secret", now.Add(time.Minute)) {
+		t.Errorf("Verified() should be false after the ttl")
+	}
+
+	// the password is changed
+	c.Add(kb, "secret", now)
+	changed := *kb
+	changed.AccessSettings.SimpleAuth.PasswordHash = "new hash"
+	if c.Verified(&changed, "secret", now) {
+		t.Errorf("Verified() should be false after the password is changed")
+	}
+}
@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+var rerankClient = &http.Client{Timeout: 30 * time.Second}
+
+type rerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	ReturnDocuments bool     `json:"return_documents"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// Rerank scores documents against the query with the rerank model, scores are in the order of documents
+func (u *LLMUsecase) Rerank(ctx context.Context, model *domain.Model, query string, documents []string) ([]float64, error) {
+	body, err := json.Marshal(rerankRequest{
+		Model:     model.Model,
+		Query:     query,
+		Documents: documents,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal rerank request failed: %w", err)
+	}
+	url := strings.TrimRight(model.BaseURL, "/") + "/rerank"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new rerank request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if model.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+model.APIKey)
+	}
+	for k, v := range utils.GetHeaderMap(model.APIHeader) {
+		req.Header.Set(k, v)
+	}
+	resp, err := rerankClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send rerank request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("rerank request failed: %s %s", resp.Status, string(msg))
+	}
+	var result rerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode rerank response failed: %w", err)
+	}
+	if len(result.Results) != len(documents) {
+		return nil, fmt.Errorf("rerank count mismatch: want %d, got %d", len(documents), len(result.Results))
+	}
+	scores := make([]float64, len(documents))
+	for _, item := range result.Results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			return nil, fmt.Errorf("invalid rerank result index %d", item.Index)
+		}
+		scores[item.Index] = item.RelevanceScore
+	}
+	return scores, nil
+}
+
+// rerankNodes rescores all chunks with the rerank model, the order is kept if no rerank model is configured or rerank fails
+func (u *LLMUsecase) rerankNodes(ctx context.Context, question string, rankedNodes []*domain.RankedNodeChunks, threshold *float64) []*domain.RankedNodeChunks {
+	if len(rankedNodes) == 0 {
+		return rankedNodes
+	}
+	model, err := u.modelRepo.GetRerankModel(ctx)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			u.logger.Error("get rerank model failed", log.Error(err))
+		}
+		return rankedNodes
+	}
+	documents := make([]string, 0)
+	for _, node := range rankedNodes {
+		for _, chunk := range node.Chunks {
+			documents = append(documents, node.NodeName+"\n"+chunk.Content)
+		}
+	}
+	scores, err := u.Rerank(ctx, model, question, documents)
+	if err != nil {
+		u.logger.Error("rerank chunks failed, keep retrieval order", log.Error(err))
+		return rankedNodes
+	}
+	return applyRerankScores(rankedNodes, scores, threshold)
+}
+
+// applyRerankScores sets rerank scores of chunks in order, drops chunks below the threshold
+// and sorts nodes by their best chunk score
+func applyRerankScores(rankedNodes []*domain.RankedNodeChunks, scores []float64, threshold *float64) []*domain.RankedNodeChunks {
+	result := make([]*domain.RankedNodeChunks, 0, len(rankedNodes))
+	i := 0
+	for _, node := range rankedNodes {
+		chunks := make([]*domain.NodeContentChunk, 0, len(node.Chunks))
+		best := 0.0
+		for _, chunk := range node.Chunks {
+			score := scores[i]
+			i++
+			chunk.RerankScore = &score
+			if threshold != nil && score < *threshold {
+				continue
+			}
+			if len(chunks) == 0 || score > best {
+				best = score
+			}
+			chunks = append(chunks, chunk)
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+		node.Chunks = chunks
+		node.Score = best
+		result = append(result, node)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/utils"
+)
+
+// max runes of the excerpt built for a keyword hit
+const keywordExcerptSize = 600
+
+// queryHits vector and keyword hits of one query
+type queryHits struct {
+	records        []*domain.NodeContentChunk
+	keywordResults []*domain.KeywordSearchResult
+}
+
+// Retrieve hybrid retrieval: vector search on rag chunks and full text search on node releases
+// for the query and every sub query, all rankings are fused by weighted reciprocal rank and then reranked
+func (u *LLMUsecase) Retrieve(ctx context.Context, kb *domain.KnowledgeBase, query string, subQueries ...string) (*domain.RetrievalResult, error) {
+	settings := kb.RetrievalSettings
+	queries := lo.Uniq(append([]string{query}, subQueries...))
+
+	hits := make([]*queryHits, 0, len(queries))
+	records := make([]*domain.NodeContentChunk, 0)
+	keywordResults := make([]*domain.KeywordSearchResult, 0)
+	for _, q := range queries {
+		queryHit := &queryHits{}
+		if settings.GetVectorWeight() > 0 {
+			var err error
+			queryHit.records, err = u.rag.QueryRecords(ctx, []string{kb.DatasetID}, q, settings.GetVectorTopK())
+			if err != nil {
+				return nil, fmt.Errorf("get records from raglite failed: %w", err)
+			}
+		}
+		if settings.GetKeywordWeight() > 0 {
+			var err error
+			queryHit.keywordResults, err = u.nodeRepo.SearchNodeReleases(ctx, kb.ID, q, settings.GetKeywordTopK())
+			if err != nil {
+				return nil, fmt.Errorf("search node releases failed: %w", err)
+			}
+		}
+		hits = append(hits, queryHit)
+		records = append(records, queryHit.records...)
+		keywordResults = append(keywordResults, queryHit.keywordResults...)
+	}
+	u.logger.Info("get related documents from raglite", log.Any("record_count", len(records)))
+	u.logger.Info("get related documents from full text search", log.Int("result_count", len(keywordResults)))
+
+	docIDNode := make(map[string]*domain.NodeRelease)
+	if len(records) > 0 {
+		docIDs := lo.Uniq(lo.Map(records, func(item *domain.NodeContentChunk, _ int) string {
+			return item.DocID
+		}))
+		var err error
+		docIDNode, err = u.nodeRepo.GetNodeReleasesByDocIDs(ctx, docIDs)
+		if err != nil {
+			return nil, fmt.Errorf("get nodes by ids failed: %w", err)
+		}
+	}
+
+	rankedNodes := fuseRankedNodes(hits, docIDNode, query, settings)
+	rankedNodes = u.rerankNodes(ctx, query, rankedNodes, settings.RerankThreshold)
+	if topK := settings.GetTopK(); len(rankedNodes) > topK {
+		rankedNodes = rankedNodes[:topK]
+	}
+	u.logger.Info("retrieved chunks", log.Any("chunks", domain.NewRetrievedChunks(rankedNodes)))
+	return &domain.RetrievalResult{
+		Queries:        queries,
+		VectorChunks:   records,
+		DocIDNode:      docIDNode,
+		KeywordResults: keywordResults,
+		RankedNodes:    rankedNodes,
+	}, nil
+}
+
+// fuseRankedNodes merges vector chunks and keyword hits of all queries by node with weighted reciprocal rank fusion.
+// Nodes hit by vector search keep their chunks, nodes only hit by keyword search get an excerpt around the match.
+// Top k is applied by the caller after rerank.
+func fuseRankedNodes(
+	hits []*queryHits,
+	docIDNode map[string]*domain.NodeRelease,
+	question string,
+	settings domain.RetrievalSettings,
+) []*domain.RankedNodeChunks {
+	rankedNodes := make([]*domain.RankedNodeChunks, 0)
+	rankedNodesMap := make(map[string]*domain.RankedNodeChunks)
+	chunkIDs := make(map[string]struct{})
+
+	vectorWeight := settings.GetVectorWeight()
+	keywordWeight := settings.GetKeywordWeight()
+	for _, hit := range hits {
+		// vector ranking, a node ranks by its best chunk
+		vectorRank := 0
+		rankedInQuery := make(map[string]struct{})
+		for i, record := range hit.records {
+			docNode, ok := docIDNode[record.DocID]
+			if !ok {
+				continue
+			}
+			nodeChunk, ok := rankedNodesMap[docNode.NodeID]
+			if !ok {
+				nodeChunk = &domain.RankedNodeChunks{
+					NodeID:      docNode.NodeID,
+					NodeName:    docNode.Name,
+					NodeSummary: docNode.Meta.Summary,
+					Chunks:      make([]*domain.NodeContentChunk, 0),
+				}
+				rankedNodes = append(rankedNodes, nodeChunk)
+				rankedNodesMap[docNode.NodeID] = nodeChunk
+			}
+			if _, ok := chunkIDs[record.ID]; !ok {
+				chunkIDs[record.ID] = struct{}{}
+				record.VectorRank = i + 1
+				nodeChunk.Chunks = append(nodeChunk.Chunks, record)
+			}
+			if _, ok := rankedInQuery[docNode.NodeID]; !ok {
+				rankedInQuery[docNode.NodeID] = struct{}{}
+				vectorRank++
+				nodeChunk.Score += vectorWeight / float64(domain.RRFConstant+vectorRank)
+			}
+		}
+
+		// keyword ranking
+		for i, result := range hit.keywordResults {
+			score := keywordWeight / float64(domain.RRFConstant+i+1)
+			if nodeChunk, ok := rankedNodesMap[result.NodeRelease.NodeID]; ok {
+				nodeChunk.Score += score
+				if nodeChunk.KeywordRank == 0 || nodeChunk.KeywordRank > i+1 {
+					nodeChunk.KeywordRank = i + 1
+				}
+				continue
+			}
+			rankNodeChunk := &domain.RankedNodeChunks{
+				NodeID:      result.NodeRelease.NodeID,
+				NodeName:    result.NodeRelease.Name,
+				NodeSummary: result.NodeRelease.Meta.Summary,
+				Chunks: []*domain.NodeContentChunk{
+					{
+						ID:      result.NodeRelease.ID,
+						KBID:    result.NodeRelease.KBID,
+						DocID:   result.NodeRelease.DocID,
+						Name:    result.NodeRelease.Name,
+						Content: keywordExcerpt(result.NodeRelease.Content, question, keywordExcerptSize),
+					},
+				},
+				Score:       score,
+				KeywordRank: i + 1,
+			}
+			rankedNodes = append(rankedNodes, rankNodeChunk)
+			rankedNodesMap[result.NodeRelease.NodeID] = rankNodeChunk
+		}
+	}
+
+	sort.SliceStable(rankedNodes, func(i, j int) bool {
+		return rankedNodes[i].Score > rankedNodes[j].Score
+	})
+	return rankedNodes
+}
+
+// keywordExcerpt cuts about size runes of content around the longest query token found in it
+func keywordExcerpt(content, query string, size int) string {
+	if strings.HasPrefix(content, "<") {
+		if markdown, err := htmltomarkdown.ConvertString(content); err == nil {
+			content = markdown
+		}
+	}
+	runes := []rune(content)
+	if len(runes) <= size {
+		return content
+	}
+	tokens := utils.SearchTokens(query)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return utf8.RuneCountInString(tokens[i]) > utf8.RuneCountInString(tokens[j])
+	})
+	start := 0
+	lowerContent := strings.ToLower(content)
+	for _, token := range tokens {
+		if index := strings.Index(lowerContent, token); index >= 0 {
+			// keep some context before the match
+			start = max(utf8.RuneCountInString(lowerContent[:index])-size/4, 0)
+			break
+		}
+	}
+	end := min(start+size, len(runes))
+	start = max(end-size, 0)
+	return string(runes[start:end])
+}
+
+// RetrievalTest runs retrieval and renders the prompt for a question without calling the chat model
+func (u *LLMUsecase) RetrievalTest(ctx context.Context, req *domain.RetrievalTestReq) (*domain.RetrievalTestResp, error) {
+	kb, err := u.kbRepo.GetKnowledgeBaseByID(ctx, req.KBID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb failed: %w", err)
+	}
+	result, err := u.Retrieve(ctx, kb, req.Question, req.SubQueries...)
+	if err != nil {
+		return nil, err
+	}
+	result.RankedNodes = kb.PromptSettings.LimitDocs(result.RankedNodes)
+	messages, err := u.FormatPromptMessages(ctx, kb, kb.PromptSettings, req.Question, result.RankedNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.RetrievalTestResp{
+		Queries:      result.Queries,
+		VectorChunks: make([]*domain.RetrievalTestChunk, len(result.VectorChunks)),
+		KeywordHits:  make([]*domain.RetrievalTestKeywordHit, len(result.KeywordResults)),
+		RankedNodes:  result.RankedNodes,
+		Messages:     messages,
+	}
+	for i, chunk := range result.VectorChunks {
+		testChunk := &domain.RetrievalTestChunk{NodeContentChunk: chunk}
+		if nodeRelease, ok := result.DocIDNode[chunk.DocID]; ok {
+			testChunk.NodeRelease = newRetrievalTestNodeRelease(nodeRelease)
+		}
+		resp.VectorChunks[i] = testChunk
+	}
+	for i, hit := range result.KeywordResults {
+		resp.KeywordHits[i] = &domain.RetrievalTestKeywordHit{
+			RetrievalTestNodeRelease: *newRetrievalTestNodeRelease(hit.NodeRelease),
+			Rank:                     hit.Rank,
+		}
+	}
+	prompt := strings.Builder{}
+	for _, msg := range messages {
+		prompt.WriteString(fmt.Sprintf("[%s]\n%s\n\n", msg.Role, msg.Content))
+	}
+	resp.Prompt = prompt.String()
+	resp.TokenEstimate = utils.EstimateTokens(resp.Prompt)
+	return resp, nil
+}
+
+func newRetrievalTestNodeRelease(nodeRelease *domain.NodeRelease) *domain.RetrievalTestNodeRelease {
+	return &domain.RetrievalTestNodeRelease{
+		ID:     nodeRelease.ID,
+		NodeID: nodeRelease.NodeID,
+		DocID:  nodeRelease.DocID,
+		Name:   nodeRelease.Name,
+	}
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/repo/pg"
+	"github.com/chaitin/panda-wiki/store/rag"
+)
+
+// testRAG a rag service returning the same records for every query
+type testRAG struct {
+	rag.RAGService
+	records []*domain.NodeContentChunk
+}
+
+func (r *testRAG) QueryRecords(ctx context.Context, datasetIDs []string, query string, topK int) ([]*domain.NodeContentChunk, error) {
+	return r.records, nil
+}
+
+func TestFuseRankedNodes(t *testing.T) {
+	records := []*domain.NodeContentChunk{
+		{ID: "c1", DocID: "doc-a", Content: "a1"},
+		{ID: "c2", DocID: "doc-b", Content: "b1"},
+		{ID: "c3", DocID: "doc-a", Content: "a2"},
+		{ID: "c4", DocID: "doc-x", Content: "not published"},
+	}
+	docIDNode := map[string]*domain.NodeRelease{
+		"doc-a": {ID: "r-a", NodeID: "node-a", DocID: "doc-a", Name: "A"},
+		"doc-b": {ID: "r-b", NodeID: "node-b", DocID: "doc-b", Name: "B"},
+	}
+	keywordResults := []*domain.KeywordSearchResult{
+		{NodeRelease: &domain.NodeRelease{ID: "r-c", NodeID: "node-c", DocID: "doc-c", Name: "C", Content: "ERR-1024 means timeout"}},
+		{NodeRelease: docIDNode["doc-b"]},
+	}
+
+	hits := []*queryHits{{records: records, keywordResults: keywordResults}}
+	ranked := fuseRankedNodes(hits, docIDNode, "err-1024", domain.RetrievalSettings{})
+	got := make([]string, len(ranked))
+	for i, node := range ranked {
+		got[i] = node.NodeID
+	}
+	// node-b is hit by both searches and ranks first
+	if strings.Join(got, ",") != "node-b,node-a,node-c" {
+		t.Fatalf("unexpected ranking %v", got)
+	}
+	if len(ranked[1].Chunks) != 2 {
+		t.Errorf("node-a should keep both vector chunks, got %d", len(ranked[1].Chunks))
+	}
+	if ranked[2].Chunks[0].Content != "ERR-1024 means timeout" {
+		t.Errorf("keyword only node should carry its content, got %q", ranked[2].Chunks[0].Content)
+	}
+
+	if ranked[0].KeywordRank != 2 || ranked[1].Chunks[1].VectorRank != 3 {
+		t.Errorf("ranks are not recorded: %+v", domain.NewRetrievedChunks(ranked))
+	}
+
+	// keyword weight dominates
+	zero := 0.0
+	ranked = fuseRankedNodes(hits, docIDNode, "err-1024", domain.RetrievalSettings{VectorWeight: &zero})
+	if ranked[0].NodeID != "node-c" {
+		t.Fatalf("expected node-c first, got %s", ranked[0].NodeID)
+	}
+
+	// a sub query hitting node-a again adds to its score but not duplicated chunks
+	hits = []*queryHits{
+		{records: records},
+		{records: []*domain.NodeContentChunk{records[2], {ID: "c5", DocID: "doc-a", Content: "a3"}}},
+	}
+	ranked = fuseRankedNodes(hits, docIDNode, "err-1024", domain.RetrievalSettings{})
+	if ranked[0].NodeID != "node-a" || len(ranked[0].Chunks) != 3 {
+		t.Fatalf("unexpected multi query fusion: %+v", domain.NewRetrievedChunks(ranked))
+	}
+}
+
+func TestKeywordExcerpt(t *testing.T) {
+	content := strings.Repeat("前言", 500) + "错误码 ERR-1024 表示超时" + strings.Repeat("附录", 500)
+	excerpt := keywordExcerpt(content, "err-1024 是什么", 100)
+	if n := len([]rune(excerpt)); n != 100 {
+		t.Errorf("excerpt has %d runes, want 100", n)
+	}
+	if !strings.Contains(excerpt, "ERR-1024") {
+		t.Errorf("excerpt should contain the matched term, got %q", excerpt)
+	}
+	if got := keywordExcerpt("short", "x", 100); got != "short" {
+		t.Errorf("short content should be kept, got %q", got)
+	}
+}
+
+func TestApplyRerankScores(t *testing.T) {
+	newNodes := func() []*domain.RankedNodeChunks {
+		return []*domain.RankedNodeChunks{
+			{NodeID: "node-a", Chunks: []*domain.NodeContentChunk{{ID: "a1"}, {ID: "a2"}}},
+			{NodeID: "node-b", Chunks: []*domain.NodeContentChunk{{ID: "b1"}}},
+			{NodeID: "node-c", Chunks: []*domain.NodeContentChunk{{ID: "c1"}}},
+		}
+	}
+
+	ranked := applyRerankScores(newNodes(), []float64{0.2, 0.6, 0.9, 0.1}, nil)
+	if len(ranked) != 3 || ranked[0].NodeID != "node-b" || ranked[1].NodeID != "node-a" || ranked[2].NodeID != "node-c" {
+		t.Fatalf("unexpected order after rerank: %+v", ranked)
+	}
+	if ranked[1].Score != 0.6 || *ranked[1].Chunks[0].RerankScore != 0.2 {
+		t.Errorf("unexpected scores: node %v chunk %v", ranked[1].Score, *ranked[1].Chunks[0].RerankScore)
+	}
+
+	threshold := 0.5
+	ranked = applyRerankScores(newNodes(), []float64{0.2, 0.6, 0.9, 0.1}, &threshold)
+	if len(ranked) != 2 || len(ranked[1].Chunks) != 1 || ranked[1].Chunks[0].ID != "a2" {
+		t.Fatalf("chunks below threshold should be dropped: %+v", domain.NewRetrievedChunks(ranked))
+	}
+}
+
+func TestParseQueryRewrite(t *testing.T) {
+	answer := "<think>ok</think>\n```json\n{\"query\": \" 怎么配置 LDAP 登录？ \", \"sub_queries\": [\"LDAP 配置\", \"\", \"LDAP 配置\", \"怎么配置 LDAP 登录？\", \"LDAP 组映射\"]}\n```"
+	rewrite, err := parseQueryRewrite(answer, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrite.Query != "怎么配置 LDAP 登录？" {
+		t.Errorf("unexpected query %q", rewrite.Query)
+	}
+	if len(rewrite.SubQueries) != 1 || rewrite.SubQueries[0] != "LDAP 配置" {
+		t.Errorf("unexpected sub queries %v", rewrite.SubQueries)
+	}
+	if _, err := parseQueryRewrite("sorry", 1); err == nil {
+		t.Error("expected error for non json answer")
+	}
+}
+
+func TestRetrievalTest(t *testing.T) {
+	db, mock := newMockDB(t)
+	// the kb repo lists the kbs to sync the access settings when it is created
+	mock.ExpectQuery(`FROM "knowledge_bases" ORDER BY created_at ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	kbRepo := pg.NewKnowledgeBaseRepository(db, nil, newTestLogger(), nil)
+	mock.ExpectQuery(`SELECT \* FROM "knowledge_bases"`).
+		WithArgs("kb", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "dataset_id", "retrieval_settings", "prompt_settings"}).
+			AddRow("kb", "Wiki", "dataset", []byte(`{}`), []byte(`{"max_docs":2}`)))
+	mock.ExpectQuery(`FROM node_releases, CAST\(\$1 AS tsquery\) AS query`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kb_id", "node_id", "doc_id", "name", "content", "rank"}).
+			AddRow("r-c", "kb", "node-c", "doc-c", "C", "timeout of the keyword hit", 0.5))
+	mock.ExpectQuery(`SELECT \* FROM "node_releases"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kb_id", "node_id", "doc_id", "name"}).
+			AddRow("r-a", "kb", "node-a", "doc-a", "A").
+			AddRow("r-b", "kb", "node-b", "doc-b", "B"))
+	// no rerank model, the fused order is kept
+	mock.ExpectQuery(`SELECT \* FROM "models"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}))
+
+	u := &LLMUsecase{
+		rag: &testRAG{records: []*domain.NodeContentChunk{
+			{ID: "c1", DocID: "doc-a", Content: "timeout of the vector hit"},
+			{ID: "c2", DocID: "doc-b", Content: "timeout of the dropped node"},
+			{ID: "c3", DocID: "doc-x", Content: "not published"},
+		}},
+		kbRepo:    kbRepo,
+		nodeRepo:  pg.NewNodeRepository(db, newTestLogger()),
+		modelRepo: pg.NewModelRepository(db, newTestLogger()),
+		logger:    newTestLogger(),
+	}
+	resp, err := u.RetrievalTest(context.Background(), &domain.RetrievalTestReq{KBID: "kb", Question: "timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if strings.Join(resp.Queries, ",") != "timeout" {
+		t.Errorf("unexpected queries %v", resp.Queries)
+	}
+	if len(resp.VectorChunks) != 3 {
+		t.Fatalf("expected 3 vector chunks, got %d", len(resp.VectorChunks))
+	}
+	if nodeRelease := resp.VectorChunks[0].NodeRelease; nodeRelease == nil || nodeRelease.NodeID != "node-a" {
+		t.Errorf("vector chunk should carry its node release, got %+v", nodeRelease)
+	}
+	if resp.VectorChunks[2].NodeRelease != nil {
+		t.Errorf("unpublished chunk should have no node release, got %+v", resp.VectorChunks[2].NodeRelease)
+	}
+	if len(resp.KeywordHits) != 1 || resp.KeywordHits[0].NodeID != "node-c" || resp.KeywordHits[0].Rank != 0.5 {
+		t.Errorf("unexpected keyword hits %+v", resp.KeywordHits)
+	}
+	got := make([]string, len(resp.RankedNodes))
+	for i, node := range resp.RankedNodes {
+		got[i] = node.NodeID
+	}
+	// node-b ranks last and is cut by the max docs of the prompt
+	if strings.Join(got, ",") != "node-a,node-c" {
+		t.Errorf("unexpected ranking %v", got)
+	}
+	if !strings.Contains(resp.Prompt, "timeout of the vector hit") || !strings.Contains(resp.Prompt, "timeout of the keyword hit") {
+		t.Errorf("prompt should contain the ranked documents: %s", resp.Prompt)
+	}
+	if strings.Contains(resp.Prompt, "timeout of the dropped node") {
+		t.Errorf("prompt should not contain the dropped document: %s", resp.Prompt)
+	}
+	if len(resp.Messages) == 0 || resp.TokenEstimate <= 0 {
+		t.Errorf("unexpected messages %d and token estimate %d", len(resp.Messages), resp.TokenEstimate)
+	}
+}
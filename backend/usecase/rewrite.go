@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/schema"
+	"github.com/samber/lo"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+const (
+	rewriteHistorySize       = 6   // max history messages used to rewrite
+	rewriteHistoryContentLen = 500 // max runes of each history message
+)
+
+// RewriteQuery rewrites the follow up question into a standalone query with the conversation history,
+// the chat models of the app or the kb are tried in order, the consumed tokens are added to usage
+func (u *LLMUsecase) RewriteQuery(ctx context.Context, models []*domain.Model, history []*schema.Message, question string, maxSubQueries int, usage *schema.TokenUsage) (*domain.QueryRewrite, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("chat %w", domain.ErrModelNotConfigured)
+	}
+	template := prompt.FromMessages(schema.GoTemplate, schema.SystemMessage(domain.QueryRewritePrompt))
+	messages, err := template.Format(ctx, map[string]any{
+		"MaxSubQueries": maxSubQueries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("format rewrite prompt failed: %w", err)
+	}
+	conversation := strings.Builder{}
+	for _, msg := range history[max(len(history)-rewriteHistorySize, 0):] {
+		content := []rune(msg.Content)
+		if len(content) > rewriteHistoryContentLen {
+			content = append(content[:rewriteHistoryContentLen], []rune("...")...)
+		}
+		conversation.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, string(content)))
+	}
+	messages = append(messages, schema.UserMessage(fmt.Sprintf("<history>\n%s</history>\n\n<question>\n%s\n</question>", conversation.String(), question)))
+
+	var answer string
+	for i, model := range models {
+		answer, err = u.generateRewrite(ctx, model, messages, usage)
+		if err == nil || i == len(models)-1 {
+			break
+		}
+		u.logger.Warn("rewrite model failed, fallback to the next model",
+			log.String("model", model.Model),
+			log.String("next_model", models[i+1].Model),
+			log.Error(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseQueryRewrite(answer, maxSubQueries)
+}
+
+func (u *LLMUsecase) generateRewrite(ctx context.Context, model *domain.Model, messages []*schema.Message, usage *schema.TokenUsage) (string, error) {
+	chatModel, err := u.GetChatModel(ctx, model)
+	if err != nil {
+		return "", err
+	}
+	resp, err := chatModel.Generate(ctx, messages)
+	if err != nil {
+		return "", fmt.Errorf("generate failed: %w", err)
+	}
+	// some providers do not report the usage
+	consumed := estimateUsage(messages, resp.Content)
+	if resp.ResponseMeta != nil && resp.ResponseMeta.Usage != nil {
+		consumed = *resp.ResponseMeta.Usage
+	}
+	usage.PromptTokens += consumed.PromptTokens
+	usage.CompletionTokens += consumed.CompletionTokens
+	usage.TotalTokens += consumed.TotalTokens
+	return resp.Content, nil
+}
+
+// parseQueryRewrite parses the json answer of the rewrite prompt
+func parseQueryRewrite(answer string, maxSubQueries int) (*domain.QueryRewrite, error) {
+	if endIndex := strings.Index(answer, "</think>"); endIndex != -1 {
+		answer = answer[endIndex+len("</think>"):]
+	}
+	// the model may wrap json in a code block
+	start := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("invalid rewrite answer: %s", answer)
+	}
+	var rewrite domain.QueryRewrite
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &rewrite); err != nil {
+		return nil, fmt.Errorf("unmarshal rewrite answer failed: %w", err)
+	}
+	rewrite.Query = strings.TrimSpace(rewrite.Query)
+	if rewrite.Query == "" {
+		return nil, fmt.Errorf("empty rewritten query")
+	}
+	subQueries := make([]string, 0)
+	for _, query := range rewrite.SubQueries {
+		query = strings.TrimSpace(query)
+		if query != "" && query != rewrite.Query {
+			subQueries = append(subQueries, query)
+		}
+	}
+	subQueries = lo.Uniq(subQueries)
+	if len(subQueries) > maxSubQueries {
+		subQueries = subQueries[:maxSubQueries]
+	}
+	rewrite.SubQueries = subQueries
+	return &rewrite, nil
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
+)
+
+// sessionTouchInterval the last seen of a session is saved at most once in the interval unless the ip changes
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionUsecase struct {
+	sessionRepo *cache.SessionRepo
+	logger      *log.Logger
+}
+
+func NewSessionUsecase(sessionRepo *cache.SessionRepo, logger *log.Logger) *SessionUsecase {
+	return &SessionUsecase{
+		sessionRepo: sessionRepo,
+		logger:      logger.WithModule("usecase.session"),
+	}
+}
+
+// CreateSession the session of a jwt issued to the client
+func (u *SessionUsecase) CreateSession(ctx context.Context, userID string, client *domain.SessionClient, expiresAt time.Time) (*domain.Session, error) {
+	now := time.Now()
+	session := &domain.Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := u.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("create session failed: %w", err)
+	}
+	return session, nil
+}
+
+// Authenticate checks the session of the jwt is not revoked and records the last seen of the client
+func (u *SessionUsecase) Authenticate(ctx context.Context, userID, sessionID string, client *domain.SessionClient) (*domain.Session, error) {
+	if sessionID == "" {
+		return nil, ErrSessionNotFound
+	}
+	session, err := u.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get session failed: %w", err)
+	}
+	if session == nil || session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval || session.IP != client.IP {
+		session.LastSeenAt = now
+		session.IP = client.IP
+		if err := u.sessionRepo.UpdateSession(ctx, session); err != nil {
+			u.logger.Warn("update session failed", log.String("session_id", session.ID), log.Error(err))
+		}
+	}
+	return session, nil
+}
+
+// GetSessionList the sessions of the user, the current session is marked
+func (u *SessionUsecase) GetSessionList(ctx context.Context, userID, currentSessionID string) ([]*domain.SessionListItem, error) {
+	sessions, err := u.sessionRepo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get sessions failed: %w", err)
+	}
+	items := make([]*domain.SessionListItem, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, &domain.SessionListItem{
+			Session: session,
+			Current: session.ID == currentSessionID,
+		})
+	}
+	return items, nil
+}
+
+// GetSession nil if expired or revoked
+func (u *SessionUsecase) GetSession(ctx context.Context, sessionID string) (*domain.Session, error) {
+	return u.sessionRepo.GetSession(ctx, sessionID)
+}
+
+func (u *SessionUsecase) RevokeSession(ctx context.Context, session *domain.Session) error {
+	return u.sessionRepo.DeleteSession(ctx, session.UserID, session.ID)
+}
+
+// RevokeAllSessions logs the user out everywhere except the sessions to keep
+func (u *SessionUsecase) RevokeAllSessions(ctx context.Context, userID string, keepSessionIDs ...string) error {
+	return u.sessionRepo.DeleteSessionsByUserID(ctx, userID, keepSessionIDs...)
+}
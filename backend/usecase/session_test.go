@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/repo/cache"
+	cachestore "github.com/chaitin/panda-wiki/store/cache"
+)
+
+func newTestCache(t *testing.T) (*cachestore.Cache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &cachestore.Cache{Client: client}, server
+}
+
+func TestSessionRevoke(t *testing.T) {
+	c, server := newTestCache(t)
+	sessionRepo := cache.NewSessionRepo(c)
+	u := NewSessionUsecase(sessionRepo, newTestLogger())
+	ctx := context.Background()
+	client := &domain.SessionClient{IP: "10.0.0.1", UserAgent: "test"}
+	expiresAt := time.Now().Add(time.Hour)
+
+	current, err := u.CreateSession(ctx, "alice", client, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := u.CreateSession(ctx, "alice", client, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Authenticate(ctx, "alice", current.ID, client); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := u.Authenticate(ctx, "bob", current.ID, client); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Authenticate() of another user error = %v, expected %v", err, ErrSessionNotFound)
+	}
+
+	// logout
+	if err := u.RevokeSession(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Authenticate(ctx, "alice", other.ID, client); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Authenticate() of a revoked session error = %v, expected %v", err, ErrSessionNotFound)
+	}
+	// a request in flight touching the revoked session does not recreate it
+	if err := sessionRepo.UpdateSession(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := u.GetSession(ctx, other.ID); err != nil || session != nil {
+		t.Errorf("GetSession() of a revoked session = %v, %v, expected nil", session, err)
+	}
+
+	// logout everywhere else
+	for range 2 {
+		if _, err := u.CreateSession(ctx, "alice", client, expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.RevokeAllSessions(ctx, "alice", current.ID); err != nil {
+		t.Fatal(err)
+	}
+	items, err := u.GetSessionList(ctx, "alice", current.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != current.ID || !items[0].Current {
+		t.Errorf("GetSessionList() after revoking the others = %+v, expected only the current session", items)
+	}
+
+	// the session expires with its jwt
+	server.FastForward(time.Hour)
+	if _, err := u.Authenticate(ctx, "alice", current.ID, client); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Authenticate() of an expired session error = %v, expected %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestSessionTouch(t *testing.T) {
+	c, server := newTestCache(t)
+	u := NewSessionUsecase(cache.NewSessionRepo(c), newTestLogger())
+	ctx := context.Background()
+	session, err := u.CreateSession(ctx, "alice", &domain.SessionClient{IP: "10.0.0.1"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ip change is saved without extending the ttl
+	server.FastForward(30 * time.Minute)
+	if _, err := u.Authenticate(ctx, "alice", session.ID, &domain.SessionClient{IP: "10.0.0.2"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := u.GetSession(ctx, session.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("GetSession() = %v, %v", saved, err)
+	}
+	if saved.IP != "10.0.0.2" {
+		t.Errorf("ip of the session = %s, expected 10.0.0.2", saved.IP)
+	}
+	if ttl := server.TTL("session:" + session.ID); ttl > 31*time.Minute {
+		t.Errorf("ttl of the session = %v, expected not extended", ttl)
+	}
+}
@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+
+	"github.com/chaitin/panda-wiki/domain"
+	"github.com/chaitin/panda-wiki/log"
+)
+
+const (
+	twoFactorIssuer         = "PandaWiki"
+	twoFactorChallengeTTL   = 5 * time.Minute
+	twoFactorMaxAttempts    = 5
+	twoFactorUsedCodeTTL    = 2 * time.Minute // longer than the window of a code with the default skew
+	twoFactorRecoveryCodes  = 10
+	twoFactorRecoveryLength = 5 // bytes, 10 hex characters
+)
+
+var (
+	ErrTwoFactorInvalidCode   = errors.New("invalid two factor code")
+	ErrTwoFactorInvalidToken  = errors.New("two factor login is invalid or expired, please login again")
+	ErrTwoFactorNotEnrolled   = errors.New("two factor authentication is not enrolled")
+	ErrTwoFactorEnabled       = errors.New("two factor authentication is already enabled")
+	ErrTwoFactorRequired      = errors.New("two factor authentication is required by the admins")
+	ErrTwoFactorNotApplicable = errors.New("service accounts can not enroll two factor authentication")
+)
+
+// LoginUser issues the token of the user whose password is verified,
+// or the challenge of the second step if the user has 2fa or 2fa is required
+func (u *UserUsecase) LoginUser(ctx context.Context, user *domain.User, client *domain.SessionClient) (*domain.LoginResp, error) {
+	setting, err := u.GetSecuritySetting(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled && !setting.RequireTwoFactor {
+		token, err := u.GenerateToken(ctx, user, client)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.LoginResp{Token: token}, nil
+	}
+	twoFactorToken, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.twoFactorRepo.SetChallenge(ctx, twoFactorToken, &domain.TwoFactorChallenge{UserID: user.ID}, twoFactorChallengeTTL); err != nil {
+		return nil, fmt.Errorf("save two factor challenge failed: %w", err)
+	}
+	return &domain.LoginResp{
+		TwoFactorRequired:       true,
+		TwoFactorToken:          twoFactorToken,
+		TwoFactorEnrollRequired: !user.TwoFactorEnabled,
+	}, nil
+}
+
+// LoginTwoFactor the second step of the login, users enrolling during the login enable 2fa by the first code
+func (u *UserUsecase) LoginTwoFactor(ctx context.Context, req *domain.TwoFactorLoginReq, client *domain.SessionClient) (*domain.TwoFactorLoginResp, error) {
+	challenge, err := u.twoFactorRepo.GetChallenge(ctx, req.TwoFactorToken)
+	if err != nil {
+		return nil, fmt.Errorf("get two factor challenge failed: %w", err)
+	}
+	if challenge == nil {
+		return nil, ErrTwoFactorInvalidToken
+	}
+	// counted before the code is verified, so parallel guesses can not exceed the attempts
+	attempts, err := u.twoFactorRepo.IncrAttempts(ctx, req.TwoFactorToken, twoFactorChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("count two factor attempts failed: %w", err)
+	}
+	if attempts > twoFactorMaxAttempts {
+		return nil, ErrTwoFactorInvalidToken
+	}
+	user, err := u.repo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user failed: %w", err)
+	}
+
+	resp := &domain.TwoFactorLoginResp{}
+	if user.TwoFactorEnabled {
+		err = u.verifyTwoFactorCode(ctx, user, req.Code)
+	} else {
+		resp.RecoveryCodes, err = u.enableTwoFactor(ctx, user, req.Code)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrTwoFactorInvalidCode) {
+			return nil, err
+		}
+		// the password has to be verified again after too many wrong codes
+		if attempts >= twoFactorMaxAttempts {
+			if err := u.twoFactorRepo.DeleteChallenge(ctx, req.TwoFactorToken); err != nil {
+				u.logger.Error("delete two factor challenge failed", log.Error(err))
+			}
+		}
+		u.logger.Warn("invalid two factor code", log.String("account", user.Account), log.Int("attempts", int(attempts)))
+		return nil, ErrTwoFactorInvalidCode
+	}
+	if err := u.twoFactorRepo.DeleteChallenge(ctx, req.TwoFactorToken); err != nil {
+		return nil, fmt.Errorf("delete two factor challenge failed: %w", err)
+	}
+	if resp.Token, err = u.GenerateToken(ctx, user, client); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LoginEnrollTwoFactor starts the enrollment of a user required to enroll 2fa before the first login
+func (u *UserUsecase) LoginEnrollTwoFactor(ctx context.Context, req *domain.TwoFactorLoginEnrollReq) (*domain.TwoFactorEnrollResp, error) {
+	challenge, err := u.twoFactorRepo.GetChallenge(ctx, req.TwoFactorToken)
+	if err != nil {
+		return nil, fmt.Errorf("get two factor challenge failed: %w", err)
+	}
+	if challenge == nil {
+		return nil, ErrTwoFactorInvalidToken
+	}
+	return u.EnrollTwoFactor(ctx, challenge.UserID)
+}
+
+// EnrollTwoFactor generates a pending totp secret, 2fa is enabled after the first valid code
+func (u *UserUsecase) EnrollTwoFactor(ctx context.Context, userID string) (*domain.TwoFactorEnrollResp, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user failed: %w", err)
+	}
+	if user.Type == domain.UserTypeServiceAccount {
+		return nil, ErrTwoFactorNotApplicable
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      twoFactorIssuer,
+		AccountName: user.Account,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret failed: %w", err)
+	}
+	image, err := key.Image(200, 200)
+	if err != nil {
+		return nil, fmt.Errorf("generate qr code failed: %w", err)
+	}
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return nil, fmt.Errorf("encode qr code failed: %w", err)
+	}
+	if err := u.repo.UpdateTwoFactor(ctx, user.ID, false, key.Secret(), nil); err != nil {
+		return nil, fmt.Errorf("save totp secret failed: %w", err)
+	}
+	return &domain.TwoFactorEnrollResp{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode.Bytes()),
+	}, nil
+}
+
+// EnableTwoFactor confirms the enrollment by a code of the pending secret, returns the recovery codes
+func (u *UserUsecase) EnableTwoFactor(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user failed: %w", err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	return u.enableTwoFactor(ctx, user, code)
+}
+
+func (u *UserUsecase) enableTwoFactor(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := u.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.UpdateTwoFactor(ctx, user.ID, true, user.TwoFactorSecret, hashes); err != nil {
+		return nil, fmt.Errorf("enable two factor failed: %w", err)
+	}
+	u.logger.Info("two factor enabled", log.String("account", user.Account))
+	u.recordTwoFactor(ctx, domain.AuditActionUserEnableTwoFactor, user.ID, false, true)
+	return codes, nil
+}
+
+// DisableTwoFactor turns off 2fa of the user by a valid code, not allowed when 2fa is required
+func (u *UserUsecase) DisableTwoFactor(ctx context.Context, userID, code string) error {
+	setting, err := u.GetSecuritySetting(ctx)
+	if err != nil {
+		return err
+	}
+	if setting.RequireTwoFactor {
+		return ErrTwoFactorRequired
+	}
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user failed: %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err := u.verifyTwoFactorCode(ctx, user, code); err != nil {
+		return err
+	}
+	if err := u.repo.UpdateTwoFactor(ctx, user.ID, false, "", nil); err != nil {
+		return err
+	}
+	u.recordTwoFactor(ctx, domain.AuditActionUserDisableTwoFactor, user.ID, true, false)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, the old codes are invalidated
+func (u *UserUsecase) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user failed: %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := u.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.UpdateTwoFactor(ctx, user.ID, true, user.TwoFactorSecret, hashes); err != nil {
+		return nil, fmt.Errorf("save recovery codes failed: %w", err)
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor clears 2fa of a user who lost the device, done by admins
+func (u *UserUsecase) ResetTwoFactor(ctx context.Context, userID string) error {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user failed: %w", err)
+	}
+	if err := u.repo.UpdateTwoFactor(ctx, userID, false, "", nil); err != nil {
+		return err
+	}
+	u.recordTwoFactor(ctx, domain.AuditActionUserResetTwoFactor, userID, user.TwoFactorEnabled, false)
+	return nil
+}
+
+func (u *UserUsecase) recordTwoFactor(ctx context.Context, action domain.AuditAction, userID string, before, after bool) {
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     action,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+	}, map[string]any{"two_factor_enabled": before}, map[string]any{"two_factor_enabled": after})
+}
+
+func (u *UserUsecase) GetSecuritySetting(ctx context.Context) (*domain.SecuritySetting, error) {
+	setting := &domain.SecuritySetting{}
+	if err := u.settingRepo.GetSetting(ctx, domain.SettingKeySecurity, setting); err != nil {
+		return nil, fmt.Errorf("get security setting failed: %w", err)
+	}
+	return setting, nil
+}
+
+func (u *UserUsecase) UpdateSecuritySetting(ctx context.Context, setting *domain.SecuritySetting) error {
+	before, err := u.GetSecuritySetting(ctx)
+	if err != nil {
+		return err
+	}
+	if err := u.settingRepo.UpsertSetting(ctx, domain.SettingKeySecurity, setting); err != nil {
+		return err
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionSecuritySettingUpdate,
+		TargetType: domain.AuditTargetSecuritySetting,
+		TargetID:   domain.SettingKeySecurity,
+	}, before, setting)
+	return nil
+}
+
+// verifyTwoFactorCode accepts a totp code or an unused recovery code
+func (u *UserUsecase) verifyTwoFactorCode(ctx context.Context, user *domain.User, code string) error {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		return u.verifyTOTP(ctx, user, code)
+	}
+	ok, err := u.repo.UseRecoveryCode(ctx, user.ID, hashAPIKey(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("use recovery code failed: %w", err)
+	}
+	if !ok {
+		return ErrTwoFactorInvalidCode
+	}
+	u.logger.Info("recovery code used", log.String("account", user.Account))
+	return nil
+}
+
+// verifyTOTP each code is accepted once
+func (u *UserUsecase) verifyTOTP(ctx context.Context, user *domain.User, code string) error {
+	code = strings.TrimSpace(code)
+	if !totp.Validate(code, user.TwoFactorSecret) {
+		return ErrTwoFactorInvalidCode
+	}
+	unused, err := u.twoFactorRepo.MarkCodeUsed(ctx, user.ID, code, twoFactorUsedCodeTTL)
+	if err != nil {
+		return fmt.Errorf("mark totp code used failed: %w", err)
+	}
+	if !unused {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeRecoveryCode recovery codes are displayed as xxxxx-xxxxx and accepted without the dash in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// generateRecoveryCodes the plaintext codes for the user and the hashes to store
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for range twoFactorRecoveryCodes {
+		code, err := randomHex(twoFactorRecoveryLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+		hashes = append(hashes, hashAPIKey(code))
+	}
+	return codes, hashes, nil
+}
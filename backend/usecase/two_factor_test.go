@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != twoFactorRecoveryCodes || len(hashes) != twoFactorRecoveryCodes {
+		t.Fatalf("expected %d codes, got %d", twoFactorRecoveryCodes, len(codes))
+	}
+	for i, code := range codes {
+		if isTOTPCode(code) {
+			t.Errorf("recovery code %s should not be taken as a totp code", code)
+		}
+		// the code is accepted as displayed, without the dash or in upper case
+		for _, input := range []string{code, strings.ReplaceAll(code, "-", ""), strings.ToUpper(code)} {
+			if hashAPIKey(normalizeRecoveryCode(input)) != hashes[i] {
+				t.Errorf("recovery code %q does not match its hash", input)
+			}
+		}
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	for code, expected := range map[string]bool{
+		"123456":      true,
+		"12345":       false,
+		"12345a":      false,
+		"abcde-12345": false,
+	} {
+		if got := isTOTPCode(code); got != expected {
+			t.Errorf("isTOTPCode(%q) = %v, expected %v", code, got, expected)
+		}
+	}
+}
@@ -2,60 +2,210 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"github.com/chaitin/panda-wiki/config"
 	"github.com/chaitin/panda-wiki/domain"
 	"github.com/chaitin/panda-wiki/log"
+	"github.com/chaitin/panda-wiki/repo/cache"
 	"github.com/chaitin/panda-wiki/repo/pg"
 )
 
 type UserUsecase struct {
-	repo   *pg.UserRepository
-	logger *log.Logger
-	config *config.Config
+	repo          *pg.UserRepository
+	apiTokenRepo  *pg.APITokenRepository
+	memberRepo    *pg.KBMemberRepository
+	settingRepo   *pg.SettingRepository
+	twoFactorRepo *cache.TwoFactorRepo
+	sessions      *SessionUsecase
+	audit         *AuditUsecase
+	logger        *log.Logger
+	config        *config.Config
 }
 
-func NewUserUsecase(repo *pg.UserRepository, logger *log.Logger, config *config.Config) (*UserUsecase, error) {
+func NewUserUsecase(repo *pg.UserRepository, apiTokenRepo *pg.APITokenRepository, memberRepo *pg.KBMemberRepository, settingRepo *pg.SettingRepository, twoFactorRepo *cache.TwoFactorRepo, sessions *SessionUsecase, audit *AuditUsecase, logger *log.Logger, config *config.Config) (*UserUsecase, error) {
 	if config.AdminPassword != "" {
 		if err := repo.UpsertDefaultUser(context.Background(), &domain.User{
 			ID:       uuid.New().String(),
 			Account:  "admin",
 			Password: config.AdminPassword,
+			Role:     domain.RoleOwner,
 		}); err != nil {
 			return nil, fmt.Errorf("failed to create default user: %w", err)
 		}
 	}
 	return &UserUsecase{
-		repo:   repo,
-		logger: logger.WithModule("usecase.user"),
-		config: config,
+		repo:          repo,
+		apiTokenRepo:  apiTokenRepo,
+		memberRepo:    memberRepo,
+		settingRepo:   settingRepo,
+		twoFactorRepo: twoFactorRepo,
+		sessions:      sessions,
+		audit:         audit,
+		logger:        logger.WithModule("usecase.user"),
+		config:        config,
 	}, nil
 }
 
 func (u *UserUsecase) CreateUser(ctx context.Context, user *domain.User) error {
-	return u.repo.CreateUser(ctx, user)
+	if err := u.repo.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionUserCreate,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+	}, nil, userAuditFields(user))
+	return nil
+}
+
+// userAuditFields the fields of the user recorded in the audit log
+func userAuditFields(user *domain.User) map[string]any {
+	return map[string]any{
+		"account":            user.Account,
+		"type":               user.Type,
+		"role":               user.Role,
+		"source":             user.Source,
+		"two_factor_enabled": user.TwoFactorEnabled,
+	}
 }
 
-func (u *UserUsecase) VerifyUserAndGenerateToken(ctx context.Context, req domain.LoginReq) (string, error) {
+// Login verifies the password of a local user, users with 2fa get the challenge of the second step instead of the token
+func (u *UserUsecase) Login(ctx context.Context, req domain.LoginReq, client *domain.SessionClient) (*domain.LoginResp, error) {
 	var user *domain.User
 	var err error
+	if u.config.Auth.DisableLocalLogin {
+		return nil, fmt.Errorf("local login is disabled")
+	}
 	user, err = u.repo.VerifyUser(ctx, req.Account, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if user.Type == domain.UserTypeServiceAccount {
+		return nil, fmt.Errorf("service account %s can not login", user.Account)
+	}
+	if user.Source != "" && user.Source != domain.UserSourceLocal {
+		return nil, fmt.Errorf("user %s must login by %s", user.Account, user.Source)
+	}
+	return u.LoginUser(ctx, user, client)
+}
+
+// GenerateToken signs the jwt of the authenticated user, the jti is the id of the session of the client
+func (u *UserUsecase) GenerateToken(ctx context.Context, user *domain.User, client *domain.SessionClient) (string, error) {
+	expiresAt := time.Now().Add(time.Hour * 24)
+	session, err := u.sessions.CreateSession(ctx, user.ID, client, expiresAt)
 	if err != nil {
 		return "", err
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"id":  user.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"jti": session.ID,
+		"exp": expiresAt.Unix(),
 	})
 
 	return token.SignedString([]byte(u.config.Auth.JWT.Secret))
 }
 
+// ProvisionExternalUser gets or creates the user of the external identity just in time,
+// the roles are synced from the groups when group roles are configured
+func (u *UserUsecase) ProvisionExternalUser(ctx context.Context, external *domain.ExternalUser, groupRoles []config.GroupRoleConfig) (*domain.User, error) {
+	user, err := u.repo.GetUserByExternalID(ctx, external.Source, external.ExternalID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("get user failed: %w", err)
+		}
+		// the password is never used, login of external users is rejected
+		password := make([]byte, 24)
+		if _, err := rand.Read(password); err != nil {
+			return nil, fmt.Errorf("generate password failed: %w", err)
+		}
+		user = &domain.User{
+			ID:         uuid.New().String(),
+			Account:    external.Account,
+			Password:   hex.EncodeToString(password),
+			Type:       domain.UserTypeUser,
+			Source:     external.Source,
+			ExternalID: external.ExternalID,
+		}
+		if err := u.repo.CreateUser(ctx, user); err != nil {
+			return nil, fmt.Errorf("create user %s failed: %w", external.Account, err)
+		}
+		u.logger.Info("external user provisioned", log.String("account", user.Account), log.String("source", string(user.Source)))
+	}
+	if len(groupRoles) == 0 {
+		return user, nil
+	}
+	role, kbRoles := mapGroupRoles(groupRoles, external.Groups)
+	members, err := u.memberRepo.GetMembersByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get kb members failed: %w", err)
+	}
+	// the empty role of a kb means not a member
+	beforeKBRoles := make(map[string]domain.Role, len(kbRoles))
+	for kbID := range kbRoles {
+		beforeKBRoles[kbID] = ""
+	}
+	for _, member := range members {
+		if _, ok := kbRoles[member.KBID]; ok {
+			beforeKBRoles[member.KBID] = member.Role
+		}
+	}
+	before := map[string]any{"role": user.Role, "kb_roles": beforeKBRoles}
+	if role != user.Role {
+		if err := u.repo.UpdateUserRole(ctx, user.ID, role); err != nil {
+			return nil, fmt.Errorf("update user role failed: %w", err)
+		}
+		user.Role = role
+	}
+	for kbID, kbRole := range kbRoles {
+		if kbRole == "" {
+			err = u.memberRepo.DeleteMember(ctx, kbID, user.ID)
+		} else {
+			err = u.memberRepo.UpsertMember(ctx, &domain.KBMember{KBID: kbID, UserID: user.ID, Role: kbRole, CreatedAt: time.Now()})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sync kb member failed: %w", err)
+		}
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionUserSyncRoles,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+	}, before, map[string]any{"role": role, "kb_roles": kbRoles})
+	return user, nil
+}
+
+// mapGroupRoles the highest global role and kb roles of the groups, kbs of the configs without a matched group map to the empty role
+func mapGroupRoles(groupRoles []config.GroupRoleConfig, groups []string) (domain.Role, map[string]domain.Role) {
+	role := domain.Role("")
+	kbRoles := make(map[string]domain.Role)
+	for _, groupRole := range groupRoles {
+		if groupRole.KBID != "" {
+			if _, ok := kbRoles[groupRole.KBID]; !ok {
+				kbRoles[groupRole.KBID] = ""
+			}
+		}
+		if !slices.Contains(groups, groupRole.Group) {
+			continue
+		}
+		if groupRole.KBID == "" {
+			role = role.Max(domain.Role(groupRole.Role))
+		} else {
+			kbRoles[groupRole.KBID] = kbRoles[groupRole.KBID].Max(domain.Role(groupRole.Role))
+		}
+	}
+	return role, kbRoles
+}
+
 func (u *UserUsecase) GetUser(ctx context.Context, userID string) (*domain.UserInfoResp, error) {
 	user, err := u.repo.GetUser(ctx, userID)
 	if err != nil {
@@ -64,7 +214,12 @@ func (u *UserUsecase) GetUser(ctx context.Context, userID string) (*domain.UserI
 	return &domain.UserInfoResp{
 		ID:        user.ID,
 		Account:   user.Account,
+		Type:      user.Type,
+		Role:      user.Role,
+		Source:    user.Source,
 		CreatedAt: user.CreatedAt,
+
+		TwoFactorEnabled: user.TwoFactorEnabled,
 	}, nil
 }
 
@@ -72,10 +227,44 @@ func (u *UserUsecase) ListUsers(ctx context.Context) ([]*domain.UserListItemResp
 	return u.repo.ListUsers(ctx)
 }
 
+// ResetPassword also revokes all sessions of the user
 func (u *UserUsecase) ResetPassword(ctx context.Context, req *domain.ResetPasswordReq) error {
-	return u.repo.UpdateUserPassword(ctx, req.ID, req.NewPassword)
+	if err := u.repo.UpdateUserPassword(ctx, req.ID, req.NewPassword); err != nil {
+		return err
+	}
+	if err := u.sessions.RevokeAllSessions(ctx, req.ID); err != nil {
+		return fmt.Errorf("revoke sessions failed: %w", err)
+	}
+	// only that a new password is set, the value is redacted
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionUserResetPassword,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   req.ID,
+	}, nil, map[string]any{"password": req.NewPassword})
+	return nil
 }
 
 func (u *UserUsecase) DeleteUser(ctx context.Context, userID string) error {
-	return u.repo.DeleteUser(ctx, userID)
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user failed: %w", err)
+	}
+	if err := u.repo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := u.apiTokenRepo.DeleteAPITokensByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("delete api tokens failed: %w", err)
+	}
+	if err := u.memberRepo.DeleteMembersByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("delete kb members failed: %w", err)
+	}
+	if err := u.sessions.RevokeAllSessions(ctx, userID); err != nil {
+		return fmt.Errorf("revoke sessions failed: %w", err)
+	}
+	u.audit.Record(ctx, &domain.AuditLog{
+		Action:     domain.AuditActionUserDelete,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+	}, userAuditFields(user), nil)
+	return nil
 }
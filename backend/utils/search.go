@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSearchTokenLen       = 64
+	maxSearchQueryTokens    = 64
+	maxTSVectorPosition     = 16383 // postgres limit
+	maxTSVectorLexemeNumPos = 256   // postgres limit
+)
+
+// SearchTokens splits text into full text search tokens.
+// Latin words, numbers and identifiers like ERR-1024 or api.v1 are kept as a whole
+// (together with their parts), CJK text has no word boundary so it is cut into bigrams.
+func SearchTokens(text string) []string {
+	tokens := make([]string, 0)
+	word := make([]rune, 0)
+	cjk := make([]rune, 0)
+
+	flushWord := func() {
+		// trim trailing joiners, e.g. "end." or "v1-"
+		for len(word) > 0 && isSearchJoiner(word[len(word)-1]) {
+			word = word[:len(word)-1]
+		}
+		if len(word) > 0 && len(word) <= maxSearchTokenLen {
+			token := string(word)
+			tokens = append(tokens, token)
+			if parts := strings.FieldsFunc(token, isSearchJoiner); len(parts) > 1 {
+				tokens = append(tokens, parts...)
+			}
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			flushCJK()
+			word = append(word, r)
+		case isSearchJoiner(r) && len(word) > 0:
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// BuildTSVector builds a postgres tsvector literal from text, use it as ?::tsvector
+func BuildTSVector(text string) string {
+	positions := make(map[string][]int)
+	for i, token := range SearchTokens(text) {
+		pos := min(i+1, maxTSVectorPosition)
+		if len(positions[token]) < maxTSVectorLexemeNumPos {
+			positions[token] = append(positions[token], pos)
+		}
+	}
+	lexemes := make([]string, 0, len(positions))
+	for lexeme := range positions {
+		lexemes = append(lexemes, lexeme)
+	}
+	sort.Strings(lexemes)
+
+	var sb strings.Builder
+	for i, lexeme := range lexemes {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(quoteLexeme(lexeme))
+		sb.WriteByte(':')
+		for j, pos := range uniqPositions(positions[lexeme]) {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "%d", pos)
+		}
+	}
+	return sb.String()
+}
+
+// BuildTSQuery builds a postgres tsquery literal matching any token of the query, use it as ?::tsquery.
+// An empty string is returned if the query has no searchable token.
+func BuildTSQuery(query string) string {
+	seen := make(map[string]struct{})
+	terms := make([]string, 0)
+	for _, token := range SearchTokens(query) {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		terms = append(terms, quoteLexeme(token))
+		if len(terms) >= maxSearchQueryTokens {
+			break
+		}
+	}
+	return strings.Join(terms, " | ")
+}
+
+// uniqPositions removes duplicated positions after clamping to the postgres limit
+func uniqPositions(positions []int) []int {
+	result := make([]int, 0, len(positions))
+	for _, pos := range positions {
+		if len(result) > 0 && result[len(result)-1] == pos {
+			continue
+		}
+		result = append(result, pos)
+	}
+	return result
+}
+
+func quoteLexeme(lexeme string) string {
+	lexeme = strings.ReplaceAll(lexeme, `\`, `\\`)
+	lexeme = strings.ReplaceAll(lexeme, `'`, `''`)
+	return "'" + lexeme + "'"
+}
+
+func isSearchJoiner(r rune) bool {
+	return r == '-' || r == '.'
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// EstimateTokens roughly estimates llm tokens of text without a tokenizer:
+// a CJK character is about one token, other text is about four bytes per token
+func EstimateTokens(text string) int {
+	cjk := 0
+	others := 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			others += len(string(r))
+		}
+	}
+	return cjk + (others+3)/4
+}